@@ -13,6 +13,10 @@ import (
 func main() {
 	cfgFilePath := readFlags()
 	cfg, ticker := config.Load(cfgFilePath)
+	cfg.Mu.Lock()
+	cfg.OnReload = func() { service.NotifyConfigReloaded(cfg) }
+	cfg.Mu.Unlock()
+	service.NotifyStartup(cfg)
 	go service.StartServer(cfg)
 	handleTicks(cfg, ticker)
 }
@@ -31,11 +35,17 @@ func readFlags() string {
 	return cfgFilePath
 }
 
-//handles received ticks on the supplied ticker
+//handles received ticks on the supplied ticker and, when enabled, settled address change events, the ticker remains
+//as a safety net poll
 func handleTicks(cfg *config.Configuration, ticker *time.Ticker) {
 	defer ticker.Stop()
+	addressEvents := service.WatchAddressEvents(cfg)
 	for {
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-addressEvents:
+			log.Println("Performing an immediate update after address changes")
+		}
 		err := service.PerformDDNSActions(cfg)
 		if err != nil {
 			log.Println(err)
@@ -11,28 +11,113 @@ import (
 	"time"
 )
 
+// the natDetection publish values, the IPv4 address published when carrier grade or double NAT is detected
+const (
+	NATPublishRouter   = "router"
+	NATPublishExternal = "external"
+)
+
 type Configuration struct {
 	CfgFilePath        string                 `json:"-"`
 	Reloaded           chan bool              `json:"-"`              // A channel upon which config reload events are delivered
 	LastUpdateInterval string                 `json:"-"`              // Used to track changes to the update interval
 	FileInfo           os.FileInfo            `json:"-"`              // Used to track changes to the config file
 	Mu                 *sync.Mutex            `json:"-"`              // Used to lock and unlock access to the package level cfg
+	OnReload           func()                 `json:"-"`              // Called after each config reload, set under Mu
 	UpdateInterval     string                 `json:"updateInterval"` // A duration string parsed by time.ParseDuration
 	ServerPort         string                 `json:"serverPort"`     // The port that the inbuilt http server listens on
 	Hostname           string                 `json:"hostname"`       // The hostname of the machine where this code is running
 	LastIPv4           net.IP                 `json:"lastIPv4"`
 	LastIPv6           net.IP                 `json:"lastIPv6"`
+	LastIPv6Prefix     string                 `json:"lastIPv6Prefix,omitempty"` // The last delegated IPv6 prefix in use
 	Router             RouterConfiguration    `json:"router,omitempty"`
+	IPSources          *IPSources             `json:"ipSources,omitempty"` // An ordered list of public IP address sources
+	IPv4               *AddressFamily         `json:"ipv4,omitempty"`      // IPv4 mode and source chain, required by default
+	IPv6               *AddressFamily         `json:"ipv6,omitempty"`      // IPv6 mode and source chain, optional by default
 	Services           []ServiceConfiguration `json:"services,omitempty"`
 	Notifications      Notifications          `json:"notifications,omitempty"`
+	DynDNSServer       *DynDNSServer          `json:"dynDnsServer,omitempty"`     // DynDNS2 compatible update server mode
+	ChangeValidation   *ChangeValidation      `json:"changeValidation,omitempty"` // Address validation and hold-down
+	NATDetection       *NATDetection          `json:"natDetection,omitempty"`     // Carrier grade and double NAT detection
+	AddressEvents      *AddressEvents         `json:"addressEvents,omitempty"`    // Immediate updates on address changes
 }
 
 type RouterConfiguration struct {
-	RouterType   string `json:"routerType,omitempty"`
-	Username     string `json:"userName,omitempty"`
-	Password     string `json:"password,omitempty"`
-	LoginUrl     string `json:"loginUrl,omitempty"`
-	IpDetailsUrl string `json:"ipDetailsUrl,omitempty"`
+	RouterType         string `json:"routerType,omitempty"`
+	Username           string `json:"userName,omitempty"`
+	Password           string `json:"password,omitempty"`
+	LoginUrl           string `json:"loginUrl,omitempty"`
+	IpDetailsUrl       string `json:"ipDetailsUrl,omitempty"`
+	Interface          string `json:"interface,omitempty"`          // The WAN interface name of router APIs
+	APIKey             string `json:"apiKey,omitempty"`             // The API key of router APIs using key authentication
+	APISecret          string `json:"apiSecret,omitempty"`          // The API secret of router APIs using key authentication
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // Accept self-signed router API certificates
+}
+
+type IPSources struct {
+	Mode    string     `json:"mode,omitempty"`    // fallback (the default) or quorum
+	Quorum  int        `json:"quorum,omitempty"`  // The number of sources that must agree on an address in quorum mode
+	Timeout string     `json:"timeout,omitempty"` // A duration string bounding the parallel quorum mode lookups
+	Sources []IPSource `json:"sources,omitempty"`
+}
+
+type IPSource struct {
+	SourceType  string               `json:"sourceType"`
+	Timeout     string               `json:"timeout,omitempty"`     // A duration string bounding the source lookup
+	Router      *RouterConfiguration `json:"router,omitempty"`      // Router sources, defaults to the top level router section
+	Preset      string               `json:"preset,omitempty"`      // HTTP and DNS sources, a built-in preset name
+	Url         string               `json:"url,omitempty"`         // HTTP and UPnP sources, the echo service or device url
+	Family      string               `json:"family,omitempty"`      // ipv4 or ipv6, the address family of the source
+	ForceFamily bool                 `json:"forceFamily,omitempty"` // HTTP sources, dial the url over the family only
+	Extractor   string               `json:"extractor,omitempty"`   // HTTP sources, text (the default), json or regex
+	Expression  string               `json:"expression,omitempty"`  // HTTP sources, a JSON path ($.ip) or a regex
+	Resolvers   []string             `json:"resolvers,omitempty"`   // DNS sources, resolver addresses overriding the preset
+	Servers     []string             `json:"servers,omitempty"`     // STUN sources, STUN server host:port addresses
+	Address     string               `json:"address,omitempty"`     // UPnP SSDP search, NATPMP gateway or SNMP agent address
+	Protocol    string               `json:"protocol,omitempty"`    // NATPMP sources, natpmp, pcp or empty to try both
+	Interface   string               `json:"interface,omitempty"`   // Interface and SNMP sources, the interface name
+	Prefer      []string             `json:"prefer,omitempty"`      // Interface sources, CIDR ranges selected first
+	Exclude     []string             `json:"exclude,omitempty"`     // Interface sources, CIDR ranges never selected
+	Command     string               `json:"command,omitempty"`     // Command sources, the command to run
+	Args        []string             `json:"args,omitempty"`        // Command sources, the command arguments
+	Env         []string             `json:"env,omitempty"`         // Command sources, additional KEY=value variables
+	Format      string               `json:"format,omitempty"`      // Command sources, text (the default) or json output
+	SNMP        *SNMPConfiguration   `json:"snmp,omitempty"`        // SNMP sources, the SNMP version and credentials
+}
+
+type SNMPConfiguration struct {
+	Version      string `json:"version,omitempty"`      // 2c (the default) or 3
+	Community    string `json:"community,omitempty"`    // SNMP v2c, the community, public when empty
+	Username     string `json:"userName,omitempty"`     // SNMP v3, the USM user name
+	AuthProtocol string `json:"authProtocol,omitempty"` // SNMP v3, MD5 or SHA (the default)
+	AuthPassword string `json:"authPassword,omitempty"` // SNMP v3, the authentication password
+	PrivProtocol string `json:"privProtocol,omitempty"` // SNMP v3, DES or AES (the default)
+	PrivPassword string `json:"privPassword,omitempty"` // SNMP v3, the privacy password
+}
+
+type AddressFamily struct {
+	Mode         string     `json:"mode,omitempty"`         // required, optional or disabled
+	IPSources    *IPSources `json:"ipSources,omitempty"`    // A family specific source chain, the shared ipSources when empty
+	PrefixLength int        `json:"prefixLength,omitempty"` // IPv6, the prefix length derived from the address, 64 by default
+}
+
+type ChangeValidation struct {
+	AllowNonGlobal   bool   `json:"allowNonGlobal,omitempty"`   // Publish private, CGNAT and other non global addresses
+	HoldDownCount    int    `json:"holdDownCount,omitempty"`    // The consecutive readings a changed address must be seen for
+	HoldDownDuration string `json:"holdDownDuration,omitempty"` // A duration string a changed address must be seen for
+	NotifySuppressed bool   `json:"notifySuppressed,omitempty"` // Send notifications about changes that were not published
+}
+
+type NATDetection struct {
+	Enabled    bool      `json:"enabled"`
+	EchoSource *IPSource `json:"echoSource,omitempty"` // The external echo source, the ipify-v4 HTTP preset by default
+	Publish    string    `json:"publish,omitempty"`    // router (the default) or external, the IPv4 published behind NAT
+}
+
+type AddressEvents struct {
+	Enabled    bool     `json:"enabled"`
+	Interfaces []string `json:"interfaces,omitempty"` // The interfaces whose changes trigger an update, all when empty
+	Debounce   string   `json:"debounce,omitempty"`   // A duration string of quiet that ends a burst of changes, 5s by default
 }
 
 type ServiceConfiguration struct {
@@ -47,29 +132,170 @@ type ServiceConfiguration struct {
 	RecordName   string `json:"recordname,omitempty"`
 	Port         int    `json:"port,omitempty"`
 	TTL          int    `json:"ttl,omitempty"`
+	RemoteUpdate bool   `json:"remoteUpdate,omitempty"` // updated by DynDNS2 server mode clients rather than the local ticker
+	IPv6Suffix   string `json:"ipv6Suffix,omitempty"`   // An interface ID such as ::10 combined with the IPv6 prefix
+	MacAddress   string `json:"macAddress,omitempty"`   // A MAC address the EUI-64 interface ID is derived from
 }
 
 type Notifications struct {
-	SipgateSMS SipgateSMS `json:"sipgateSMS,omitempty"`
-	Email      Email      `json:"email,omitempty"`
+	SipgateSMS       SipgateSMS                 `json:"sipgateSMS,omitempty"`
+	Email            Email                      `json:"email,omitempty"`
+	Webhooks         []Webhook                  `json:"webhooks,omitempty"`
+	Slack            Slack                      `json:"slack,omitempty"`
+	Discord          Discord                    `json:"discord,omitempty"`
+	Teams            Teams                      `json:"teams,omitempty"`
+	Mattermost       Mattermost                 `json:"mattermost,omitempty"`
+	Telegram         Telegram                   `json:"telegram,omitempty"`
+	Ntfy             Ntfy                       `json:"ntfy,omitempty"`
+	Gotify           Gotify                     `json:"gotify,omitempty"`
+	Pushover         Pushover                   `json:"pushover,omitempty"`
+	Matrix           Matrix                     `json:"matrix,omitempty"`
+	MQTT             MQTT                       `json:"mqtt,omitempty"`
+	FailureThreshold int                        `json:"failureThreshold,omitempty"` // The consecutive failed runs before a failure is notified
+	Cooldown         string                     `json:"cooldown,omitempty"`         // A duration string, the minimum time between failure alerts
+	Locale           string                     `json:"locale,omitempty"`           // The language of the built-in messages: en (the default), de, fr or es
+	Templates        map[string]MessageTemplate `json:"templates,omitempty"`        // Message templates of all notifiers keyed by event type or default
+}
+
+type MessageTemplate struct {
+	Subject string `json:"subject,omitempty"` // A text/template over the event, the built-in subject when empty
+	Body    string `json:"body,omitempty"`    // A text/template over the event, the built-in message when empty
+	Html    string `json:"html,omitempty"`    // An html/template over the event, the html body of email notifications
 }
 
 type SipgateSMS struct {
-	Enabled   bool   `json:"enabled"`
-	TokenId   string `json:"tokenId,omitempty"`
-	Token     string `json:"token,omitempty"`
-	SmsId     string `json:"smsId,omitempty"`
-	Recipient string `json:"recipient,omitempty"`
+	Enabled   bool                       `json:"enabled"`
+	TokenId   string                     `json:"tokenId,omitempty"`
+	Token     string                     `json:"token,omitempty"`
+	SmsId     string                     `json:"smsId,omitempty"`
+	Recipient string                     `json:"recipient,omitempty"`
+	Events    []string                   `json:"events,omitempty"`    // The event types sent, all event types when empty
+	Templates map[string]MessageTemplate `json:"templates,omitempty"` // Message templates keyed by event type or default
+}
+
+type Webhook struct {
+	Enabled         bool                       `json:"enabled"`
+	Url             string                     `json:"url,omitempty"`
+	Method          string                     `json:"method,omitempty"`          // POST by default
+	Headers         map[string]string          `json:"headers,omitempty"`         // Additional request headers
+	Body            string                     `json:"body,omitempty"`            // A text/template over the event, its json by default
+	Secret          string                     `json:"secret,omitempty"`          // The HMAC-SHA256 request signing key
+	SignatureHeader string                     `json:"signatureHeader,omitempty"` // The signature header, X-Signature-256 by default
+	Retries         int                        `json:"retries,omitempty"`         // The retries of a failed request
+	RetryDelay      string                     `json:"retryDelay,omitempty"`      // A duration string doubled on each retry, 2s by default
+	Events          []string                   `json:"events,omitempty"`          // The event types sent, all event types when empty
+	Templates       map[string]MessageTemplate `json:"templates,omitempty"`       // Message templates keyed by event type or default
+}
+
+type Slack struct {
+	Enabled    bool                       `json:"enabled"`
+	WebhookUrl string                     `json:"webhookUrl,omitempty"` // The incoming webhook url
+	Channel    string                     `json:"channel,omitempty"`    // Overrides the channel of the incoming webhook
+	Username   string                     `json:"username,omitempty"`   // Overrides the username of the incoming webhook
+	Events     []string                   `json:"events,omitempty"`     // The event types sent, all event types when empty
+	Templates  map[string]MessageTemplate `json:"templates,omitempty"`  // Message templates keyed by event type or default
+}
+
+type Discord struct {
+	Enabled    bool                       `json:"enabled"`
+	WebhookUrl string                     `json:"webhookUrl,omitempty"` // The channel webhook url
+	Username   string                     `json:"username,omitempty"`   // Overrides the username of the webhook
+	Events     []string                   `json:"events,omitempty"`     // The event types sent, all event types when empty
+	Templates  map[string]MessageTemplate `json:"templates,omitempty"`  // Message templates keyed by event type or default
+}
+
+type Teams struct {
+	Enabled    bool                       `json:"enabled"`
+	WebhookUrl string                     `json:"webhookUrl,omitempty"` // The incoming webhook or workflow url
+	Events     []string                   `json:"events,omitempty"`     // The event types sent, all event types when empty
+	Templates  map[string]MessageTemplate `json:"templates,omitempty"`  // Message templates keyed by event type or default
+}
+
+type Mattermost struct {
+	Enabled    bool                       `json:"enabled"`
+	WebhookUrl string                     `json:"webhookUrl,omitempty"` // The incoming webhook url
+	Channel    string                     `json:"channel,omitempty"`    // Overrides the channel of the incoming webhook
+	Username   string                     `json:"username,omitempty"`   // Overrides the username of the incoming webhook
+	Events     []string                   `json:"events,omitempty"`     // The event types sent, all event types when empty
+	Templates  map[string]MessageTemplate `json:"templates,omitempty"`  // Message templates keyed by event type or default
+}
+
+type Telegram struct {
+	Enabled   bool                       `json:"enabled"`
+	BaseUrl   string                     `json:"baseUrl,omitempty"`   // The Bot API url, https://api.telegram.org by default
+	BotToken  string                     `json:"botToken,omitempty"`  // The bot token issued by @BotFather
+	ChatId    string                     `json:"chatId,omitempty"`    // The chat id or @channelusername to send to
+	Events    []string                   `json:"events,omitempty"`    // The event types sent, all event types when empty
+	Templates map[string]MessageTemplate `json:"templates,omitempty"` // Message templates keyed by event type or default
+}
+
+type Ntfy struct {
+	Enabled   bool                       `json:"enabled"`
+	BaseUrl   string                     `json:"baseUrl,omitempty"`   // The ntfy server url, https://ntfy.sh by default
+	Topic     string                     `json:"topic,omitempty"`     // The topic to publish to
+	Token     string                     `json:"token,omitempty"`     // An access token, used instead of the username and password
+	Username  string                     `json:"username,omitempty"`  // The username of protected topics
+	Password  string                     `json:"password,omitempty"`  // The password of protected topics
+	Priority  int                        `json:"priority,omitempty"`  // A fixed priority from 1 to 5, mapped from the event severity when 0
+	Events    []string                   `json:"events,omitempty"`    // The event types sent, all event types when empty
+	Templates map[string]MessageTemplate `json:"templates,omitempty"` // Message templates keyed by event type or default
+}
+
+type Gotify struct {
+	Enabled   bool                       `json:"enabled"`
+	BaseUrl   string                     `json:"baseUrl,omitempty"`   // The Gotify server url
+	Token     string                     `json:"token,omitempty"`     // The application token
+	Priority  int                        `json:"priority,omitempty"`  // A fixed priority from 1 to 10, mapped from the event severity when 0
+	Events    []string                   `json:"events,omitempty"`    // The event types sent, all event types when empty
+	Templates map[string]MessageTemplate `json:"templates,omitempty"` // Message templates keyed by event type or default
+}
+
+type Pushover struct {
+	Enabled   bool                       `json:"enabled"`
+	BaseUrl   string                     `json:"baseUrl,omitempty"`   // The Pushover API url, https://api.pushover.net by default
+	Token     string                     `json:"token,omitempty"`     // The application API token
+	UserKey   string                     `json:"userKey,omitempty"`   // The user or group key
+	Device    string                     `json:"device,omitempty"`    // The device to send to, all devices when empty
+	Events    []string                   `json:"events,omitempty"`    // The event types sent, all event types when empty
+	Templates map[string]MessageTemplate `json:"templates,omitempty"` // Message templates keyed by event type or default
+}
+
+type Matrix struct {
+	Enabled     bool                       `json:"enabled"`
+	BaseUrl     string                     `json:"baseUrl,omitempty"`     // The homeserver url such as https://matrix.example.com
+	AccessToken string                     `json:"accessToken,omitempty"` // The access token of the sending user
+	RoomId      string                     `json:"roomId,omitempty"`      // The room id such as !abcdefg:example.com
+	Events      []string                   `json:"events,omitempty"`      // The event types sent, all event types when empty
+	Templates   map[string]MessageTemplate `json:"templates,omitempty"`   // Message templates keyed by event type or default
+}
+
+type MQTT struct {
+	Enabled            bool                       `json:"enabled"`
+	Broker             string                     `json:"broker,omitempty"`             // tcp://host:1883 or tls://host:8883
+	ClientId           string                     `json:"clientId,omitempty"`           // go-ddns-client-<hostname> by default
+	Username           string                     `json:"username,omitempty"`           // The broker username
+	Password           string                     `json:"password,omitempty"`           // The broker password
+	TopicPrefix        string                     `json:"topicPrefix,omitempty"`        // go-ddns-client/<hostname> by default
+	Discovery          bool                       `json:"discovery,omitempty"`          // Publish Home Assistant MQTT discovery configs
+	DiscoveryPrefix    string                     `json:"discoveryPrefix,omitempty"`    // homeassistant by default
+	InsecureSkipVerify bool                       `json:"insecureSkipVerify,omitempty"` // Accept self-signed broker certificates
+	Events             []string                   `json:"events,omitempty"`             // The event types sent, all event types when empty
+	Templates          map[string]MessageTemplate `json:"templates,omitempty"`          // Message templates keyed by event type or default
 }
 
 type Email struct {
-	IsEnabled    bool   `json:"enabled"`
-	Username     string `json:"username,omitempty"`
-	Password     string `json:"password,omitempty"`
-	From         EmailAddress
-	Recipients   []EmailAddress
-	SmtpServer   string `json:"smtpServer,omitempty"`
-	SecurityType string `json:"securityType,omitempty"` /*SSL or TLS*/
+	IsEnabled          bool   `json:"enabled"`
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	From               EmailAddress
+	Recipients         []EmailAddress
+	SmtpServer         string                     `json:"smtpServer,omitempty"`
+	SecurityType       string                     `json:"securityType,omitempty"`       /*SSL, TLS, OptionalTLS or None*/
+	AuthType           string                     `json:"authType,omitempty"`           // PLAIN (the default), LOGIN, CRAM-MD5 or XOAUTH2 with the access token as password
+	CAFile             string                     `json:"caFile,omitempty"`             // A PEM file of the CA certificates trusted in place of the system roots
+	InsecureSkipVerify bool                       `json:"insecureSkipVerify,omitempty"` // Accept any SMTP server certificate
+	Events             []string                   `json:"events,omitempty"`             // The event types sent, all event types when empty
+	Templates          map[string]MessageTemplate `json:"templates,omitempty"`          // Message templates keyed by event type or default
 }
 
 type EmailAddress struct {
@@ -77,6 +303,17 @@ type EmailAddress struct {
 	Address string `json:"address,omitempty"`
 }
 
+type DynDNSServer struct {
+	Enabled bool         `json:"enabled"`
+	Users   []DynDNSUser `json:"users,omitempty"`
+}
+
+type DynDNSUser struct {
+	Username  string   `json:"username"`
+	Password  string   `json:"password"`
+	Hostnames []string `json:"hostnames,omitempty"` // the hostnames this user may update, any configured hostname when empty
+}
+
 var (
 	cfg *Configuration //the package level cfg
 )
@@ -97,6 +334,10 @@ func unmarshalConfigFile(cfgFilePath string) bool {
 			//broken json in config file
 			log.Panic(err)
 		}
+		if err = cfg.validate(); err != nil {
+			//unsupported values in config file
+			log.Panic(err)
+		}
 
 		cfg.FileInfo, err = os.Stat(cfgFilePath)
 		if err != nil {
@@ -107,26 +348,43 @@ func unmarshalConfigFile(cfgFilePath string) bool {
 	return loaded
 }
 
+//validate checks the configured values that are otherwise only read once the condition they apply to occurs, so an
+//unsupported value fails the load rather than a later update run
+func (appData *Configuration) validate() error {
+	if appData.NATDetection != nil {
+		switch appData.NATDetection.Publish {
+		case "", NATPublishRouter, NATPublishExternal:
+		default:
+			return fmt.Errorf("unsupported natDetection publish value %s, %s or %s is expected",
+				appData.NATDetection.Publish, NATPublishRouter, NATPublishExternal)
+		}
+	}
+	return nil
+}
+
 //watchConfigFile implements a simple file watcher on the cfg.cfgFilePath file to enable reload on change detection
 func (appData *Configuration) watchConfigFile() {
 	for {
+		//the file is compared under Mu so the writes of Save, which update FileInfo, are not seen as a change
+		appData.Mu.Lock()
 		nowFileInfo, err := os.Stat(appData.CfgFilePath)
 		if err != nil {
 			log.Panic(err)
 		}
 
+		reloaded := false
 		if nowFileInfo.Size() != appData.FileInfo.Size() || nowFileInfo.ModTime() != appData.FileInfo.ModTime() {
 			//refresh on change
-			appData.Mu.Lock()
-			reloaded := unmarshalConfigFile(appData.CfgFilePath)
-			appData.Mu.Unlock()
-
+			reloaded = unmarshalConfigFile(appData.CfgFilePath)
 			if reloaded {
 				appData.FileInfo = nowFileInfo
-				log.Printf("A change was detected on %s, the file was reloaded", appData.CfgFilePath)
-				appData.Reloaded <- true //channel comm
 			}
+		}
+		appData.Mu.Unlock()
 
+		if reloaded {
+			log.Printf("A change was detected on %s, the file was reloaded", appData.CfgFilePath)
+			appData.Reloaded <- true //channel comm
 		}
 		time.Sleep(1 * time.Second)
 	}
@@ -152,6 +410,12 @@ func (appData *Configuration) handleConfigReload(ticker *time.Ticker) {
 			log.Printf("**Ticker interval changed from %s to %s**", appData.LastUpdateInterval, appData.UpdateInterval)
 			appData.LastUpdateInterval = appData.UpdateInterval
 		}
+		appData.Mu.Lock()
+		onReload := appData.OnReload
+		appData.Mu.Unlock()
+		if onReload != nil {
+			onReload()
+		}
 	}
 }
 
@@ -190,10 +454,17 @@ func Load(cfgFilePath string) (*Configuration, *time.Ticker) {
 
 //IPAddressesChanged returns an indicator that describes if either the supplied ipv4 or ipv6 have changed
 func (appData *Configuration) IPAddressesChanged(ipv4 net.IP, ipv6 net.IP) bool {
-	return appData.LastIPv4 == nil ||
-		appData.LastIPv6 == nil ||
-		(appData.LastIPv4 != nil && !ipv4.Equal(appData.LastIPv4)) ||
-		(appData.LastIPv6 != nil && !ipv6.Equal(appData.LastIPv6))
+	//a nil address equals a nil last address, a family that is disabled or missing is not a change on every run
+	return !ipv4.Equal(appData.LastIPv4) || !ipv6.Equal(appData.LastIPv6)
+}
+
+//IPv6PrefixChanged returns an indicator that describes if the supplied IPv6 prefix differs from the last prefix in use
+func (appData *Configuration) IPv6PrefixChanged(prefix *net.IPNet) bool {
+	prefixStr := ""
+	if prefix != nil {
+		prefixStr = prefix.String()
+	}
+	return prefixStr != appData.LastIPv6Prefix
 }
 
 // Save persists the serviceConfig.json file to the file system with the supplied currentPublicIpAddr
@@ -211,6 +482,24 @@ func (appData *Configuration) Save(ipv4 net.IP, ipv6 net.IP) error {
 		return err
 	}
 
+	//the file is written and its FileInfo refreshed under Mu so the config file watcher does not reload the changes
+	//made by the process itself
+	appData.Mu.Lock()
+	defer appData.Mu.Unlock()
+	if err = appData.writeConfigFile(jsonByteArr); err != nil {
+		return err
+	}
+	fileInfo, err := os.Stat(appData.CfgFilePath)
+	if err != nil {
+		return err
+	}
+	appData.FileInfo = fileInfo
+
+	return nil
+}
+
+//writeConfigFile replaces the content of the config file with the supplied json
+func (appData *Configuration) writeConfigFile(jsonByteArr []byte) error {
 	configFile, err := os.OpenFile(appData.CfgFilePath, os.O_RDWR|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
@@ -223,23 +512,22 @@ func (appData *Configuration) Save(ipv4 net.IP, ipv6 net.IP) error {
 		}
 	}()
 
-	if _, err = configFile.Write(jsonByteArr); err != nil {
-		return err
-	}
-
-	return nil
+	_, err = configFile.Write(jsonByteArr)
+	return err
 }
 
-//GetDomainsStr returns a comma separated string of all configured target domain names
+//GetDomainsStr returns a comma separated string of all configured target domain names that are updated by the
+//local ticker
 func (appData *Configuration) GetDomainsStr() (string, error) {
 	var err error
 	var builder strings.Builder
-	for index, svc := range cfg.Services {
+	services := appData.GetLocalServices()
+	for index, svc := range services {
 		_, err = fmt.Fprintf(&builder, "%s", svc.TargetDomain)
 		if err != nil {
 			return "", err
 		}
-		if index < (len(cfg.Services) - 1) {
+		if index < (len(services) - 1) {
 			_, err = fmt.Fprint(&builder, ", ")
 			if err != nil {
 				return "", err
@@ -248,3 +536,44 @@ func (appData *Configuration) GetDomainsStr() (string, error) {
 	}
 	return builder.String(), err
 }
+
+//GetLocalServices returns the configured services that are updated by the local ticker, excluding those that are
+//updated by remote DynDNS2 clients through the server mode
+func (appData *Configuration) GetLocalServices() []ServiceConfiguration {
+	var services []ServiceConfiguration
+	for _, svc := range appData.Services {
+		if !svc.RemoteUpdate {
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+//MayUpdate returns an indicator that describes if the DynDNS2 user is permitted to update the supplied hostname
+func (user *DynDNSUser) MayUpdate(hostname string) bool {
+	if len(user.Hostnames) == 0 {
+		return true
+	}
+	for _, permitted := range user.Hostnames {
+		if strings.EqualFold(permitted, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+//UsesIPv6Prefix returns an indicator that describes if the IPv6 address of the service is built from the IPv6 prefix
+//and an interface ID rather than being the public IPv6 address itself
+func (serviceConfig *ServiceConfiguration) UsesIPv6Prefix() bool {
+	return serviceConfig.IPv6Suffix != "" || serviceConfig.MacAddress != ""
+}
+
+//UsesIPv6Prefix returns an indicator that describes if any local service builds its IPv6 address from the IPv6 prefix
+func (appData *Configuration) UsesIPv6Prefix() bool {
+	for _, svc := range appData.GetLocalServices() {
+		if svc.UsesIPv6Prefix() {
+			return true
+		}
+	}
+	return false
+}
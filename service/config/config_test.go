@@ -0,0 +1,27 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		natDetection *NATDetection
+		expected     string
+	}{
+		{nil, ""},
+		{&NATDetection{Enabled: true}, ""},
+		{&NATDetection{Enabled: true, Publish: NATPublishRouter}, ""},
+		{&NATDetection{Enabled: true, Publish: NATPublishExternal}, ""},
+		{&NATDetection{Enabled: true, Publish: "External"},
+			"unsupported natDetection publish value External, router or external is expected"},
+		{&NATDetection{Publish: "wan"}, "unsupported natDetection publish value wan, router or external is expected"},
+	}
+	for _, test := range tests {
+		err := (&Configuration{NATDetection: test.natDetection}).validate()
+		if test.expected == "" && err != nil {
+			t.Errorf("expected the natDetection %+v to be valid, got %v", test.natDetection, err)
+		}
+		if test.expected != "" && (err == nil || err.Error() != test.expected) {
+			t.Errorf("expected the error %q, got %v", test.expected, err)
+		}
+	}
+}
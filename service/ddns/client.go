@@ -26,6 +26,17 @@ func (client Client) LogIPAddressUpdate(args ...string) {
 		client.ServiceConfig.ServiceType, client.ServiceConfig.TargetDomain, strings.Join(args, ","))
 }
 
+// FormatIPAddresses returns the non nil supplied addresses separated by " / " for use in log and error messages
+func FormatIPAddresses(ips ...net.IP) string {
+	var addresses []string
+	for _, ip := range ips {
+		if ip != nil {
+			addresses = append(addresses, ip.String())
+		}
+	}
+	return strings.Join(addresses, " / ")
+}
+
 // PerformHttpRequest performs a HTTP request and returns the status code and the response
 func PerformHttpRequest(
 	method string,
@@ -164,9 +164,13 @@ func (client CloudFlareClient) applyIpAddressUpdate(dnsRecord *DnsRecord, ipv4,
 		var ipToUse *net.IP
 		switch dnsRecord.Type {
 		case "A":
-			ipToUse = &ipv4
+			if ipv4 != nil {
+				ipToUse = &ipv4
+			}
 		case "AAAA":
-			ipToUse = &ipv6
+			if ipv6 != nil {
+				ipToUse = &ipv6
+			}
 		}
 		if ipToUse != nil {
 			headers := client.getRequestHeaders()
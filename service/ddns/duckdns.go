@@ -2,6 +2,7 @@ package ddns
 
 import (
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 )
@@ -17,14 +18,24 @@ OK
 */
 type DuckDNSClient Client
 
-// UpdateIPAddresses performs the dynamic dns IP address update operation
+// UpdateIPAddresses performs the dynamic dns IP address update operation. DuckDNS publishes the detected address of the
+// caller when no ip is sent, so without an IPv4 address, a disabled or missing family, the update is skipped
 func (client DuckDNSClient) UpdateIPAddresses(ipv4, ipv6 net.IP) error {
+	if ipv4 == nil {
+		log.Printf("No IPv4 address, the %s update for domain %s is skipped as DuckDNS would publish the detected "+
+			"address of this host", client.ServiceConfig.ServiceType, client.ServiceConfig.TargetDomain)
+		return nil
+	}
+
 	dynDnsIpUpdateUrl := fmt.Sprintf(
-		"https://www.duckdns.org/update?domains=%s&token=%s&ip=%s&ipv6=%s",
+		"https://www.duckdns.org/update?domains=%s&token=%s&ip=%s",
 		client.ServiceConfig.TargetDomain,
 		client.ServiceConfig.Token,
-		ipv4,
-		ipv6)
+		ipv4)
+	//a missing address is left out rather than sent as <nil>
+	if ipv6 != nil {
+		dynDnsIpUpdateUrl += "&ipv6=" + ipv6.String()
+	}
 
 	_, responseBytes, err := PerformHttpRequest(
 		http.MethodGet,
@@ -41,8 +52,8 @@ func (client DuckDNSClient) UpdateIPAddresses(ipv4, ipv6 net.IP) error {
 
 	responseStr := string(responseBytes)
 	if responseStr != "OK" {
-		return fmt.Errorf("the DuckDNS IP address update to %s / %s for domain %s failed: '%s'",
-			ipv4, ipv6, client.ServiceConfig.TargetDomain, responseStr)
+		return fmt.Errorf("the DuckDNS IP address update to %s for domain %s failed: '%s'",
+			FormatIPAddresses(ipv4, ipv6), client.ServiceConfig.TargetDomain, responseStr)
 	}
 
 	Client(client).LogIPAddressUpdate()
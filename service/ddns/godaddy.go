@@ -3,6 +3,7 @@ package ddns
 import (
 	"bytes"
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 )
@@ -28,6 +29,12 @@ type GoDaddyClient Client
 
 // UpdateIPAddresses performs the dynamic dns IP address update operation
 func (client GoDaddyClient) UpdateIPAddresses(ipv4, ipv6 net.IP) error {
+	if ipv4 == nil {
+		log.Printf("No IPv4 address, the %s A record update for domain %s is skipped",
+			client.ServiceConfig.ServiceType, client.ServiceConfig.TargetDomain)
+		return nil
+	}
+
 	dynDnsIpUpdateUrl := fmt.Sprintf(
 		"https://api.godaddy.com/v1/domains/%s/records/A/%s",
 		client.ServiceConfig.TargetDomain,
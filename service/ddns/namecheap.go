@@ -3,6 +3,7 @@ package ddns
 import (
 	"encoding/xml"
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 )
@@ -60,6 +61,12 @@ type NamecheapXmlResponse struct {
 
 // UpdateIPAddresses performs the dynamic dns IP address update operation
 func (client NamecheapClient) UpdateIPAddresses(ipv4, ipv6 net.IP) error {
+	if ipv4 == nil {
+		log.Printf("No IPv4 address, the %s update for domain %s is skipped",
+			client.ServiceConfig.ServiceType, client.ServiceConfig.TargetDomain)
+		return nil
+	}
+
 	dynDnsIpUpdateUrl := fmt.Sprintf(
 		"https://dynamicdns.park-your-domain.com/update?host=@&domain=%s&password=%s&ip=%s",
 		client.ServiceConfig.TargetDomain,
@@ -2,6 +2,7 @@ package ddns
 
 import (
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"strings"
@@ -18,13 +19,26 @@ nochg 192.0.2.25
 */
 type NoIPClient Client
 
-// UpdateIPAddresses performs the dynamic dns IP address update operation
+// UpdateIPAddresses performs the dynamic dns IP address update operation. No-IP publishes the detected address of the
+// caller when neither myip nor myipv6 is sent, so without an IPv4 address only myipv6 is sent and without any address
+// the update is skipped
 func (client NoIPClient) UpdateIPAddresses(ipv4, ipv6 net.IP) error {
+	if ipv4 == nil && ipv6 == nil {
+		log.Printf("No IP address, the %s update for domain %s is skipped",
+			client.ServiceConfig.ServiceType, client.ServiceConfig.TargetDomain)
+		return nil
+	}
+
 	dynDnsIpUpdateUrl := fmt.Sprintf(
-		"https://dynupdate.no-ip.com/nic/update?hostname=%s&myip=%s&myipv6=%s",
-		client.ServiceConfig.TargetDomain,
-		ipv4,
-		ipv6)
+		"https://dynupdate.no-ip.com/nic/update?hostname=%s",
+		client.ServiceConfig.TargetDomain)
+	//a missing address is left out rather than sent as <nil>
+	if ipv4 != nil {
+		dynDnsIpUpdateUrl += "&myip=" + ipv4.String()
+	}
+	if ipv6 != nil {
+		dynDnsIpUpdateUrl += "&myipv6=" + ipv6.String()
+	}
 
 	_, responseBytes, err := PerformHttpRequest(
 		http.MethodGet,
@@ -41,8 +55,8 @@ func (client NoIPClient) UpdateIPAddresses(ipv4, ipv6 net.IP) error {
 
 	responseStr := string(responseBytes)
 	if !strings.HasPrefix(responseStr, "nochg") && !strings.HasPrefix(responseStr, "good") {
-		return fmt.Errorf("the noIP IP address update to %s / %s for domain %s failed: '%s'",
-			ipv4, ipv6, client.ServiceConfig.TargetDomain, responseStr)
+		return fmt.Errorf("the noIP IP address update to %s for domain %s failed: '%s'",
+			FormatIPAddresses(ipv4, ipv6), client.ServiceConfig.TargetDomain, responseStr)
 	}
 
 	Client(client).LogIPAddressUpdate()
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"github.com/bebo-dot-dev/go-ddns-client/service/ddns"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//dynDNSTestClient is a stub dynamic dns client recording the addresses it is updated with
+type dynDNSTestClient struct {
+	target  string
+	err     error
+	updates *[]string
+}
+
+//UpdateIPAddresses records the update of the stub client
+func (client dynDNSTestClient) UpdateIPAddresses(ipv4, ipv6 net.IP) error {
+	*client.updates = append(*client.updates, client.target+" "+formatDynDNSAddresses(ipv4, ipv6))
+	return client.err
+}
+
+//newDynDNSTestConfig returns a configuration with the DynDNS2 server enabled for the user device, permitted to update
+//remote.example.com and local.example.com, the updates applied by the stub clients are recorded in updates
+func newDynDNSTestConfig(t *testing.T, updateErr error, updates *[]string) *config.Configuration {
+	dynDNSRemoteAddresses = remoteAddresses{
+		addresses: make(map[string][2]net.IP),
+		locks:     make(map[string]*sync.Mutex),
+	}
+	newDynDNSClient = func(serviceConfig *config.ServiceConfiguration) ddns.IDynamicDnsClient {
+		return dynDNSTestClient{target: serviceConfig.ServiceType, err: updateErr, updates: updates}
+	}
+	t.Cleanup(func() {
+		newDynDNSClient = getDDNSClient
+	})
+
+	return &config.Configuration{
+		Mu: &sync.Mutex{},
+		DynDNSServer: &config.DynDNSServer{
+			Enabled: true,
+			Users: []config.DynDNSUser{{
+				Username:  "device",
+				Password:  "secret",
+				Hostnames: []string{"remote.example.com", "local.example.com"},
+			}},
+		},
+		Services: []config.ServiceConfiguration{
+			{ServiceType: "DuckDNS", TargetDomain: "remote.example.com", RemoteUpdate: true},
+			{ServiceType: "NoIP", TargetDomain: "Remote.Example.com", RemoteUpdate: true},
+			{ServiceType: "Namecheap", TargetDomain: "local.example.com"},
+			{ServiceType: "DuckDNS", TargetDomain: "other.example.com", RemoteUpdate: true},
+		},
+	}
+}
+
+func TestDynDNSUpdateHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		query      string
+		last       [2]net.IP
+		updateErr  error
+		statusCode int
+		response   string
+		updates    []string
+	}{
+		{
+			name:       "good",
+			password:   "secret",
+			query:      "hostname=remote.example.com&myip=81.2.69.142",
+			statusCode: http.StatusOK,
+			response:   "good 81.2.69.142\n",
+			updates:    []string{"DuckDNS 81.2.69.142", "NoIP 81.2.69.142"},
+		},
+		{
+			name:       "good keeps the last address of the other family",
+			password:   "secret",
+			query:      "hostname=remote.example.com&myipv6=2a00:1450:4009:81d::200e",
+			last:       [2]net.IP{net.ParseIP("81.2.69.142").To4(), nil},
+			statusCode: http.StatusOK,
+			response:   "good 81.2.69.142,2a00:1450:4009:81d::200e\n",
+			updates: []string{
+				"DuckDNS 81.2.69.142,2a00:1450:4009:81d::200e",
+				"NoIP 81.2.69.142,2a00:1450:4009:81d::200e",
+			},
+		},
+		{
+			name:       "nochg",
+			password:   "secret",
+			query:      "hostname=remote.example.com&myip=81.2.69.142",
+			last:       [2]net.IP{net.ParseIP("81.2.69.142").To4(), nil},
+			statusCode: http.StatusOK,
+			response:   "nochg 81.2.69.142\n",
+		},
+		{
+			name:       "badauth",
+			password:   "wrong",
+			query:      "hostname=remote.example.com&myip=81.2.69.142",
+			statusCode: http.StatusUnauthorized,
+			response:   "badauth\n",
+		},
+		{
+			name:       "notfqdn without a hostname",
+			password:   "secret",
+			query:      "myip=81.2.69.142",
+			statusCode: http.StatusOK,
+			response:   "notfqdn\n",
+		},
+		{
+			name:       "notfqdn",
+			password:   "secret",
+			query:      "hostname=remote&myip=81.2.69.142",
+			statusCode: http.StatusOK,
+			response:   "notfqdn\n",
+		},
+		{
+			name:       "numhost",
+			password:   "secret",
+			query:      "hostname=" + strings.Repeat("remote.example.com,", maxDynDNSHostnames+1) + "&myip=81.2.69.142",
+			statusCode: http.StatusOK,
+			response:   "numhost\n",
+		},
+		{
+			name:       "nohost for a hostname the user may not update",
+			password:   "secret",
+			query:      "hostname=other.example.com&myip=81.2.69.142",
+			statusCode: http.StatusOK,
+			response:   "nohost\n",
+		},
+		{
+			name:       "nohost for a service updated by the local ticker",
+			password:   "secret",
+			query:      "hostname=local.example.com&myip=81.2.69.142",
+			statusCode: http.StatusOK,
+			response:   "nohost\n",
+		},
+		{
+			name:       "malformed myip",
+			password:   "secret",
+			query:      "hostname=remote.example.com&myip=81.2.69",
+			statusCode: http.StatusOK,
+			response:   "911\n",
+		},
+		{
+			name:       "private myip",
+			password:   "secret",
+			query:      "hostname=remote.example.com&myip=192.168.1.20",
+			statusCode: http.StatusOK,
+			response:   "911\n",
+		},
+		{
+			name:       "private device address",
+			password:   "secret",
+			query:      "hostname=remote.example.com",
+			statusCode: http.StatusOK,
+			response:   "911\n",
+		},
+		{
+			name:       "dnserr",
+			password:   "secret",
+			query:      "hostname=remote.example.com&myip=81.2.69.142",
+			updateErr:  errors.New("update rejected"),
+			statusCode: http.StatusOK,
+			response:   "dnserr\n",
+			updates:    []string{"DuckDNS 81.2.69.142"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var updates []string
+			cfg := newDynDNSTestConfig(t, test.updateErr, &updates)
+			if test.last[0] != nil || test.last[1] != nil {
+				dynDNSRemoteAddresses.set("remote.example.com", test.last)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/nic/update?"+test.query, nil)
+			request.RemoteAddr = "192.168.1.20:41234"
+			request.SetBasicAuth("device", test.password)
+			recorder := httptest.NewRecorder()
+			dynDNSUpdateHandler(cfg)(recorder, request)
+
+			if recorder.Code != test.statusCode || recorder.Body.String() != test.response {
+				t.Errorf("expected %d %q, got %d %q", test.statusCode, test.response, recorder.Code, recorder.Body.String())
+			}
+			if strings.Join(updates, "|") != strings.Join(test.updates, "|") {
+				t.Errorf("expected the updates %q, got %q", test.updates, updates)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"github.com/bebo-dot-dev/go-ddns-client/service/notifications"
+	"log"
+	"sync"
+	"time"
+)
+
+// the failure keys of the failures that are not DDNS service updates
+const (
+	failureKeyIPLookup = "ipLookup"
+	failureKeySave     = "save"
+)
+
+//failureState tracks the consecutive failed runs of a DDNS service update or of the IP address lookup
+type failureState struct {
+	count     int       //the consecutive failed runs
+	notified  bool      //the failure was notified, its recovery is notified
+	lastAlert time.Time //the time the last failure of this key was notified
+}
+
+//failureTracker holds the failure state of each failure key
+var failureTracker = struct {
+	mu     sync.Mutex
+	states map[string]*failureState
+}{states: make(map[string]*failureState)}
+
+//failurePolicy is the notification threshold and cooldown of failures
+type failurePolicy struct {
+	threshold int
+	cooldown  time.Duration
+}
+
+//getFailurePolicy returns the failure policy of the configured notifications. A failure is notified on the first
+//failed run and never again until it recovers by default
+func getFailurePolicy(conf *config.Notifications) failurePolicy {
+	policy := failurePolicy{threshold: conf.FailureThreshold}
+	if policy.threshold < 1 {
+		policy.threshold = 1
+	}
+	if conf.Cooldown != "" {
+		cooldown, err := time.ParseDuration(conf.Cooldown)
+		if err != nil {
+			log.Printf("invalid notifications cooldown, failures are notified without a cooldown: %v", err)
+		}
+		policy.cooldown = cooldown
+	}
+	return policy
+}
+
+//recordFailure records a failed run of the supplied key and returns the consecutive failed runs with an indicator that
+//describes if the failure is due to be notified. A failure is notified once when it reaches the threshold, unless an
+//earlier failure of the same key was notified within the cooldown
+func recordFailure(key string, policy failurePolicy) (int, bool) {
+	failureTracker.mu.Lock()
+	defer failureTracker.mu.Unlock()
+
+	state := failureTracker.states[key]
+	if state == nil {
+		state = &failureState{}
+		failureTracker.states[key] = state
+	}
+	state.count++
+	if state.notified || state.count < policy.threshold {
+		return state.count, false
+	}
+	if !state.lastAlert.IsZero() && now().Sub(state.lastAlert) < policy.cooldown {
+		return state.count, false
+	}
+	state.notified = true
+	state.lastAlert = now()
+	return state.count, true
+}
+
+//recordSuccess records a successful run of the supplied key and returns the consecutive failed runs that preceded it
+//with an indicator that describes if the failure was notified, in which case its recovery is notified
+func recordSuccess(key string) (int, bool) {
+	failureTracker.mu.Lock()
+	defer failureTracker.mu.Unlock()
+
+	state := failureTracker.states[key]
+	if state == nil || state.count == 0 {
+		return 0, false
+	}
+	count, notified := state.count, state.notified
+	//the last alert time is kept for the cooldown of the next failure
+	state.count = 0
+	state.notified = false
+	return count, notified
+}
+
+//getServiceFailureKey returns the failure key of the update of a DDNS service
+func getServiceFailureKey(serviceConfig *config.ServiceConfiguration) string {
+	return fmt.Sprintf("service/%s/%s", serviceConfig.ServiceType, serviceConfig.TargetDomain)
+}
+
+//notifyFailure records a failed run of the supplied key and sends the failure event once it is due to be notified
+func notifyFailure(cfg *config.Configuration, key string, event *notifications.Event) {
+	count, due := recordFailure(key, getFailurePolicy(&cfg.Notifications))
+	if !due {
+		return
+	}
+	event.Failures = count
+	if err := sendNotifications(cfg, event); err != nil {
+		log.Println(err)
+	}
+}
+
+//notifyRecovery records a successful run of the supplied key and sends an update_recovered event when its failure was
+//notified
+func notifyRecovery(cfg *config.Configuration, key string, reason string) {
+	count, notified := recordSuccess(key)
+	if !notified {
+		return
+	}
+	event := &notifications.Event{Type: notifications.EventUpdateRecovered, Failures: count, Reason: reason}
+	if err := sendNotifications(cfg, event); err != nil {
+		log.Println(err)
+	}
+}
+
+//NotifyStartup sends the startup event carrying the last published addresses
+func NotifyStartup(cfg *config.Configuration) {
+	event := &notifications.Event{
+		Type: notifications.EventStartup,
+		IPv4: formatAddress(cfg.LastIPv4),
+		IPv6: formatAddress(cfg.LastIPv6),
+	}
+	if err := sendNotifications(cfg, event); err != nil {
+		log.Println(err)
+	}
+}
+
+//NotifyConfigReloaded sends the config_reloaded event
+func NotifyConfigReloaded(cfg *config.Configuration) {
+	if err := sendNotifications(cfg, &notifications.Event{Type: notifications.EventConfigReloaded}); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"testing"
+	"time"
+)
+
+//failureStep is a single failed or successful run of a failure key after the clock advanced, with the consecutive
+//failed runs and the notification indicator it is expected to return
+type failureStep struct {
+	advance time.Duration
+	failed  bool
+	count   int
+	notify  bool
+}
+
+//useFailureTestClock clears the failure state of all keys and replaces the clock with a test clock
+func useFailureTestClock(t *testing.T) func(time.Duration) {
+	failureTracker.states = make(map[string]*failureState)
+	return useTestClock(t)
+}
+
+func TestRecordFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy failurePolicy
+		steps  []failureStep
+	}{
+		{"notified on the first failure by default", failurePolicy{threshold: 1}, []failureStep{
+			{0, true, 1, true},
+			{time.Minute, true, 2, false},
+			{time.Minute, false, 2, true},
+		}},
+		{"notified once the threshold is reached", failurePolicy{threshold: 3}, []failureStep{
+			{0, true, 1, false},
+			{time.Minute, true, 2, false},
+			{time.Minute, true, 3, true},
+			{time.Minute, true, 4, false},
+			{time.Minute, false, 4, true},
+			{time.Minute, false, 0, false},
+		}},
+		{"recovered before the threshold", failurePolicy{threshold: 3}, []failureStep{
+			{0, true, 1, false},
+			{time.Minute, true, 2, false},
+			{time.Minute, false, 2, false},
+			{time.Minute, true, 1, false},
+			{time.Minute, true, 2, false},
+			{time.Minute, true, 3, true},
+		}},
+		{"success without a failure", failurePolicy{threshold: 1}, []failureStep{
+			{0, false, 0, false},
+		}},
+		{"failure within the cooldown is not notified", failurePolicy{threshold: 1, cooldown: time.Hour}, []failureStep{
+			{0, true, 1, true},
+			{time.Minute, false, 1, true},
+			{10 * time.Minute, true, 1, false},
+			{10 * time.Minute, false, 1, false},
+			{10 * time.Minute, true, 1, false},
+			{29*time.Minute - time.Second, true, 2, false},
+			{time.Second, true, 3, true},
+			{time.Minute, false, 3, true},
+		}},
+		{"threshold and cooldown", failurePolicy{threshold: 2, cooldown: 30 * time.Minute}, []failureStep{
+			{0, true, 1, false},
+			{time.Minute, true, 2, true},
+			{time.Minute, false, 2, true},
+			{time.Minute, true, 1, false},
+			{time.Minute, true, 2, false},
+			{30 * time.Minute, true, 3, true},
+		}},
+		{"no cooldown", failurePolicy{threshold: 1}, []failureStep{
+			{0, true, 1, true},
+			{time.Second, false, 1, true},
+			{time.Second, true, 1, true},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advance := useFailureTestClock(t)
+			for index, step := range test.steps {
+				advance(step.advance)
+				var count int
+				var notify bool
+				if step.failed {
+					count, notify = recordFailure("service/DuckDNS/home.example.com", test.policy)
+				} else {
+					count, notify = recordSuccess("service/DuckDNS/home.example.com")
+				}
+				if count != step.count || notify != step.notify {
+					t.Errorf("run %d: expected %d consecutive failed runs notified %t, got %d notified %t",
+						index, step.count, step.notify, count, notify)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordFailureKeysAreIndependent(t *testing.T) {
+	useFailureTestClock(t)
+	policy := failurePolicy{threshold: 1, cooldown: time.Hour}
+	if _, notify := recordFailure(failureKeyIPLookup, policy); !notify {
+		t.Error("expected the first IP address lookup failure to be notified")
+	}
+	if _, notify := recordFailure("service/DuckDNS/home.example.com", policy); !notify {
+		t.Error("expected the service failure to be notified within the cooldown of the IP address lookup failure")
+	}
+	if count, notify := recordSuccess(failureKeySave); count != 0 || notify {
+		t.Errorf("expected no recovery of the save key that never failed, got %d notified %t", count, notify)
+	}
+}
+
+func TestGetFailurePolicy(t *testing.T) {
+	tests := []struct {
+		conf     config.Notifications
+		expected failurePolicy
+	}{
+		{config.Notifications{}, failurePolicy{threshold: 1}},
+		{config.Notifications{FailureThreshold: -1}, failurePolicy{threshold: 1}},
+		{config.Notifications{FailureThreshold: 3, Cooldown: "30m"}, failurePolicy{threshold: 3, cooldown: 30 * time.Minute}},
+		{config.Notifications{FailureThreshold: 2, Cooldown: "half an hour"}, failurePolicy{threshold: 2}},
+	}
+	for _, test := range tests {
+		if policy := getFailurePolicy(&test.conf); policy != test.expected {
+			t.Errorf("expected the policy %+v of threshold %d and cooldown %q, got %+v",
+				test.expected, test.conf.FailureThreshold, test.conf.Cooldown, policy)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package ipaddress
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the ASN.1 BER tags used by SNMP
+const (
+	berInteger        byte = 0x02
+	berOctetString    byte = 0x04
+	berNull           byte = 0x05
+	berObjectID       byte = 0x06
+	berSequence       byte = 0x30
+	berIPAddress      byte = 0x40
+	berNoSuchObject   byte = 0x80
+	berNoSuchInstance byte = 0x81
+	berEndOfMibView   byte = 0x82
+)
+
+//berElement is a decoded BER tag, length and value. The content slice shares the memory of the decoded buffer
+type berElement struct {
+	tag     byte
+	content []byte
+}
+
+//berEncode returns the BER encoding of the supplied tag and content
+func berEncode(tag byte, content []byte) []byte {
+	length := len(content)
+	encoded := []byte{tag}
+	switch {
+	case length < 0x80:
+		encoded = append(encoded, byte(length))
+	case length <= 0xff:
+		encoded = append(encoded, 0x81, byte(length))
+	case length <= 0xffff:
+		encoded = append(encoded, 0x82, byte(length>>8), byte(length))
+	default:
+		encoded = append(encoded, 0x83, byte(length>>16), byte(length>>8), byte(length))
+	}
+	return append(encoded, content...)
+}
+
+//berEncodeSequence returns the BER encoding of a constructed element holding the supplied encoded elements
+func berEncodeSequence(tag byte, elements ...[]byte) []byte {
+	var content []byte
+	for _, element := range elements {
+		content = append(content, element...)
+	}
+	return berEncode(tag, content)
+}
+
+//berEncodeInteger returns the BER encoding of the supplied integer in the minimal two's complement form
+func berEncodeInteger(value int64) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(value)}, content...)
+		value >>= 8
+		if (value == 0 && content[0]&0x80 == 0) || (value == -1 && content[0]&0x80 != 0) {
+			break
+		}
+	}
+	return berEncode(berInteger, content)
+}
+
+//berEncodeOctetString returns the BER encoding of the supplied octet string
+func berEncodeOctetString(value []byte) []byte {
+	return berEncode(berOctetString, value)
+}
+
+//berEncodeObjectID returns the BER encoding of the supplied object identifier
+func berEncodeObjectID(oid []uint32) []byte {
+	if len(oid) < 2 {
+		return berEncode(berObjectID, nil)
+	}
+	content := []byte{byte(oid[0]*40 + oid[1])}
+	for _, arc := range oid[2:] {
+		var arcBytes []byte
+		for {
+			arcBytes = append([]byte{byte(arc & 0x7f)}, arcBytes...)
+			arc >>= 7
+			if arc == 0 {
+				break
+			}
+		}
+		for index := 0; index < len(arcBytes)-1; index++ {
+			arcBytes[index] |= 0x80
+		}
+		content = append(content, arcBytes...)
+	}
+	return berEncode(berObjectID, content)
+}
+
+//berDecode decodes the first BER element of data and returns it with the remaining data
+func berDecode(data []byte) (berElement, []byte, error) {
+	if len(data) < 2 {
+		return berElement{}, nil, errors.New("truncated BER element")
+	}
+	tag := data[0]
+	length := int(data[1])
+	offset := 2
+	if length&0x80 != 0 {
+		lengthBytes := length & 0x7f
+		if lengthBytes == 0 || lengthBytes > 3 || len(data) < offset+lengthBytes {
+			return berElement{}, nil, errors.New("invalid BER length")
+		}
+		length = 0
+		for _, lengthByte := range data[offset : offset+lengthBytes] {
+			length = length<<8 | int(lengthByte)
+		}
+		offset += lengthBytes
+	}
+	if len(data) < offset+length {
+		return berElement{}, nil, fmt.Errorf("truncated BER element with tag 0x%02x", tag)
+	}
+	return berElement{tag: tag, content: data[offset : offset+length]}, data[offset+length:], nil
+}
+
+//berDecodeSequence decodes the elements of a constructed element with the expected tag
+func berDecodeSequence(element berElement, tag byte) ([]berElement, error) {
+	if element.tag != tag {
+		return nil, fmt.Errorf("expected BER tag 0x%02x, found 0x%02x", tag, element.tag)
+	}
+	var elements []berElement
+	remaining := element.content
+	for len(remaining) > 0 {
+		child, rest, err := berDecode(remaining)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, child)
+		remaining = rest
+	}
+	return elements, nil
+}
+
+//berDecodeInteger decodes the two's complement content of an integer element
+func berDecodeInteger(element berElement) (int64, error) {
+	if len(element.content) == 0 || len(element.content) > 8 {
+		return 0, fmt.Errorf("invalid BER integer of %d bytes", len(element.content))
+	}
+	var value int64
+	if element.content[0]&0x80 != 0 {
+		value = -1
+	}
+	for _, valueByte := range element.content {
+		value = value<<8 | int64(valueByte)
+	}
+	return value, nil
+}
+
+//berDecodeObjectID decodes the content of an object identifier element
+func berDecodeObjectID(element berElement) ([]uint32, error) {
+	if element.tag != berObjectID || len(element.content) == 0 {
+		return nil, errors.New("invalid BER object identifier")
+	}
+	first := uint32(element.content[0])
+	oid := []uint32{first / 40, first % 40}
+	if first >= 80 {
+		oid = []uint32{2, first - 80}
+	}
+	var arc uint32
+	for _, arcByte := range element.content[1:] {
+		arc = arc<<7 | uint32(arcByte&0x7f)
+		if arcByte&0x80 == 0 {
+			oid = append(oid, arc)
+			arc = 0
+		}
+	}
+	return oid, nil
+}
+
+//parseObjectID parses a dotted object identifier such as 1.3.6.1.2.1
+func parseObjectID(value string) []uint32 {
+	var oid []uint32
+	for _, arc := range strings.Split(strings.Trim(value, "."), ".") {
+		number, err := strconv.ParseUint(arc, 10, 32)
+		if err != nil {
+			panic(fmt.Sprintf("invalid object identifier %s", value))
+		}
+		oid = append(oid, uint32(number))
+	}
+	return oid
+}
+
+//formatObjectID returns the dotted form of the supplied object identifier
+func formatObjectID(oid []uint32) string {
+	arcs := make([]string, len(oid))
+	for index, arc := range oid {
+		arcs[index] = strconv.FormatUint(uint64(arc), 10)
+	}
+	return strings.Join(arcs, ".")
+}
+
+//hasObjectIDPrefix returns an indicator that describes if oid lies below prefix in the object identifier tree
+func hasObjectIDPrefix(oid []uint32, prefix []uint32) bool {
+	if len(oid) <= len(prefix) {
+		return false
+	}
+	for index, arc := range prefix {
+		if oid[index] != arc {
+			return false
+		}
+	}
+	return true
+}
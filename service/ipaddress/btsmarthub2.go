@@ -1,6 +1,8 @@
 package ipaddress
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -9,13 +11,19 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 /*
-The BTSmartHub2 type that has the ability to talk to a BT Smart Hub 2 to retrieve a public IPv4 address
+The BTSmartHub2 type that has the ability to talk to a BT Smart Hub 2 to retrieve the public IPv4 address, the public
+IPv6 address and the IPv6 prefix of the active WAN connection
 
+login url: http://192.168.1.254/login.cgi (optional, form fields usr and pws where pws is the md5 hex of the password,
+a rejected login is answered with the login page rather than an error status and sets no session cookie)
 request url: http://192.168.1.254/nonAuth/wan_conn.xml
 
 sample xml response:
@@ -71,6 +79,8 @@ null]" />
     <locktime value="1" />
     <!--END for home page, login lock-->
 </status>
+
+Each *_list array holds one entry per WAN connection, wan_active_idx selects the entry of the active connection.
 */
 type BTSmartHub2 struct {
 	Config *config.RouterConfiguration
@@ -78,62 +88,141 @@ type BTSmartHub2 struct {
 
 // RouterStatus is partial model of xml response returned by a BT smart hub 2 /nonAuth/wan_conn.xml request
 type RouterStatus struct {
-	Ip4InfoList struct {
-		Text  string `xml:",chardata"`
-		Type  string `xml:"type,attr"`
-		Value string `xml:"value,attr"`
-	} `xml:"ip4_info_list"`
+	WanConnStatusList RouterStatusValue `xml:"wan_conn_status_list"`
+	WanActiveIdx      RouterStatusValue `xml:"wan_active_idx"`
+	Ip4InfoList       RouterStatusValue `xml:"ip4_info_list"`
+	Ip6GuaList        RouterStatusValue `xml:"ip6_gua_list"`
+}
+
+// RouterStatusValue is a partial model of a BT smart hub 2 /nonAuth/wan_conn.xml value element
+type RouterStatusValue struct {
+	Text  string `xml:",chardata"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:"value,attr"`
 }
 
+// the BT smart hub 2 array entries, ['a%3Bb'] or [null]
+var hubArrayEntryRegex = regexp.MustCompile(`\[([^\[\]]*)\]`)
+
+// the password field of the BT smart hub 2 login page
+var hubLoginFormRegex = regexp.MustCompile(`(?i)<input[^>]+type\s*=\s*["']?password`)
+
+// the part of a BT smart hub 2 login response searched for the login page
+const hubMaxLoginResponseSize = 1 << 20
+
 //String implements the Stringer interface to return the name of this IAddressProvider
 func (ipProvider BTSmartHub2) String() string {
 	return "BTSmartHub2 IP address provider"
 }
 
-// GetPublicIPAddresses performs a HTTP request to a BT smart hub 2 router to retrieve and return the public IP address
-// and calls GetIPv6 to return the current IPv6 address of the host where this code is executing
+// GetPublicIPAddresses performs a HTTP request to a BT smart hub 2 router to retrieve and return the public IPv4 and
+// IPv6 addresses of the active WAN connection. An error is returned when the active WAN connection is not connected
 func (ipProvider BTSmartHub2) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	ipv4, ipv6, prefix, err := ipProvider.getActiveConnection()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if prefix != nil {
+		RecordDiagnostic(Diagnostic{Source: "BTSmartHub2", Family: FamilyIPv6, Address: prefix.String(),
+			Detail: "IPv6 prefix"})
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// GetIPv6Prefix performs a HTTP request to a BT smart hub 2 router to retrieve and return the IPv6 prefix of the active
+// WAN connection
+func (ipProvider BTSmartHub2) GetIPv6Prefix() (*net.IPNet, error) {
+	_, _, prefix, err := ipProvider.getActiveConnection()
+	if err != nil {
+		return nil, err
+	}
+	if prefix == nil {
+		return nil, fmt.Errorf("the %s reports no IPv6 prefix", ipProvider)
+	}
+	return prefix, nil
+}
+
+// LogIPAddresses logs the public IP address
+func (ipProvider BTSmartHub2) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getActiveConnection retrieves the router status and returns the IPv4 address, IPv6 address and IPv6 prefix of the
+//active WAN connection
+func (ipProvider BTSmartHub2) getActiveConnection() (net.IP, net.IP, *net.IPNet, error) {
 	if ipProvider.Config == nil {
-		return nil, nil, errors.New("config is nil and it needs to be supplied")
+		return nil, nil, nil, errors.New("config is nil and it needs to be supplied")
 	}
-	xmlBytes, err := getRouterStatusXml(ipProvider.Config.IpDetailsUrl)
+	xmlBytes, err := ipProvider.getRouterStatusXml()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	var routerStatus RouterStatus
 	err = xml.Unmarshal(xmlBytes, &routerStatus)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	ipv4sArr := strings.Split(routerStatus.Ip4InfoList.Value, ",")
-	decodedIpv4s, err := url.QueryUnescape(strings.Trim(ipv4sArr[0], "[]'"))
+
+	activeIdx := 0
+	if routerStatus.WanActiveIdx.Value != "" {
+		if activeIdx, err = strconv.Atoi(routerStatus.WanActiveIdx.Value); err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to read wan_active_idx %s", routerStatus.WanActiveIdx.Value)
+		}
+	}
+
+	//connected;64;pass
+	connStatus, err := getHubArrayEntry(routerStatus.WanConnStatusList.Value, activeIdx)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, fmt.Errorf("unable to determine the WAN connection status: %v", err)
 	}
-	ipv4sArr = strings.Split(decodedIpv4s, ";")
-	ipv4 := net.ParseIP(ipv4sArr[0])
-	if ipv4 == nil {
-		return nil, nil, fmt.Errorf("unable to determine public ip from %s", decodedIpv4s)
+	if state := strings.Split(connStatus, ";")[0]; state != "connected" {
+		return nil, nil, nil, fmt.Errorf("the %s reports WAN connection %d as %s", ipProvider, activeIdx, state)
 	}
 
-	ipv6, err := GetIPv6()
+	//ip;netmask;gateway;dns1;dns2
+	decodedIpv4s, err := getHubArrayEntry(routerStatus.Ip4InfoList.Value, activeIdx)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, fmt.Errorf("unable to determine public ip: %v", err)
+	}
+	ipv4 := net.ParseIP(strings.Split(decodedIpv4s, ";")[0]).To4()
+	if ipv4 == nil || ipv4.IsUnspecified() {
+		return nil, nil, nil, fmt.Errorf("unable to determine public ip from %s", decodedIpv4s)
 	}
 
-	ipProvider.LogIPAddresses(ipv4, ipv6)
-	return ipv4, ipv6, nil
-}
+	//gua/prefixlength;gua
+	var ipv6 net.IP
+	var prefix *net.IPNet
+	if decodedIpv6s, err := getHubArrayEntry(routerStatus.Ip6GuaList.Value, activeIdx); err == nil {
+		if ip, network := parseAddressPrefix(strings.Split(decodedIpv6s, ";")[0]); isGlobalIPv6(ip) {
+			ipv6 = ip
+			prefix = network
+		}
+	}
 
-// LogIPAddresses logs the public IP address
-func (ipProvider BTSmartHub2) LogIPAddresses(ipv4, ipv6 net.IP) {
-	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+	return ipv4, ipv6, prefix, nil
 }
 
-//performs a HTTP GET request to retrieve and return the /nonAuth/wan_conn.xml
-func getRouterStatusXml(ipDetailsUrl string) ([]byte, error) {
-	response, err := http.Get(ipDetailsUrl)
+//getRouterStatusXml performs a HTTP GET request to retrieve and return the /nonAuth/wan_conn.xml, logging in first
+//when a loginUrl and password are configured
+func (ipProvider BTSmartHub2) getRouterStatusXml() ([]byte, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := getRouterHttpClient(ipProvider.Config)
+	client.Jar = jar
+
+	if ipProvider.Config.LoginUrl != "" && ipProvider.Config.Password != "" {
+		if err = ipProvider.login(client); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := client.Get(ipProvider.Config.IpDetailsUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -145,9 +234,73 @@ func getRouterStatusXml(ipDetailsUrl string) ([]byte, error) {
 		}
 	}()
 
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the %s status request returned http status code %d", ipProvider, response.StatusCode)
+	}
+
 	xmlBytes, err := io.ReadAll(response.Body)
 	if err != nil {
 		return nil, err
 	}
 	return xmlBytes, nil
 }
+
+//login posts the configured credentials to the hub login url, the session cookie is kept by the client cookie jar
+func (ipProvider BTSmartHub2) login(client *http.Client) error {
+	passwordHash := md5.Sum([]byte(ipProvider.Config.Password))
+	username := ipProvider.Config.Username
+	if username == "" {
+		username = "admin"
+	}
+	form := url.Values{}
+	form.Set("usr", username)
+	form.Set("pws", hex.EncodeToString(passwordHash[:]))
+	form.Set("GO", "status.htm")
+
+	response, err := client.PostForm(ipProvider.Config.LoginUrl, form)
+	if err != nil {
+		return fmt.Errorf("the %s login failed: %v", ipProvider, err)
+	}
+	defer func() {
+		err := response.Body.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("the %s login returned http status code %d", ipProvider, response.StatusCode)
+	}
+
+	//the hub answers a wrong username or password with its login page and a 200 status
+	page, err := io.ReadAll(io.LimitReader(response.Body, hubMaxLoginResponseSize))
+	if err != nil {
+		return fmt.Errorf("the %s login failed: %v", ipProvider, err)
+	}
+	if hubLoginFormRegex.Match(page) {
+		return fmt.Errorf("hub login failed: the %s login was answered with the login page, check the username "+
+			"and password", ipProvider)
+	}
+	statusUrl, err := url.Parse(ipProvider.Config.IpDetailsUrl)
+	if err != nil {
+		return err
+	}
+	if len(client.Jar.Cookies(statusUrl)) == 0 {
+		return fmt.Errorf("hub login failed: the %s login set no session cookie", ipProvider)
+	}
+	return nil
+}
+
+//getHubArrayEntry returns the url decoded entry at the supplied index of a BT smart hub 2 array value such as
+//[['connected%3B64%3Bpass'], ['disconnected%3B0%3Bpass'], null]
+func getHubArrayEntry(value string, index int) (string, error) {
+	entries := hubArrayEntryRegex.FindAllStringSubmatch(strings.TrimSpace(value), -1)
+	if index < 0 || index >= len(entries) {
+		return "", fmt.Errorf("there is no entry %d in %s", index, value)
+	}
+	entry := strings.Trim(strings.TrimSpace(entries[index][1]), "'")
+	if entry == "null" || entry == "" {
+		return "", fmt.Errorf("entry %d is empty", index)
+	}
+	return url.QueryUnescape(entry)
+}
@@ -0,0 +1,92 @@
+package ipaddress
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// the status of a fake hub with a connected WAN connection
+const hubTestStatus = `<status>
+    <wan_conn_status_list type="array" value="[['connected%3B64%3Bpass'],
+null]" />
+    <wan_active_idx value="0" />
+    <ip4_info_list type="array" value="[['81%2E2%2E69%2E142%3B255%2E255%2E255%2E255%3B172%2E16%2E13%2E79%3B81%2E139%2E56%2E100%3B81%2E139%2E57%2E100'],
+null]" />
+    <ip6_gua_list type="array" value="[['2a00%3A1450%3A4009%3A81d%3A%3A200e%2F64%3B2a00%3A%3A1'],
+null]" />
+</status>`
+
+// the login page the fake hub answers a rejected login with
+const hubTestLoginPage = `<html><body><form action="/login.cgi" method="post">
+<input type="text" name="usr" value="admin"><input type="PASSWORD" name="pws"></form></body></html>`
+
+//startHubTestServer starts a fake BT smart hub 2 accepting the admin password secret-password. When setCookie is not
+//set an accepted login sets no session cookie
+func startHubTestServer(t *testing.T, setCookie bool) *config.RouterConfiguration {
+	passwordHash := md5.Sum([]byte("secret-password"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login.cgi":
+			if r.PostFormValue("usr") != "admin" || r.PostFormValue("pws") != hex.EncodeToString(passwordHash[:]) {
+				_, _ = io.WriteString(w, hubTestLoginPage)
+				return
+			}
+			if setCookie {
+				http.SetCookie(w, &http.Cookie{Name: "urn", Value: "session", Path: "/"})
+			}
+			http.Redirect(w, r, "/"+r.PostFormValue("GO"), http.StatusFound)
+		case "/status.htm":
+			_, _ = io.WriteString(w, "<html><body>status</body></html>")
+		case "/nonAuth/wan_conn.xml":
+			_, _ = io.WriteString(w, hubTestStatus)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &config.RouterConfiguration{
+		LoginUrl:     server.URL + "/login.cgi",
+		IpDetailsUrl: server.URL + "/nonAuth/wan_conn.xml",
+	}
+}
+
+func TestBTSmartHub2Login(t *testing.T) {
+	routerConfig := startHubTestServer(t, true)
+	routerConfig.Password = "secret-password"
+	ipv4, ipv6, err := BTSmartHub2{Config: routerConfig}.GetPublicIPAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4.String() != "81.2.69.142" || ipv6.String() != "2a00:1450:4009:81d::200e" {
+		t.Errorf("expected the addresses 81.2.69.142 and 2a00:1450:4009:81d::200e, got %s and %s", ipv4, ipv6)
+	}
+}
+
+func TestBTSmartHub2LoginFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		password  string
+		setCookie bool
+		expected  string
+	}{
+		{"wrong password", "wrong-password", true, "answered with the login page"},
+		{"no session cookie", "secret-password", false, "set no session cookie"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := startHubTestServer(t, test.setCookie)
+			routerConfig.Password = test.password
+			_, _, err := BTSmartHub2{Config: routerConfig}.GetPublicIPAddresses()
+			if err == nil || !strings.HasPrefix(err.Error(), "hub login failed") ||
+				!strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected a hub login failed error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
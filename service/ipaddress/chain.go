@@ -0,0 +1,233 @@
+package ipaddress
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// the timeout applied to the parallel Quorum lookups when no Timeout is set
+const defaultQuorumTimeout = 30 * time.Second
+
+// Fallback wraps an ordered list of IAddressProviders and returns the IP addresses of the first provider that succeeds.
+// When Family is set a provider only succeeds when it returns an address of that family
+type Fallback struct {
+	Providers []IAddressProvider
+	Family    string
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider Fallback) String() string {
+	return fmt.Sprintf("fallback IP address provider (%d sources)", len(ipProvider.Providers))
+}
+
+// GetPublicIPAddresses queries each provider in order and returns the IP addresses of the first provider that succeeds
+func (ipProvider Fallback) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	if len(ipProvider.Providers) == 0 {
+		return nil, nil, errors.New("no IP address sources are configured")
+	}
+
+	var failures []string
+	for _, provider := range ipProvider.Providers {
+		ipv4, ipv6, err := provider.GetPublicIPAddresses()
+		if err == nil && (ipProvider.Family == FamilyIPv4 && ipv4 == nil || ipProvider.Family == FamilyIPv6 && ipv6 == nil) {
+			err = fmt.Errorf("no %s address was returned", ipProvider.Family)
+		}
+		if err == nil {
+			ipProvider.LogIPAddresses(ipv4, ipv6)
+			return ipv4, ipv6, nil
+		}
+		log.Printf("The %s failed, trying the next IP address source: %v", provider, err)
+		failures = append(failures, fmt.Sprintf("%s: %v", provider, err))
+	}
+	return nil, nil, fmt.Errorf("all IP address sources failed: %s", strings.Join(failures, "; "))
+}
+
+// GetIPv6Prefix returns the delegated IPv6 prefix of the first provider able to report one
+func (ipProvider Fallback) GetIPv6Prefix() (*net.IPNet, error) {
+	return getFirstIPv6Prefix(ipProvider.Providers)
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider Fallback) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+// Quorum wraps a list of IAddressProviders that are queried in parallel. An IP address is only accepted when at least
+// Required providers agree on it. When Family is set only the addresses of that family need to be agreed on, otherwise
+// a family that falls short of the quorum is missing and the lookup only fails when neither family is agreed on
+type Quorum struct {
+	Providers []IAddressProvider
+	Required  int
+	Timeout   time.Duration
+	Family    string
+}
+
+//quorumResult is the result of a single provider lookup performed by a Quorum
+type quorumResult struct {
+	provider IAddressProvider
+	ipv4     net.IP
+	ipv6     net.IP
+	err      error
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider Quorum) String() string {
+	return fmt.Sprintf("quorum IP address provider (%d of %d sources)", ipProvider.Required, len(ipProvider.Providers))
+}
+
+// GetPublicIPAddresses queries all providers in parallel and returns the IPv4 and IPv6 addresses that at least Required
+// providers agree on. Providers that have not answered within Timeout are disregarded
+func (ipProvider Quorum) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	if ipProvider.Required < 1 || ipProvider.Required > len(ipProvider.Providers) {
+		return nil, nil, fmt.Errorf("a quorum of %d cannot be reached with %d IP address sources",
+			ipProvider.Required, len(ipProvider.Providers))
+	}
+
+	results := make(chan quorumResult, len(ipProvider.Providers))
+	for _, provider := range ipProvider.Providers {
+		go func(provider IAddressProvider) {
+			ipv4, ipv6, err := provider.GetPublicIPAddresses()
+			results <- quorumResult{provider: provider, ipv4: ipv4, ipv6: ipv6, err: err}
+		}(provider)
+	}
+
+	timeoutDuration := ipProvider.Timeout
+	if timeoutDuration <= 0 {
+		timeoutDuration = defaultQuorumTimeout
+	}
+	timeout := time.NewTimer(timeoutDuration)
+	defer timeout.Stop()
+
+	var ipv4Votes, ipv6Votes []net.IP
+	var failures []string
+	for received := 0; received < len(ipProvider.Providers); received++ {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				log.Printf("The %s failed: %v", result.provider, result.err)
+				failures = append(failures, fmt.Sprintf("%s: %v", result.provider, result.err))
+				continue
+			}
+			if result.ipv4 != nil {
+				ipv4Votes = append(ipv4Votes, result.ipv4)
+			}
+			if result.ipv6 != nil {
+				ipv6Votes = append(ipv6Votes, result.ipv6)
+			}
+		case <-timeout.C:
+			failures = append(failures, fmt.Sprintf("%d source(s) timed out after %s",
+				len(ipProvider.Providers)-received, timeoutDuration))
+			received = len(ipProvider.Providers)
+		}
+	}
+
+	//on the shared chain a family that falls short of the quorum is missing, it does not stop the other family
+	var ipv4, ipv6 net.IP
+	var quorumErrs []string
+	if ipProvider.Family != FamilyIPv6 {
+		ip, err := ipProvider.agreedAddress("IPv4", ipv4Votes, failures)
+		if err != nil {
+			if ipProvider.Family == FamilyIPv4 {
+				return nil, nil, err
+			}
+			log.Printf("The %s has no IPv4 address: %v", ipProvider, err)
+			quorumErrs = append(quorumErrs, err.Error())
+		}
+		ipv4 = ip
+	}
+	if ipProvider.Family != FamilyIPv4 {
+		ip, err := ipProvider.agreedAddress("IPv6", ipv6Votes, failures)
+		if err != nil {
+			if ipProvider.Family == FamilyIPv6 {
+				return nil, nil, err
+			}
+			log.Printf("The %s has no IPv6 address: %v", ipProvider, err)
+			quorumErrs = append(quorumErrs, err.Error())
+		}
+		ipv6 = ip
+	}
+	if ipv4 == nil && ipv6 == nil {
+		if len(quorumErrs) > 0 {
+			return nil, nil, errors.New(strings.Join(quorumErrs, "; "))
+		}
+		return nil, nil, fmt.Errorf("no IP address source succeeded: %s", strings.Join(failures, "; "))
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+//agreedAddress returns the address with at least Required votes. A nil address is returned when there are no votes at
+//all and an error is returned when the votes do not reach the quorum or when more than one address reaches it, which a
+//quorum of half the sources or less allows
+func (ipProvider Quorum) agreedAddress(family string, votes []net.IP, failures []string) (net.IP, error) {
+	if len(votes) == 0 {
+		return nil, nil
+	}
+
+	counts := make(map[string]int)
+	var addresses []net.IP
+	for _, ip := range votes {
+		if counts[ip.String()] == 0 {
+			addresses = append(addresses, ip)
+		}
+		counts[ip.String()]++
+	}
+
+	var agreed []net.IP
+	var tally []string
+	for _, ip := range addresses {
+		if counts[ip.String()] >= ipProvider.Required {
+			agreed = append(agreed, ip)
+		}
+		tally = append(tally, fmt.Sprintf("%s (%d)", ip, counts[ip.String()]))
+	}
+	if len(agreed) == 1 {
+		return agreed[0], nil
+	}
+
+	err := fmt.Errorf("no %s address was agreed on by %d IP address sources, votes: %s",
+		family, ipProvider.Required, strings.Join(tally, ", "))
+	if len(agreed) > 1 {
+		err = fmt.Errorf("the IP address sources disagree, %d %s addresses were each agreed on by %d sources, votes: %s",
+			len(agreed), family, ipProvider.Required, strings.Join(tally, ", "))
+	}
+	if len(failures) > 0 {
+		err = fmt.Errorf("%v, failures: %s", err, strings.Join(failures, "; "))
+	}
+	return nil, err
+}
+
+// GetIPv6Prefix returns the delegated IPv6 prefix of the first provider able to report one
+func (ipProvider Quorum) GetIPv6Prefix() (*net.IPNet, error) {
+	return getFirstIPv6Prefix(ipProvider.Providers)
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider Quorum) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getFirstIPv6Prefix returns the delegated IPv6 prefix of the first IPrefixProvider in providers that reports one
+func getFirstIPv6Prefix(providers []IAddressProvider) (*net.IPNet, error) {
+	var failures []string
+	for _, provider := range providers {
+		prefixProvider, ok := provider.(IPrefixProvider)
+		if !ok {
+			continue
+		}
+		prefix, err := prefixProvider.GetIPv6Prefix()
+		if err == nil {
+			return prefix, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", provider, err))
+	}
+	if len(failures) == 0 {
+		return nil, errors.New("no IP address source reports a delegated IPv6 prefix")
+	}
+	return nil, fmt.Errorf("no IP address source reported a delegated IPv6 prefix: %s", strings.Join(failures, "; "))
+}
@@ -0,0 +1,183 @@
+package ipaddress
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//chainTestProvider is a stub IAddressProvider returning fixed addresses or an error after an optional delay
+type chainTestProvider struct {
+	name  string
+	ipv4  string
+	ipv6  string
+	err   error
+	delay time.Duration
+	calls *int32
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider chainTestProvider) String() string {
+	return ipProvider.name
+}
+
+//GetPublicIPAddresses returns the fixed addresses or error of the stub provider
+func (ipProvider chainTestProvider) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	if ipProvider.calls != nil {
+		atomic.AddInt32(ipProvider.calls, 1)
+	}
+	time.Sleep(ipProvider.delay)
+	if ipProvider.err != nil {
+		return nil, nil, ipProvider.err
+	}
+	return net.ParseIP(ipProvider.ipv4).To4(), net.ParseIP(ipProvider.ipv6), nil
+}
+
+//LogIPAddresses is a no-op for the stub provider
+func (ipProvider chainTestProvider) LogIPAddresses(ipv4, ipv6 net.IP) {
+}
+
+//ipv4Provider returns a stub provider of the supplied IPv4 address
+func ipv4Provider(name string, ipv4 string) chainTestProvider {
+	return chainTestProvider{name: name, ipv4: ipv4}
+}
+
+func TestFallback(t *testing.T) {
+	var thirdCalls int32
+	tests := []struct {
+		name      string
+		family    string
+		providers []IAddressProvider
+		ipv4      string
+		ipv6      string
+		expected  string
+	}{
+		{"first success wins", "", []IAddressProvider{
+			chainTestProvider{name: "first", err: errors.New("unreachable")},
+			chainTestProvider{name: "second", ipv4: "81.2.69.142", ipv6: "2a00:1450:4009:81d::200e"},
+			chainTestProvider{name: "third", ipv4: "81.2.69.143", calls: &thirdCalls},
+		}, "81.2.69.142", "2a00:1450:4009:81d::200e", ""},
+		{"family filter skips a source without the family", FamilyIPv6, []IAddressProvider{
+			ipv4Provider("first", "81.2.69.142"),
+			chainTestProvider{name: "second", ipv6: "2a00:1450:4009:81d::200e"},
+		}, "<nil>", "2a00:1450:4009:81d::200e", ""},
+		{"all sources fail", FamilyIPv4, []IAddressProvider{
+			chainTestProvider{name: "first", err: errors.New("unreachable")},
+			chainTestProvider{name: "second", ipv6: "2a00:1450:4009:81d::200e"},
+		}, "", "", "all IP address sources failed: first: unreachable; second: no ipv4 address was returned"},
+		{"no sources", "", nil, "", "", "no IP address sources are configured"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ipv4, ipv6, err := Fallback{Providers: test.providers, Family: test.family}.GetPublicIPAddresses()
+			if test.expected != "" {
+				if err == nil || err.Error() != test.expected {
+					t.Errorf("expected the error %q, got %v", test.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+		})
+	}
+	if thirdCalls != 0 {
+		t.Errorf("expected the source after the first success not to be queried, it was queried %d times", thirdCalls)
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	tests := []struct {
+		name      string
+		family    string
+		required  int
+		providers []IAddressProvider
+		ipv4      string
+		ipv6      string
+		expected  string
+	}{
+		{"majority agrees", "", 2, []IAddressProvider{
+			ipv4Provider("first", "81.2.69.142"),
+			ipv4Provider("second", "81.2.69.143"),
+			ipv4Provider("third", "81.2.69.142"),
+		}, "81.2.69.142", "<nil>", ""},
+		{"failed source is not a vote", "", 2, []IAddressProvider{
+			ipv4Provider("first", "81.2.69.142"),
+			chainTestProvider{name: "second", err: errors.New("unreachable")},
+			ipv4Provider("third", "81.2.69.142"),
+		}, "81.2.69.142", "<nil>", ""},
+		{"no address reaches the quorum", "", 2, []IAddressProvider{
+			ipv4Provider("first", "81.2.69.142"),
+			ipv4Provider("second", "81.2.69.143"),
+			ipv4Provider("third", "81.2.69.144"),
+		}, "", "", "no IPv4 address was agreed on by 2 IP address sources"},
+		{"two addresses reach a minority quorum", "", 2, []IAddressProvider{
+			ipv4Provider("first", "81.2.69.142"),
+			ipv4Provider("second", "81.2.69.143"),
+			ipv4Provider("third", "81.2.69.142"),
+			ipv4Provider("fourth", "81.2.69.143"),
+		}, "", "", "the IP address sources disagree, 2 IPv4 addresses were each agreed on by 2 sources"},
+		{"family short of the quorum is missing on the shared chain", "", 2, []IAddressProvider{
+			chainTestProvider{name: "first", ipv4: "81.2.69.142", ipv6: "2a00:1450:4009:81d::200e"},
+			chainTestProvider{name: "second", ipv4: "81.2.69.142", ipv6: "2a00:1450:4009:81d::200f"},
+		}, "81.2.69.142", "<nil>", ""},
+		{"family short of the quorum fails the family chain", FamilyIPv6, 2, []IAddressProvider{
+			chainTestProvider{name: "first", ipv4: "81.2.69.142", ipv6: "2a00:1450:4009:81d::200e"},
+			chainTestProvider{name: "second", ipv4: "81.2.69.142", ipv6: "2a00:1450:4009:81d::200f"},
+		}, "", "", "no IPv6 address was agreed on by 2 IP address sources"},
+		{"quorum larger than the sources", "", 3, []IAddressProvider{
+			ipv4Provider("first", "81.2.69.142"),
+			ipv4Provider("second", "81.2.69.142"),
+		}, "", "", "a quorum of 3 cannot be reached with 2 IP address sources"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quorum := Quorum{Providers: test.providers, Required: test.required, Family: test.family}
+			ipv4, ipv6, err := quorum.GetPublicIPAddresses()
+			if test.expected != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.expected) {
+					t.Errorf("expected an error starting %q, got %v", test.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+		})
+	}
+}
+
+func TestQuorumTimeout(t *testing.T) {
+	providers := []IAddressProvider{
+		ipv4Provider("first", "81.2.69.142"),
+		chainTestProvider{name: "slow", ipv4: "81.2.69.142", delay: 5 * time.Second},
+		ipv4Provider("third", "81.2.69.142"),
+	}
+	for _, required := range []int{2, 3} {
+		t.Run(fmt.Sprintf("quorum of %d", required), func(t *testing.T) {
+			start := time.Now()
+			quorum := Quorum{Providers: providers, Required: required, Timeout: 100 * time.Millisecond}
+			ipv4, _, err := quorum.GetPublicIPAddresses()
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected the lookup to end at the timeout, it took %s", elapsed)
+			}
+			if required == 2 && (err != nil || ipv4.String() != "81.2.69.142") {
+				t.Errorf("expected the IPv4 address 81.2.69.142 agreed on by the sources that answered, got %s %v",
+					ipv4, err)
+			}
+			if required == 3 && (err == nil || !strings.Contains(err.Error(), "1 source(s) timed out after 100ms")) {
+				t.Errorf("expected the timed out source to be reported, got %v", err)
+			}
+		})
+	}
+}
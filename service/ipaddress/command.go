@@ -0,0 +1,164 @@
+package ipaddress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// the timeout applied to command IP address sources when no Timeout is set
+const defaultCommandSourceTimeout = 10 * time.Second
+
+// the time allowed for the output of a command killed on timeout to be closed
+const commandKillGracePeriod = time.Second
+
+/*
+The CommandSource type that has the ability to run an external command or script and read the public IP addresses from
+its standard output, an escape hatch for modems and routers that are already scraped by a script
+
+sample text output, one or two addresses separated by whitespace or commas:
+	255.255.255.255
+	2a00:2a00:2a00:2a00::1
+
+sample json output:
+	{"ipv4": "255.255.255.255", "ipv6": "2a00:2a00:2a00:2a00::1"}
+
+The command is run directly rather than through a shell, Env entries (KEY=value) are added to the environment of this
+process. A command that exits with a non zero status, does not finish within Timeout or prints anything that is not a
+public IP address fails, addresses of the family other than the configured Family are ignored.
+*/
+type CommandSource struct {
+	Command string        // The command to run
+	Args    []string      // The command arguments
+	Env     []string      // Additional KEY=value environment variables
+	Format  string        // text (the default) or json
+	Family  string        // ipv4, ipv6 or empty to accept both address families
+	Timeout time.Duration // The time the command is allowed to run for
+}
+
+//commandOutput is the model of the json output of a command IP address source
+type commandOutput struct {
+	IPv4 string `json:"ipv4"`
+	IPv6 string `json:"ipv6"`
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider CommandSource) String() string {
+	return fmt.Sprintf("%s command IP address provider", ipProvider.Command)
+}
+
+// GetPublicIPAddresses runs the configured command and returns the public IPv4 and IPv6 addresses read from its output
+func (ipProvider CommandSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	output, err := ipProvider.run()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var values []string
+	switch ipProvider.Format {
+	case "", ExtractorText:
+		values = strings.FieldsFunc(string(output), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+		})
+	case ExtractorJSON:
+		var document commandOutput
+		if err = json.Unmarshal(output, &document); err != nil {
+			return nil, nil, fmt.Errorf("the %s output is not valid json: %v", ipProvider, err)
+		}
+		for _, value := range []string{document.IPv4, document.IPv6} {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported command IP address source format %s", ipProvider.Format)
+	}
+
+	var ipv4, ipv6 net.IP
+	for _, value := range values {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("the %s returned '%s' which is not an IP address", ipProvider, value)
+		}
+		isIPv4 := ip.To4() != nil
+		if (ipProvider.Family == FamilyIPv4 && !isIPv4) || (ipProvider.Family == FamilyIPv6 && isIPv4) {
+			//an address of the other family is ignored, such as the private IPv4 address of an IPv6 only source
+			continue
+		}
+		if !IsPublicAddress(ip) {
+			return nil, nil, fmt.Errorf("the %s returned %s which is not a public IP address", ipProvider, ip)
+		}
+		if isIPv4 && ipv4 == nil {
+			ipv4 = ip.To4()
+		} else if !isIPv4 && ipv6 == nil {
+			ipv6 = ip
+		}
+	}
+
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s returned no public IP address", ipProvider)
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider CommandSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//run runs the configured command and returns its standard output
+func (ipProvider CommandSource) run() ([]byte, error) {
+	if ipProvider.Command == "" {
+		return nil, errors.New("the command IP address source requires a command")
+	}
+	timeout := ipProvider.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandSourceTimeout
+	}
+
+	command := exec.Command(ipProvider.Command, ipProvider.Args...)
+	command.Env = append(os.Environ(), ipProvider.Env...)
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	//the children of a script are killed along with it, a child left running would hold the output open
+	setProcessGroup(command)
+
+	if err := command.Start(); err != nil {
+		return nil, fmt.Errorf("the %s failed: %v", ipProvider, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("the %s failed: %v %s", ipProvider, err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.Bytes(), nil
+	case <-timer.C:
+		if err := killProcessGroup(command); err != nil {
+			log.Println(err)
+		}
+		//Wait also waits for the output to be closed, which a descendant that left the process group may still hold
+		select {
+		case <-done:
+		case <-time.After(commandKillGracePeriod):
+			log.Printf("The output of the %s is still open after it was killed", ipProvider)
+		}
+		return nil, fmt.Errorf("the %s did not finish within %s", ipProvider, timeout)
+	}
+}
@@ -0,0 +1,16 @@
+package ipaddress
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+//setProcessGroup starts the command in a process group of its own
+func setProcessGroup(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+//killProcessGroup kills the process group of the started command, the command and all of its children
+func killProcessGroup(command *exec.Cmd) error {
+	return syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,89 @@
+package ipaddress
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandSourceReadsOutput(t *testing.T) {
+	source := CommandSource{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo 81.2.69.142, 2a00:1450:4009:81d::200e"},
+		Timeout: 5 * time.Second,
+	}
+	ipv4, ipv6, err := source.GetPublicIPAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4.String() != "81.2.69.142" || ipv6.String() != "2a00:1450:4009:81d::200e" {
+		t.Errorf("expected the addresses 81.2.69.142 and 2a00:1450:4009:81d::200e, got %s and %s", ipv4, ipv6)
+	}
+}
+
+func TestCommandSourceKillsChildrenOnTimeout(t *testing.T) {
+	//the background child holds the output open after the shell is gone
+	timeout := 200 * time.Millisecond
+	source := CommandSource{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 30 & echo 81.2.69.142; wait"},
+		Timeout: timeout,
+	}
+	start := time.Now()
+	_, _, err := source.GetPublicIPAddresses()
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > timeout+commandKillGracePeriod/2 {
+		t.Errorf("expected the process group to be killed on timeout, the command ran for %s", elapsed)
+	}
+}
+
+func TestCommandSourceFamily(t *testing.T) {
+	tests := []struct {
+		name   string
+		family string
+		format string
+		output string
+		ipv4   string
+		ipv6   string
+		err    string
+	}{
+		{"private IPv4 address of an IPv6 source", FamilyIPv6, "", "192.168.1.2 2a00:1450:4009:81d::200e", "<nil>",
+			"2a00:1450:4009:81d::200e", ""},
+		{"ULA of an IPv4 source", FamilyIPv4, "", "fd00::1, 81.2.69.142", "81.2.69.142", "<nil>", ""},
+		{"json private IPv4 address of an IPv6 source", FamilyIPv6, ExtractorJSON,
+			`{"ipv4": "10.0.0.2", "ipv6": "2a00:1450:4009:81d::200e"}`, "<nil>", "2a00:1450:4009:81d::200e", ""},
+		{"private address of the source family", FamilyIPv4, "", "192.168.1.2 2a00:1450:4009:81d::200e", "", "",
+			"returned 192.168.1.2 which is not a public IP address"},
+		{"private address of a source of both families", "", "", "192.168.1.2 2a00:1450:4009:81d::200e", "", "",
+			"returned 192.168.1.2 which is not a public IP address"},
+		{"only an address of the other family", FamilyIPv6, "", "81.2.69.142", "", "", "returned no public IP address"},
+		{"output that is not an address", FamilyIPv6, "", "unknown 2a00:1450:4009:81d::200e", "", "",
+			"returned 'unknown' which is not an IP address"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := CommandSource{
+				Command: "/bin/echo",
+				Args:    []string{test.output},
+				Format:  test.format,
+				Family:  test.family,
+				Timeout: 5 * time.Second,
+			}
+			ipv4, ipv6, err := source.GetPublicIPAddresses()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package ipaddress
+
+import (
+	"os/exec"
+)
+
+//setProcessGroup is only implemented on linux, the command shares the process group of this process elsewhere
+func setProcessGroup(*exec.Cmd) {
+}
+
+//killProcessGroup kills the started command, its children are only killed on linux
+func killProcessGroup(command *exec.Cmd) error {
+	return command.Process.Kill()
+}
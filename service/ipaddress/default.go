@@ -5,7 +5,6 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"strings"
 )
 
 // IAddressProvider describes the interface of a type able to return the public facing IP address in use where this code is running
@@ -32,7 +31,8 @@ func (ipProvider Default) String() string {
 }
 
 // GetPublicIPAddresses performs a HTTP request to https://api.ipify.org to retrieve and return the public IPv4 address
-// and calls GetIPv6 to return the current IPv6 address of the host where this code is executing
+// and calls GetIPv6 to return the current IPv6 address of the host where this code is executing, which is nil when the
+// host has no public IPv6 address
 func (ipProvider Default) GetPublicIPAddresses() (net.IP, net.IP, error) {
 	response, err := http.Get("https://api.ipify.org")
 	if err != nil {
@@ -57,29 +57,22 @@ func (ipProvider Default) GetPublicIPAddresses() (net.IP, net.IP, error) {
 
 	ipv6, err := GetIPv6()
 	if err != nil {
-		return nil, nil, err
+		//the IPv4 address is still usable without an IPv6 address
+		log.Printf("The %s could not read the IPv6 address: %v", ipProvider, err)
 	}
 
 	ipProvider.LogIPAddresses(ipv4, ipv6)
 	return ipv4, ipv6, nil
 }
 
-//GetIPv6 returns the IPv6 address of the host where this code is executing
+//GetIPv6 returns the public IPv6 address of the host where this code is executing. Unique local, link-local and, on
+//linux, temporary and deprecated privacy addresses are never returned. A nil address is returned when there is none
 func GetIPv6() (net.IP, error) {
-	var ip net.IP
-	ifAddresses, err := net.InterfaceAddrs()
+	addresses, err := getInterfaceAddresses("")
 	if err != nil {
 		return nil, err
 	}
-	for _, address := range ifAddresses {
-		if ipNetwork, ok := address.(*net.IPNet); ok && !ipNetwork.IP.IsLoopback() {
-			if len(strings.Split(ipNetwork.IP.String(), ":")) == 8 {
-				ip = ipNetwork.IP
-				break
-			}
-		}
-	}
-	return ip, nil
+	return selectAddress(addresses, FamilyIPv6, nil, nil), nil
 }
 
 // LogIPAddresses logs the public IP addresses
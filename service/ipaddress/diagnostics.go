@@ -0,0 +1,51 @@
+package ipaddress
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Diagnostic describes a detail reported by an IAddressProvider during its last lookup, such as the mapped address and
+// port observed by a STUN server
+type Diagnostic struct {
+	Source    string `json:"source"`
+	Family    string `json:"family,omitempty"`
+	Server    string `json:"server,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+//diagnostics holds the last Diagnostic recorded per source and family
+var diagnostics = struct {
+	mu      sync.Mutex
+	entries map[string]Diagnostic
+}{entries: make(map[string]Diagnostic)}
+
+// RecordDiagnostic records the supplied Diagnostic, replacing any earlier Diagnostic for the same source and family
+func RecordDiagnostic(diagnostic Diagnostic) {
+	diagnostic.Timestamp = time.Now().Format(time.RFC3339)
+	diagnostics.mu.Lock()
+	defer diagnostics.mu.Unlock()
+	diagnostics.entries[diagnostic.Source+"/"+diagnostic.Family] = diagnostic
+}
+
+// GetDiagnostics returns the last recorded Diagnostic of each source and family ordered by source and family
+func GetDiagnostics() []Diagnostic {
+	diagnostics.mu.Lock()
+	defer diagnostics.mu.Unlock()
+
+	keys := make([]string, 0, len(diagnostics.entries))
+	for key := range diagnostics.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]Diagnostic, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, diagnostics.entries[key])
+	}
+	return entries
+}
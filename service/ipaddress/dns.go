@@ -0,0 +1,330 @@
+package ipaddress
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNS resource record types and classes used by the DNSSource
+const (
+	dnsTypeA     uint16 = 1
+	dnsTypeTXT   uint16 = 16
+	dnsTypeAAAA  uint16 = 28
+	dnsClassIN   uint16 = 1
+	dnsClassCH   uint16 = 3
+	dnsHeaderLen        = 12
+)
+
+// the timeout applied to DNS IP address source queries when no Timeout is set
+const defaultDNSSourceTimeout = 5 * time.Second
+
+/*
+The DNSSource type that has the ability to discover the public IP address by querying special DNS names that resolve to
+the address of the querying client
+
+opendns:    dig @resolver1.opendns.com myip.opendns.com A (or AAAA over IPv6 transport)
+google:     dig @ns1.google.com o-o.myaddr.l.google.com TXT
+cloudflare: dig @1.1.1.1 whoami.cloudflare TXT CH
+
+The query is sent over IPv4 transport to discover the public IPv4 address and over IPv6 transport to discover the
+public IPv6 address.
+*/
+type DNSSource struct {
+	Preset    string        // opendns, google or cloudflare
+	Resolvers []string      // Resolver addresses with an optional port overriding the preset resolvers
+	Family    string        // ipv4, ipv6 or empty to query over both transports
+	Timeout   time.Duration // The query timeout
+}
+
+//dnsPreset describes how a public IP address is discovered from a DNS service
+type dnsPreset struct {
+	name      string
+	ipv4Type  uint16
+	ipv6Type  uint16
+	class     uint16
+	resolvers []string
+}
+
+// dnsPresets are the built-in DNSSource configurations
+var dnsPresets = map[string]dnsPreset{
+	"opendns": {
+		name: "myip.opendns.com", ipv4Type: dnsTypeA, ipv6Type: dnsTypeAAAA, class: dnsClassIN,
+		resolvers: []string{"208.67.222.222", "2620:119:35::35"}},
+	"google": {
+		name: "o-o.myaddr.l.google.com", ipv4Type: dnsTypeTXT, ipv6Type: dnsTypeTXT, class: dnsClassIN,
+		resolvers: []string{"216.239.32.10", "2001:4860:4802:32::a"}},
+	"cloudflare": {
+		name: "whoami.cloudflare", ipv4Type: dnsTypeTXT, ipv6Type: dnsTypeTXT, class: dnsClassCH,
+		resolvers: []string{"1.1.1.1", "2606:4700:4700::1111"}},
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider DNSSource) String() string {
+	return fmt.Sprintf("%s DNS IP address provider", ipProvider.Preset)
+}
+
+// GetPublicIPAddresses queries the preset DNS name over IPv4 and IPv6 transport and returns the public IP addresses.
+// An error is only returned when no address could be discovered at all
+func (ipProvider DNSSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	preset, ok := dnsPresets[ipProvider.Preset]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown DNS IP address source preset %s", ipProvider.Preset)
+	}
+	resolvers := ipProvider.Resolvers
+	if len(resolvers) == 0 {
+		resolvers = preset.resolvers
+	}
+
+	var ipv4, ipv6 net.IP
+	var failures []string
+	if ipProvider.Family != FamilyIPv6 {
+		ip, err := ipProvider.query(preset, preset.ipv4Type, FamilyIPv4, resolvers)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		ipv4 = ip
+	}
+	if ipProvider.Family != FamilyIPv4 {
+		ip, err := ipProvider.query(preset, preset.ipv6Type, FamilyIPv6, resolvers)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		ipv6 = ip
+	}
+
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s failed: %s", ipProvider, strings.Join(failures, "; "))
+	}
+	if len(failures) > 0 {
+		log.Printf("The %s only partially succeeded: %s", ipProvider, strings.Join(failures, "; "))
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider DNSSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//query sends the preset query to each resolver of the supplied family in turn and returns the first address of that
+//family found in an answer
+func (ipProvider DNSSource) query(preset dnsPreset, qtype uint16, family string, resolvers []string) (net.IP, error) {
+	timeout := ipProvider.Timeout
+	if timeout <= 0 {
+		timeout = defaultDNSSourceTimeout
+	}
+
+	var failures []string
+	for _, resolver := range resolvers {
+		address, isIPv4, err := resolverAddress(resolver)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if isIPv4 != (family == FamilyIPv4) {
+			continue
+		}
+
+		network := "udp6"
+		if isIPv4 {
+			network = "udp4"
+		}
+		answers, err := exchangeDNSQuery(network, address, preset.name, qtype, preset.class, timeout)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", address, err))
+			continue
+		}
+		for _, answer := range answers {
+			if ip := net.ParseIP(answer); ip != nil && (ip.To4() != nil) == isIPv4 {
+				if isIPv4 {
+					return ip.To4(), nil
+				}
+				return ip, nil
+			}
+		}
+		failures = append(failures, fmt.Sprintf("%s: no %s address in the answer", address, family))
+	}
+
+	if len(failures) == 0 {
+		return nil, fmt.Errorf("no %s resolver is configured", family)
+	}
+	return nil, errors.New(strings.Join(failures, "; "))
+}
+
+//resolverAddress returns the host:port address of the supplied resolver, defaulting the port to 53, and an indicator
+//that describes if the resolver is an IPv4 resolver
+func resolverAddress(resolver string) (string, bool, error) {
+	host, port, err := net.SplitHostPort(resolver)
+	if err != nil {
+		host, port = strings.Trim(resolver, "[]"), "53"
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", false, fmt.Errorf("the resolver %s is not an IP address", resolver)
+	}
+	return net.JoinHostPort(host, port), ip.To4() != nil, nil
+}
+
+//exchangeDNSQuery sends a single DNS query over UDP to the supplied address and returns the A, AAAA and TXT answers as
+//strings
+func exchangeDNSQuery(
+	network string,
+	address string,
+	name string,
+	qtype uint16,
+	qclass uint16,
+	timeout time.Duration) ([]string, error) {
+
+	query, id, err := buildDNSQuery(name, qtype, qclass)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := conn.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, 4096)
+	for {
+		count, err := conn.Read(buffer)
+		if err != nil {
+			return nil, err
+		}
+		if count >= 2 && binary.BigEndian.Uint16(buffer) != id {
+			//a stray response to an earlier query, keep waiting
+			continue
+		}
+		return parseDNSResponse(buffer[:count], qtype)
+	}
+}
+
+//buildDNSQuery returns a recursion desired DNS query message for the supplied name, type and class and its id
+func buildDNSQuery(name string, qtype uint16, qclass uint16) ([]byte, uint16, error) {
+	idBytes := make([]byte, 2)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(idBytes)
+
+	message := make([]byte, dnsHeaderLen, 512)
+	binary.BigEndian.PutUint16(message[0:], id)
+	binary.BigEndian.PutUint16(message[2:], 0x0100) //RD
+	binary.BigEndian.PutUint16(message[4:], 1)      //QDCOUNT
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, 0, fmt.Errorf("invalid DNS name %s", name)
+		}
+		message = append(message, byte(len(label)))
+		message = append(message, label...)
+	}
+	message = append(message, 0)
+	message = append(message, byte(qtype>>8), byte(qtype), byte(qclass>>8), byte(qclass))
+	return message, id, nil
+}
+
+//parseDNSResponse parses the supplied DNS response message and returns the answers of the supplied type as strings
+func parseDNSResponse(message []byte, qtype uint16) ([]string, error) {
+	if len(message) < dnsHeaderLen {
+		return nil, errors.New("the DNS response is truncated")
+	}
+	flags := binary.BigEndian.Uint16(message[2:])
+	if flags&0x8000 == 0 {
+		return nil, errors.New("the DNS message is not a response")
+	}
+	if rcode := flags & 0x000f; rcode != 0 {
+		return nil, fmt.Errorf("the DNS query failed with rcode %d", rcode)
+	}
+	questionCount := int(binary.BigEndian.Uint16(message[4:]))
+	answerCount := int(binary.BigEndian.Uint16(message[6:]))
+
+	offset := dnsHeaderLen
+	var err error
+	for i := 0; i < questionCount; i++ {
+		if offset, err = skipDNSName(message, offset); err != nil {
+			return nil, err
+		}
+		offset += 4
+	}
+
+	var answers []string
+	for i := 0; i < answerCount; i++ {
+		if offset, err = skipDNSName(message, offset); err != nil {
+			return nil, err
+		}
+		if offset+10 > len(message) {
+			return nil, errors.New("the DNS answer is truncated")
+		}
+		rrType := binary.BigEndian.Uint16(message[offset:])
+		rdLength := int(binary.BigEndian.Uint16(message[offset+8:]))
+		offset += 10
+		if offset+rdLength > len(message) {
+			return nil, errors.New("the DNS answer data is truncated")
+		}
+		rdata := message[offset : offset+rdLength]
+		offset += rdLength
+
+		if rrType != qtype {
+			continue
+		}
+		switch rrType {
+		case dnsTypeA, dnsTypeAAAA:
+			if len(rdata) == net.IPv4len || len(rdata) == net.IPv6len {
+				answers = append(answers, net.IP(rdata).String())
+			}
+		case dnsTypeTXT:
+			var builder strings.Builder
+			for len(rdata) > 0 {
+				length := int(rdata[0])
+				if 1+length > len(rdata) {
+					return nil, errors.New("the DNS TXT record is truncated")
+				}
+				builder.Write(rdata[1 : 1+length])
+				rdata = rdata[1+length:]
+			}
+			answers = append(answers, builder.String())
+		}
+	}
+	return answers, nil
+}
+
+//skipDNSName returns the offset following the possibly compressed DNS name starting at the supplied offset
+func skipDNSName(message []byte, offset int) (int, error) {
+	for {
+		if offset >= len(message) {
+			return 0, errors.New("the DNS name is truncated")
+		}
+		length := int(message[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			//a compression pointer ends the name
+			return offset + 2, nil
+		default:
+			offset += 1 + length
+		}
+	}
+}
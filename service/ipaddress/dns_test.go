@@ -0,0 +1,216 @@
+package ipaddress
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+//dnsTestResolver is a DNS resolver stand-in answering the query of a DNSSource preset over UDP. Each answer is
+//preceded by a stray response carrying another query id and a wrong address that must be ignored
+type dnsTestResolver struct {
+	t       *testing.T
+	conn    net.PacketConn
+	preset  dnsPreset
+	rcode   uint16
+	answers map[uint16][][]byte // the answer rdata by query type
+}
+
+//start starts the resolver on the supplied loopback address and returns its address, the resolver is stopped when the
+//test completes. The test is skipped when the loopback address is not available
+func (resolver *dnsTestResolver) start(t *testing.T, network, address string) string {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Skipf("the %s loopback is not available: %v", network, err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	resolver.t = t
+	resolver.conn = conn
+	go resolver.serve()
+	return conn.LocalAddr().String()
+}
+
+//serve answers the queries until the connection is closed
+func (resolver *dnsTestResolver) serve() {
+	buffer := make([]byte, 512)
+	for {
+		length, address, err := resolver.conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		id, question, qtype, err := resolver.parseQuery(buffer[:length])
+		if err != nil {
+			resolver.t.Errorf("the DNS test resolver rejected a query: %v", err)
+			continue
+		}
+
+		stray := [][]byte{net.ParseIP("192.0.2.1").To4()}
+		if qtype == dnsTypeAAAA {
+			stray = [][]byte{net.ParseIP("2001:db8::1")}
+		} else if qtype == dnsTypeTXT {
+			stray = [][]byte{dnsTestTXT("192.0.2.1")}
+		}
+		responses := [][]byte{
+			resolver.response(id^0xffff, 0, question, qtype, stray),
+			resolver.response(id, resolver.rcode, question, qtype, resolver.answers[qtype]),
+		}
+		for _, response := range responses {
+			if _, err = resolver.conn.WriteTo(response, address); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//parseQuery checks a query against the preset and returns its id, question section and type
+func (resolver *dnsTestResolver) parseQuery(query []byte) (uint16, []byte, uint16, error) {
+	if len(query) < dnsHeaderLen {
+		return 0, nil, 0, fmt.Errorf("truncated query of %d bytes", len(query))
+	}
+	if flags := binary.BigEndian.Uint16(query[2:]); flags != 0x0100 {
+		return 0, nil, 0, fmt.Errorf("expected a recursion desired query, the flags are 0x%04x", flags)
+	}
+	if count := binary.BigEndian.Uint16(query[4:]); count != 1 {
+		return 0, nil, 0, fmt.Errorf("expected a single question, got %d", count)
+	}
+
+	var labels []string
+	offset := dnsHeaderLen
+	for offset < len(query) && query[offset] != 0 {
+		length := int(query[offset])
+		if offset+1+length > len(query) {
+			return 0, nil, 0, fmt.Errorf("truncated label")
+		}
+		labels = append(labels, string(query[offset+1:offset+1+length]))
+		offset += 1 + length
+	}
+	offset++
+	if offset+4 != len(query) {
+		return 0, nil, 0, fmt.Errorf("malformed question")
+	}
+	qtype := binary.BigEndian.Uint16(query[offset:])
+	qclass := binary.BigEndian.Uint16(query[offset+2:])
+
+	if name := strings.Join(labels, "."); name != resolver.preset.name {
+		return 0, nil, 0, fmt.Errorf("unexpected name %s", name)
+	}
+	if qtype != resolver.preset.ipv4Type && qtype != resolver.preset.ipv6Type {
+		return 0, nil, 0, fmt.Errorf("unexpected type %d", qtype)
+	}
+	if qclass != resolver.preset.class {
+		return 0, nil, 0, fmt.Errorf("unexpected class %d", qclass)
+	}
+	return binary.BigEndian.Uint16(query), query[dnsHeaderLen:], qtype, nil
+}
+
+//response returns a response carrying the supplied answers, the answer names are compressed to the question name
+func (resolver *dnsTestResolver) response(id, rcode uint16, question []byte, qtype uint16, answers [][]byte) []byte {
+	message := make([]byte, dnsHeaderLen)
+	binary.BigEndian.PutUint16(message[0:], id)
+	binary.BigEndian.PutUint16(message[2:], 0x8180|rcode) //QR RD RA
+	binary.BigEndian.PutUint16(message[4:], 1)
+	binary.BigEndian.PutUint16(message[6:], uint16(len(answers)))
+	message = append(message, question...)
+
+	for _, rdata := range answers {
+		answer := make([]byte, 12)
+		binary.BigEndian.PutUint16(answer[0:], 0xc000|dnsHeaderLen)
+		binary.BigEndian.PutUint16(answer[2:], qtype)
+		binary.BigEndian.PutUint16(answer[4:], resolver.preset.class)
+		binary.BigEndian.PutUint32(answer[6:], 0)
+		binary.BigEndian.PutUint16(answer[10:], uint16(len(rdata)))
+		message = append(append(message, answer...), rdata...)
+	}
+	return message
+}
+
+//dnsTestTXT returns the TXT rdata of the supplied text, split into character strings of at most 8 bytes
+func dnsTestTXT(text string) []byte {
+	var rdata []byte
+	for len(text) > 8 {
+		rdata = append(append(rdata, 8), text[:8]...)
+		text = text[8:]
+	}
+	return append(append(rdata, byte(len(text))), text...)
+}
+
+func TestDNSSourcePresetsOverIPv4(t *testing.T) {
+	tests := []struct {
+		preset  string
+		answers map[uint16][][]byte
+	}{
+		{"opendns", map[uint16][][]byte{
+			dnsTypeA: {net.ParseIP("81.2.69.142").To4()},
+		}},
+		{"google", map[uint16][][]byte{
+			dnsTypeTXT: {dnsTestTXT("edns0-client-subnet 81.2.69.0/24"), dnsTestTXT("81.2.69.142")},
+		}},
+		{"cloudflare", map[uint16][][]byte{
+			dnsTypeTXT: {dnsTestTXT("81.2.69.142")},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.preset, func(t *testing.T) {
+			resolver := &dnsTestResolver{preset: dnsPresets[test.preset], answers: test.answers}
+			source := DNSSource{
+				Preset:    test.preset,
+				Resolvers: []string{resolver.start(t, "udp4", "127.0.0.1:0")},
+				Timeout:   2 * time.Second,
+			}
+			ipv4, ipv6, err := source.GetPublicIPAddresses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != "81.2.69.142" || len(ipv4) != net.IPv4len {
+				t.Errorf("expected the IPv4 address 81.2.69.142, got %s", ipv4)
+			}
+			if ipv6 != nil {
+				t.Errorf("expected no IPv6 address without an IPv6 resolver, got %s", ipv6)
+			}
+		})
+	}
+}
+
+func TestDNSSourceOpenDNSOverIPv6(t *testing.T) {
+	resolver := &dnsTestResolver{
+		preset: dnsPresets["opendns"],
+		answers: map[uint16][][]byte{
+			dnsTypeAAAA: {net.ParseIP("2a00:1450:4009:81d::200e")},
+		},
+	}
+	source := DNSSource{
+		Preset:    "opendns",
+		Resolvers: []string{resolver.start(t, "udp6", "[::1]:0")},
+		Family:    FamilyIPv6,
+		Timeout:   2 * time.Second,
+	}
+	ipv4, ipv6, err := source.GetPublicIPAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4 != nil {
+		t.Errorf("expected no IPv4 address, got %s", ipv4)
+	}
+	if ipv6.String() != "2a00:1450:4009:81d::200e" {
+		t.Errorf("expected the IPv6 address 2a00:1450:4009:81d::200e, got %s", ipv6)
+	}
+}
+
+func TestDNSSourceFailsOnErrorRcode(t *testing.T) {
+	resolver := &dnsTestResolver{preset: dnsPresets["opendns"], rcode: 3}
+	source := DNSSource{
+		Preset:    "opendns",
+		Resolvers: []string{resolver.start(t, "udp4", "127.0.0.1:0")},
+		Family:    FamilyIPv4,
+		Timeout:   2 * time.Second,
+	}
+	_, _, err := source.GetPublicIPAddresses()
+	if err == nil || !strings.Contains(err.Error(), "rcode 3") {
+		t.Errorf("expected an rcode 3 error, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package ipaddress
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the FRITZ!Box TR-064 base url used when no ipDetailsUrl is configured
+const defaultFritzBoxUrl = "http://fritz.box:49000"
+
+// the FRITZ!Box TR-064 WANIPConnection control url and service type of the IPv4 address, and the IGD WANIPConnection
+// control url and service type of the AVM IPv6 actions, which the TR-064 service does not expose
+const (
+	fritzBoxControlPath    = "/upnp/control/wanipconnection1"
+	fritzBoxServiceType    = "urn:dslforum-org:service:WANIPConnection:1"
+	fritzBoxIGDControlPath = "/igdupnp/control/WANIPConn1"
+	fritzBoxIGDServiceType = "urn:schemas-upnp-org:service:WANIPConnection:1"
+)
+
+/*
+The FritzBox type that has the ability to talk to an AVM FRITZ!Box router over its TR-064 and IGD SOAP interfaces to
+retrieve the public IPv4 address, the public IPv6 address and the delegated IPv6 prefix of the WAN connection
+
+TR-064 and IGD docs: https://avm.de/service/schnittstellen/
+
+TR-064 request url: http://fritz.box:49000/upnp/control/wanipconnection1
+
+actions:
+	GetExternalIPAddress            -> NewExternalIPAddress
+
+IGD request url: http://fritz.box:49000/igdupnp/control/WANIPConn1
+
+actions:
+	X_AVM_DE_GetExternalIPv6Address -> NewExternalIPv6Address, NewPrefixLength
+	X_AVM_DE_GetIPv6Prefix          -> NewIPv6Prefix, NewPrefixLength
+
+The IGD actions require the "transmit status information over UPnP" option of the FRITZ!Box to be enabled.
+
+The router configuration ipDetailsUrl holds the TR-064 base url (http://fritz.box:49000 when empty) and the userName and
+password are used for HTTP digest authentication.
+*/
+type FritzBox struct {
+	Config *config.RouterConfiguration
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider FritzBox) String() string {
+	return "FRITZ!Box IP address provider"
+}
+
+// GetPublicIPAddresses calls the FRITZ!Box TR-064 and IGD WANIPConnection actions to retrieve and return the public IPv4
+// and IPv6 addresses of the WAN connection. A missing address of one family is returned as nil, so DS-Lite and IPv6 only
+// lines report their IPv6 address, and an error is only returned when neither address is available. The delegated IPv6
+// prefix is recorded as a Diagnostic
+func (ipProvider FritzBox) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	if ipProvider.Config == nil {
+		return nil, nil, errors.New("config is nil and it needs to be supplied")
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	var ipv4 net.IP
+	arguments, ipv4Err := ipProvider.call(client, fritzBoxControlPath, fritzBoxServiceType, "GetExternalIPAddress")
+	if ipv4Err == nil {
+		if ip := net.ParseIP(arguments["NewExternalIPAddress"]).To4(); ip != nil && !ip.IsUnspecified() {
+			ipv4 = ip
+		} else {
+			//DS-Lite and IPv6 only lines have no public IPv4 address of their own
+			ipv4Err = fmt.Errorf("the %s reports no public IPv4 address", ipProvider)
+		}
+	}
+	if ipv4Err != nil {
+		log.Printf("The %s could not read the public IPv4 address: %v", ipProvider, ipv4Err)
+	}
+
+	var ipv6 net.IP
+	arguments, err := ipProvider.call(client, fritzBoxIGDControlPath, fritzBoxIGDServiceType,
+		"X_AVM_DE_GetExternalIPv6Address")
+	if err != nil {
+		//IPv4 only lines and older firmware do not support IPv6
+		log.Printf("The %s could not read the public IPv6 address: %v", ipProvider, err)
+	} else if ip := net.ParseIP(arguments["NewExternalIPv6Address"]); ip != nil && !ip.IsUnspecified() {
+		ipv6 = ip
+	}
+
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s reports no public IPv4 or IPv6 address, the WAN connection may be down: %v",
+			ipProvider, ipv4Err)
+	}
+
+	if prefix, err := ipProvider.GetIPv6Prefix(); err == nil {
+		RecordDiagnostic(Diagnostic{Source: "FritzBox", Family: FamilyIPv6, Address: prefix.String(),
+			Detail: "delegated IPv6 prefix"})
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// GetIPv6Prefix calls the FRITZ!Box X_AVM_DE_GetIPv6Prefix IGD action to retrieve and return the delegated IPv6
+// prefix of the WAN connection
+func (ipProvider FritzBox) GetIPv6Prefix() (*net.IPNet, error) {
+	if ipProvider.Config == nil {
+		return nil, errors.New("config is nil and it needs to be supplied")
+	}
+	arguments, err := ipProvider.call(&http.Client{Timeout: 5 * time.Second}, fritzBoxIGDControlPath,
+		fritzBoxIGDServiceType, "X_AVM_DE_GetIPv6Prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := net.ParseIP(arguments["NewIPv6Prefix"])
+	prefixLength, err := strconv.Atoi(arguments["NewPrefixLength"])
+	if prefix == nil || prefix.IsUnspecified() || err != nil || prefixLength <= 0 || prefixLength > 128 {
+		return nil, fmt.Errorf("the %s reports no delegated IPv6 prefix", ipProvider)
+	}
+	return &net.IPNet{IP: prefix.Mask(net.CIDRMask(prefixLength, 128)), Mask: net.CIDRMask(prefixLength, 128)}, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider FritzBox) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//call calls the supplied action of the WANIPConnection service at controlPath and returns its output arguments
+func (ipProvider FritzBox) call(
+	client *http.Client,
+	controlPath string,
+	serviceType string,
+	action string) (map[string]string, error) {
+
+	baseUrl := ipProvider.Config.IpDetailsUrl
+	if baseUrl == "" {
+		baseUrl = defaultFritzBoxUrl
+	}
+	controlURL := strings.TrimSuffix(baseUrl, "/") + controlPath
+
+	arguments, err := soapCall(client, controlURL, serviceType, action,
+		ipProvider.Config.Username, ipProvider.Config.Password)
+	if err != nil {
+		return nil, fmt.Errorf("the %s %s call failed: %v", ipProvider, action, err)
+	}
+	return arguments, nil
+}
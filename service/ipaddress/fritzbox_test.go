@@ -0,0 +1,245 @@
+package ipaddress
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// the SOAP response of the fake FRITZ!Box, filled in with the action, service type and output arguments
+const fritzBoxTestResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body>
+<u:%sResponse xmlns:u="%s">
+%s
+</u:%sResponse>
+</s:Body>
+</s:Envelope>`
+
+// the SOAP fault the fake FRITZ!Box answers an action it does not support with
+const fritzBoxTestFault = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body>
+<s:Fault>
+<faultcode>s:Client</faultcode>
+<faultstring>UPnPError</faultstring>
+<detail>
+<UPnPError xmlns="urn:schemas-upnp-org:control-1-0">
+<errorCode>401</errorCode>
+<errorDescription>Invalid Action</errorDescription>
+</UPnPError>
+</detail>
+</s:Fault>
+</s:Body>
+</s:Envelope>`
+
+//fritzBoxTestServer is a fake FRITZ!Box answering the TR-064 and IGD WANIPConnection actions behind HTTP digest
+//authentication of the user admin with the password secret-password
+type fritzBoxTestServer struct {
+	algorithm string
+	qop       bool
+	ipv4      string
+	ipv6      string
+	prefix    string
+}
+
+//start starts the fake FRITZ!Box and returns a router configuration of the supplied password pointing at it
+func (fritzBox *fritzBoxTestServer) start(t *testing.T, password string) *config.RouterConfiguration {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := fritzBox.checkAuthorization(r); err != nil {
+			challenge := `Digest realm="F!Box SOAP-Auth", nonce="4F2D1C7B9A3E5D60", opaque="6a1f"`
+			if fritzBox.algorithm != "" {
+				challenge += ", algorithm=" + fritzBox.algorithm
+			}
+			if fritzBox.qop {
+				challenge += `, qop="auth"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var serviceType, action, arguments string
+		switch soapAction := r.Header.Get("SOAPAction"); {
+		case r.URL.Path == fritzBoxControlPath && soapAction == `"`+fritzBoxServiceType+`#GetExternalIPAddress"`:
+			serviceType, action = fritzBoxServiceType, "GetExternalIPAddress"
+			arguments = "<NewExternalIPAddress>" + fritzBox.ipv4 + "</NewExternalIPAddress>"
+		case r.URL.Path == fritzBoxIGDControlPath &&
+			soapAction == `"`+fritzBoxIGDServiceType+`#X_AVM_DE_GetExternalIPv6Address"` && fritzBox.ipv6 != "":
+			serviceType, action = fritzBoxIGDServiceType, "X_AVM_DE_GetExternalIPv6Address"
+			arguments = "<NewExternalIPv6Address>" + fritzBox.ipv6 + "</NewExternalIPv6Address>\n" +
+				"<NewPrefixLength>64</NewPrefixLength>\n<NewValidLifetime>7200</NewValidLifetime>"
+		case r.URL.Path == fritzBoxIGDControlPath &&
+			soapAction == `"`+fritzBoxIGDServiceType+`#X_AVM_DE_GetIPv6Prefix"` && fritzBox.prefix != "":
+			serviceType, action = fritzBoxIGDServiceType, "X_AVM_DE_GetIPv6Prefix"
+			prefix := strings.Split(fritzBox.prefix, "/")
+			arguments = "<NewIPv6Prefix>" + prefix[0] + "</NewIPv6Prefix>\n<NewPrefixLength>" + prefix[1] +
+				"</NewPrefixLength>"
+		default:
+			w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, fritzBoxTestFault)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil || !strings.Contains(string(body), fmt.Sprintf(`<u:%s xmlns:u="%s">`, action, serviceType)) {
+			t.Errorf("unexpected %s envelope %s", action, body)
+		}
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		_, _ = fmt.Fprintf(w, fritzBoxTestResponse, action, serviceType, arguments, action)
+	}))
+	t.Cleanup(server.Close)
+	return &config.RouterConfiguration{
+		RouterType:   "FritzBox",
+		Username:     "admin",
+		Password:     password,
+		IpDetailsUrl: server.URL + "/",
+	}
+}
+
+//checkAuthorization verifies the digest Authorization header of the supplied request
+func (fritzBox *fritzBoxTestServer) checkAuthorization(r *http.Request) error {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Digest ") {
+		return fmt.Errorf("no digest authorization")
+	}
+	params := parseDigestChallenge(authorization[len("Digest "):])
+	if params["username"] != "admin" || params["realm"] != "F!Box SOAP-Auth" ||
+		params["nonce"] != "4F2D1C7B9A3E5D60" || params["opaque"] != "6a1f" || params["uri"] != r.URL.RequestURI() {
+		return fmt.Errorf("unexpected digest parameters %v", params)
+	}
+
+	newHash := md5.New
+	if fritzBox.algorithm == "SHA-256" {
+		newHash = sha256.New
+	}
+	digest := func(value string) string {
+		h := newHash()
+		_, _ = io.WriteString(h, value)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	ha1 := digest("admin:F!Box SOAP-Auth:secret-password")
+	ha2 := digest(r.Method + ":" + r.URL.RequestURI())
+	expected := digest(ha1 + ":4F2D1C7B9A3E5D60:" + ha2)
+	if fritzBox.qop {
+		if params["qop"] != "auth" || params["nc"] != "00000001" || params["cnonce"] == "" {
+			return fmt.Errorf("unexpected digest qop parameters %v", params)
+		}
+		expected = digest(ha1 + ":4F2D1C7B9A3E5D60:00000001:" + params["cnonce"] + ":auth:" + ha2)
+	}
+	if params["response"] != expected {
+		return fmt.Errorf("digest response mismatch")
+	}
+	return nil
+}
+
+func TestFritzBoxGetPublicIPAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		fritzBox fritzBoxTestServer
+		ipv4     string
+		ipv6     string
+	}{
+		{"dual stack MD5 digest with qop", fritzBoxTestServer{
+			qop:    true,
+			ipv4:   "81.2.69.142",
+			ipv6:   "2a00:1450:4009:81d::200e",
+			prefix: "2a00:1450:4009:8100::/56",
+		}, "81.2.69.142", "2a00:1450:4009:81d::200e"},
+		{"SHA-256 digest without qop", fritzBoxTestServer{
+			algorithm: "SHA-256",
+			ipv4:      "81.2.69.142",
+			ipv6:      "2a00:1450:4009:81d::200e",
+		}, "81.2.69.142", "2a00:1450:4009:81d::200e"},
+		{"DS-Lite without a public IPv4 address", fritzBoxTestServer{
+			algorithm: "MD5",
+			qop:       true,
+			ipv4:      "0.0.0.0",
+			ipv6:      "2a00:1450:4009:81d::200e",
+		}, "<nil>", "2a00:1450:4009:81d::200e"},
+		{"IPv4 only without the IGD IPv6 actions", fritzBoxTestServer{
+			qop:  true,
+			ipv4: "81.2.69.142",
+		}, "81.2.69.142", "<nil>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := test.fritzBox.start(t, "secret-password")
+			ipv4, ipv6, err := FritzBox{Config: routerConfig}.GetPublicIPAddresses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+		})
+	}
+}
+
+func TestFritzBoxGetIPv6Prefix(t *testing.T) {
+	fritzBox := fritzBoxTestServer{qop: true, ipv4: "81.2.69.142", prefix: "2a00:1450:4009:8100::/56"}
+	prefix, err := FritzBox{Config: fritzBox.start(t, "secret-password")}.GetIPv6Prefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix.String() != "2a00:1450:4009:8100::/56" {
+		t.Errorf("expected the prefix 2a00:1450:4009:8100::/56, got %s", prefix)
+	}
+}
+
+func TestFritzBoxGetPublicIPAddressesFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		fritzBox fritzBoxTestServer
+		password string
+		expected string
+	}{
+		{"no address of either family", fritzBoxTestServer{qop: true, ipv4: "0.0.0.0"},
+			"secret-password", "reports no public IPv4 or IPv6 address"},
+		{"rejected digest authentication", fritzBoxTestServer{qop: true, ipv4: "81.2.69.142"},
+			"wrong-password", "digest authentication of user admin was rejected"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := test.fritzBox.start(t, test.password)
+			_, _, err := FritzBox{Config: routerConfig}.GetPublicIPAddresses()
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected an error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestDigestAuthorizationUnsupportedAlgorithm(t *testing.T) {
+	request := httptest.NewRequest(http.MethodPost, "http://fritz.box:49000/upnp/control/wanipconnection1", nil)
+	_, err := digestAuthorization(`Digest realm="r", nonce="n", algorithm=SHA-512-256`, request.Method, request.URL,
+		"admin", "secret-password")
+	if err == nil || !strings.Contains(err.Error(), "unsupported digest algorithm") {
+		t.Errorf("expected an unsupported digest algorithm error, got %v", err)
+	}
+}
+
+func TestParseSOAPResponse(t *testing.T) {
+	responseBytes := []byte(fmt.Sprintf(fritzBoxTestResponse, "X_AVM_DE_GetIPv6Prefix", fritzBoxIGDServiceType,
+		"<NewIPv6Prefix> 2a00:1450:4009:8100:: </NewIPv6Prefix><NewPrefixLength>56</NewPrefixLength>",
+		"X_AVM_DE_GetIPv6Prefix"))
+	arguments, err := parseSOAPResponse(responseBytes, "X_AVM_DE_GetIPv6PrefixResponse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arguments["NewIPv6Prefix"] != "2a00:1450:4009:8100::" || arguments["NewPrefixLength"] != "56" {
+		t.Errorf("unexpected arguments %v", arguments)
+	}
+
+	if _, err = parseSOAPResponse(responseBytes, "GetExternalIPAddressResponse"); err == nil {
+		t.Error("expected an error for a missing response element")
+	}
+}
@@ -0,0 +1,73 @@
+package ipaddress
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// the RTF_GATEWAY route flag
+const rtfGateway = 0x2
+
+// GetDefaultGateway returns the IPv4 default gateway read from the /proc/net/route routing table
+func GetDefaultGateway() (net.IP, error) {
+	routes, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := routes.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	//Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+	scanner := bufio.NewScanner(routes)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		gateway, err := parseRouteAddress(fields[2])
+		if err != nil {
+			continue
+		}
+		return gateway, nil
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no IPv4 default route was found")
+}
+
+//parseRouteAddress parses an address of the routing table. The kernel prints the network byte order address as a
+//native byte order integer, so the address bytes are the native byte order bytes of that integer
+func parseRouteAddress(field string) (net.IP, error) {
+	value, err := strconv.ParseUint(field, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	address := make(net.IP, net.IPv4len)
+	nativeByteOrder().PutUint32(address, uint32(value))
+	return address, nil
+}
+
+//nativeByteOrder returns the byte order of this host
+func nativeByteOrder() binary.ByteOrder {
+	value := uint16(1)
+	if *(*byte)(unsafe.Pointer(&value)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
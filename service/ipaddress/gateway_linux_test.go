@@ -0,0 +1,34 @@
+package ipaddress
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseRouteAddress(t *testing.T) {
+	//192.168.1.254 as printed by the kernel on little and big endian hosts
+	field := "FE01A8C0"
+	if nativeByteOrder() == binary.BigEndian {
+		field = "C0A801FE"
+	}
+	gateway, err := parseRouteAddress(field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gateway.String() != "192.168.1.254" {
+		t.Errorf("expected the gateway 192.168.1.254, got %s", gateway)
+	}
+	if _, err = parseRouteAddress("C0A801FE00"); err == nil {
+		t.Error("expected an address of more than 32 bits to be rejected")
+	}
+}
+
+func TestGetDefaultGateway(t *testing.T) {
+	gateway, err := GetDefaultGateway()
+	if err != nil {
+		t.Skipf("no IPv4 default route: %v", err)
+	}
+	if gateway.To4() == nil || gateway.IsUnspecified() {
+		t.Errorf("expected an IPv4 default gateway, got %s", gateway)
+	}
+}
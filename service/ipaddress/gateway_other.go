@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package ipaddress
+
+import (
+	"errors"
+	"net"
+)
+
+// GetDefaultGateway is only implemented on linux, the gateway needs to be configured explicitly elsewhere
+func GetDefaultGateway() (net.IP, error) {
+	return nil, errors.New("default gateway discovery is not supported on this platform")
+}
@@ -0,0 +1,252 @@
+package ipaddress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP IP address source response extractors
+const (
+	ExtractorText  = "text"
+	ExtractorJSON  = "json"
+	ExtractorRegex = "regex"
+)
+
+// IP address families
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// the timeout applied to HTTP IP address source requests when no Timeout is set
+const defaultHTTPSourceTimeout = 10 * time.Second
+
+/*
+The HTTPSource type that has the ability to talk to any HTTP echo service to retrieve a public IP address
+
+The public IP address is extracted from the response body as plain text, with a JSON path such as $.ip or with a regular
+expression. When the expression contains a capture group the first group is used, otherwise the whole match is used.
+
+sample plain text response (https://api4.ipify.org): 255.255.255.255
+
+sample json response (https://ifconfig.co/json): {"ip": "255.255.255.255", "ip_decimal": 4294967295}
+
+sample regex response (https://1.1.1.1/cdn-cgi/trace):
+fl=123f1
+h=1.1.1.1
+ip=255.255.255.255
+ts=1623327012.893
+*/
+type HTTPSource struct {
+	Name        string        // An optional name used in logging, defaults to the URL
+	URL         string        // The echo service URL
+	Family      string        // ipv4, ipv6 or empty when the source may return an address of either family
+	ForceFamily bool          // When true the URL is dialed over the configured Family only
+	Extractor   string        // text (the default), json or regex
+	Expression  string        // The JSON path or regular expression used by the json and regex extractors
+	Timeout     time.Duration // The request timeout
+}
+
+// HTTPSourcePresets are the built-in HTTPSource configurations that may be referred to by name
+var HTTPSourcePresets = map[string]HTTPSource{
+	"ipify-v4": {
+		Name: "ipify-v4", URL: "https://api4.ipify.org", Family: FamilyIPv4, ForceFamily: true, Extractor: ExtractorText},
+	"ipify-v6": {
+		Name: "ipify-v6", URL: "https://api6.ipify.org", Family: FamilyIPv6, ForceFamily: true, Extractor: ExtractorText},
+	"icanhazip-v4": {
+		Name: "icanhazip-v4", URL: "https://ipv4.icanhazip.com", Family: FamilyIPv4, ForceFamily: true,
+		Extractor: ExtractorText},
+	"icanhazip-v6": {
+		Name: "icanhazip-v6", URL: "https://ipv6.icanhazip.com", Family: FamilyIPv6, ForceFamily: true,
+		Extractor: ExtractorText},
+	"ifconfig.co-v4": {
+		Name: "ifconfig.co-v4", URL: "https://ifconfig.co/json", Family: FamilyIPv4, ForceFamily: true,
+		Extractor: ExtractorJSON, Expression: "$.ip"},
+	"ifconfig.co-v6": {
+		Name: "ifconfig.co-v6", URL: "https://ifconfig.co/json", Family: FamilyIPv6, ForceFamily: true,
+		Extractor: ExtractorJSON, Expression: "$.ip"},
+	"cloudflare-v4": {
+		Name: "cloudflare-v4", URL: "https://1.1.1.1/cdn-cgi/trace", Family: FamilyIPv4, ForceFamily: true,
+		Extractor: ExtractorRegex, Expression: `(?m)^ip=(\S+)$`},
+	"cloudflare-v6": {
+		Name: "cloudflare-v6", URL: "https://[2606:4700:4700::1111]/cdn-cgi/trace", Family: FamilyIPv6,
+		ForceFamily: true, Extractor: ExtractorRegex, Expression: `(?m)^ip=(\S+)$`},
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider HTTPSource) String() string {
+	name := ipProvider.Name
+	if name == "" {
+		name = ipProvider.URL
+	}
+	return fmt.Sprintf("%s HTTP IP address provider", name)
+}
+
+// GetPublicIPAddresses performs a HTTP request to the configured URL and returns the public IP address extracted from
+// the response as either the IPv4 or the IPv6 address
+func (ipProvider HTTPSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	body, err := ipProvider.getResponseBody()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	value, err := ipProvider.extract(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(value))
+	if ip == nil {
+		return nil, nil, fmt.Errorf("the %s returned '%s' which is not an IP address", ipProvider, value)
+	}
+
+	var ipv4, ipv6 net.IP
+	if ip.To4() != nil {
+		ipv4 = ip.To4()
+	} else {
+		ipv6 = ip
+	}
+	if (ipProvider.Family == FamilyIPv4 && ipv4 == nil) || (ipProvider.Family == FamilyIPv6 && ipv6 == nil) {
+		return nil, nil, fmt.Errorf("the %s returned %s which is not an %s address", ipProvider, ip, ipProvider.Family)
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider HTTPSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getResponseBody performs the HTTP GET request to the configured URL, dialing over the configured Family only when
+//ForceFamily is set
+func (ipProvider HTTPSource) getResponseBody() ([]byte, error) {
+	timeout := ipProvider.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPSourceTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ipProvider.ForceFamily && ipProvider.Family != "" {
+		network := "tcp4"
+		if ipProvider.Family == FamilyIPv6 {
+			network = "tcp6"
+		}
+		dialer := &net.Dialer{Timeout: timeout}
+		transport.DialContext = func(ctx context.Context, _, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	defer client.CloseIdleConnections()
+
+	response, err := client.Get(ipProvider.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		err := response.Body.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the %s returned http status code %d", ipProvider, response.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(response.Body, 64*1024))
+}
+
+//extract returns the IP address string extracted from the supplied response body by the configured Extractor
+func (ipProvider HTTPSource) extract(body []byte) (string, error) {
+	switch ipProvider.Extractor {
+	case "", ExtractorText:
+		return string(body), nil
+	case ExtractorJSON:
+		var document interface{}
+		if err := json.Unmarshal(body, &document); err != nil {
+			return "", err
+		}
+		value, err := evaluateJSONPath(document, ipProvider.Expression)
+		if err != nil {
+			return "", fmt.Errorf("the %s response could not be read with %s: %v", ipProvider, ipProvider.Expression, err)
+		}
+		return value, nil
+	case ExtractorRegex:
+		expression, err := regexp.Compile(ipProvider.Expression)
+		if err != nil {
+			return "", err
+		}
+		match := expression.FindSubmatch(body)
+		if match == nil {
+			return "", fmt.Errorf("the %s response did not match %s", ipProvider, ipProvider.Expression)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	default:
+		return "", fmt.Errorf("unsupported HTTP IP address source extractor %s", ipProvider.Extractor)
+	}
+}
+
+//evaluateJSONPath evaluates a simple JSON path made of object keys and array indexes such as $.ip or $.addresses[0].ip
+//against the supplied decoded json document and returns the string value found
+func evaluateJSONPath(document interface{}, path string) (string, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return "", errors.New("an empty JSON path was supplied")
+	}
+
+	current := document
+	for _, segment := range strings.Split(path, ".") {
+		key := segment
+		var indexes []int
+		if bracket := strings.Index(segment, "["); bracket >= 0 {
+			key = segment[:bracket]
+			for _, indexStr := range strings.Split(strings.TrimSuffix(segment[bracket+1:], "]"), "][") {
+				index, err := strconv.Atoi(indexStr)
+				if err != nil {
+					return "", fmt.Errorf("invalid array index in path segment %s", segment)
+				}
+				indexes = append(indexes, index)
+			}
+		}
+
+		if key != "" {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("%s is not an object", key)
+			}
+			if current, ok = object[key]; !ok {
+				return "", fmt.Errorf("%s was not found", key)
+			}
+		}
+		for _, index := range indexes {
+			array, ok := current.([]interface{})
+			if !ok || index < 0 || index >= len(array) {
+				return "", fmt.Errorf("index %d of %s is out of range", index, segment)
+			}
+			current = array[index]
+		}
+	}
+
+	value, ok := current.(string)
+	if !ok {
+		return "", fmt.Errorf("the value at %s is not a string", path)
+	}
+	return value, nil
+}
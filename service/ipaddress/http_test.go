@@ -0,0 +1,183 @@
+package ipaddress
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// a json echo service response with nested objects and arrays
+const httpTestJSONResponse = `{
+	"ip": "81.2.69.142",
+	"ip_decimal": 1359103374,
+	"reverse": null,
+	"asn": {"id": "AS20712", "org": "Andrews & Arnold Ltd"},
+	"addresses": [
+		{"family": "ipv4", "ip": "81.2.69.142"},
+		{"family": "ipv6", "ip": "2a00:1450:4009:81d::200e", "tags": ["primary", "global"]}
+	],
+	"matrix": [["81.2.69.143", "81.2.69.144"], ["2a00:1450:4009:81d::200f"]],
+	"forwarded": true
+}`
+
+// the cloudflare trace response of an IPv4 connection
+const httpTestTraceResponse = `fl=123f1
+h=1.1.1.1
+ip=81.2.69.142
+ts=1623327012.893
+visit_scheme=https
+`
+
+func TestEvaluateJSONPath(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(httpTestJSONResponse), &document); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		expected string
+		err      string
+	}{
+		{"$.ip", "81.2.69.142", ""},
+		{"ip", "81.2.69.142", ""},
+		{".ip", "81.2.69.142", ""},
+		{"$.asn.org", "Andrews & Arnold Ltd", ""},
+		{"$.addresses[0].ip", "81.2.69.142", ""},
+		{"$.addresses[1].ip", "2a00:1450:4009:81d::200e", ""},
+		{"$.addresses[1].tags[1]", "global", ""},
+		{"$.matrix[0][1]", "81.2.69.144", ""},
+		{"$.matrix[1][0]", "2a00:1450:4009:81d::200f", ""},
+		{"$", "", "an empty JSON path was supplied"},
+		{"", "", "an empty JSON path was supplied"},
+		{"$.address", "", "address was not found"},
+		{"$.asn.name", "", "name was not found"},
+		{"$.ip.address", "", "address is not an object"},
+		{"$.addresses.ip", "", "ip is not an object"},
+		{"$.addresses[2].ip", "", "index 2 of addresses[2] is out of range"},
+		{"$.addresses[-1].ip", "", "index -1 of addresses[-1] is out of range"},
+		{"$.matrix[1][1]", "", "index 1 of matrix[1][1] is out of range"},
+		{"$.asn[0]", "", "index 0 of asn[0] is out of range"},
+		{"$.addresses[first].ip", "", "invalid array index in path segment addresses[first]"},
+		{"$.ip_decimal", "", "the value at ip_decimal is not a string"},
+		{"$.reverse", "", "the value at reverse is not a string"},
+		{"$.forwarded", "", "the value at forwarded is not a string"},
+		{"$.asn", "", "the value at asn is not a string"},
+		{"$.addresses", "", "the value at addresses is not a string"},
+	}
+	for _, test := range tests {
+		value, err := evaluateJSONPath(document, test.path)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected the error %q evaluating %q, got %q %v", test.err, test.path, value, err)
+			}
+			continue
+		}
+		if err != nil || value != test.expected {
+			t.Errorf("expected %q evaluating %q, got %q %v", test.expected, test.path, value, err)
+		}
+	}
+}
+
+func TestHTTPSourceExtract(t *testing.T) {
+	tests := []struct {
+		name       string
+		extractor  string
+		expression string
+		body       string
+		expected   string
+		err        string
+	}{
+		{"text by default", "", "", "81.2.69.142\n", "81.2.69.142\n", ""},
+		{"text", ExtractorText, "", "2a00:1450:4009:81d::200e", "2a00:1450:4009:81d::200e", ""},
+		{"json", ExtractorJSON, "$.addresses[1].ip", httpTestJSONResponse, "2a00:1450:4009:81d::200e", ""},
+		{"json missing path", ExtractorJSON, "$.client.ip", httpTestJSONResponse, "",
+			"the test HTTP IP address provider response could not be read with $.client.ip: client was not found"},
+		{"json non string value", ExtractorJSON, "$.ip_decimal", httpTestJSONResponse, "",
+			"the test HTTP IP address provider response could not be read with $.ip_decimal: the value at ip_decimal " +
+				"is not a string"},
+		{"malformed json", ExtractorJSON, "$.ip", "ip=81.2.69.142", "", "invalid character"},
+		{"regex capture group", ExtractorRegex, `(?m)^ip=(\S+)$`, httpTestTraceResponse, "81.2.69.142", ""},
+		{"regex first of several capture groups", ExtractorRegex, `(?m)^(ip)=(\S+)$`, httpTestTraceResponse, "ip",
+			""},
+		{"regex without a capture group", ExtractorRegex, `\d+\.\d+\.\d+\.\d+`, httpTestTraceResponse, "1.1.1.1", ""},
+		{"regex without a capture group anchored", ExtractorRegex, `(?m)^ip=\S+$`, httpTestTraceResponse,
+			"ip=81.2.69.142", ""},
+		{"regex non capturing group", ExtractorRegex, `(?m)^(?:ip)=\S+$`, httpTestTraceResponse, "ip=81.2.69.142",
+			""},
+		{"regex no match", ExtractorRegex, `(?m)^ipv6=(\S+)$`, httpTestTraceResponse, "",
+			"the test HTTP IP address provider response did not match (?m)^ipv6=(\\S+)$"},
+		{"invalid regex", ExtractorRegex, `ip=(\S+`, httpTestTraceResponse, "", "error parsing regexp"},
+		{"unsupported extractor", "xpath", "//ip", "<ip>81.2.69.142</ip>", "",
+			"unsupported HTTP IP address source extractor xpath"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ipProvider := HTTPSource{Name: "test", Extractor: test.extractor, Expression: test.expression}
+			value, err := ipProvider.extract([]byte(test.body))
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Errorf("expected an error starting %q, got %q %v", test.err, value, err)
+				}
+				return
+			}
+			if err != nil || value != test.expected {
+				t.Errorf("expected %q, got %q %v", test.expected, value, err)
+			}
+		})
+	}
+}
+
+func TestHTTPSourceGetPublicIPAddresses(t *testing.T) {
+	tests := []struct {
+		name       string
+		family     string
+		extractor  string
+		expression string
+		statusCode int
+		body       string
+		ipv4       string
+		ipv6       string
+		err        string
+	}{
+		{"text IPv4", "", ExtractorText, "", http.StatusOK, "81.2.69.142\n", "81.2.69.142", "<nil>", ""},
+		{"json IPv6", FamilyIPv6, ExtractorJSON, "$.addresses[1].ip", http.StatusOK, httpTestJSONResponse, "<nil>",
+			"2a00:1450:4009:81d::200e", ""},
+		{"regex IPv4", FamilyIPv4, ExtractorRegex, `(?m)^ip=(\S+)$`, http.StatusOK, httpTestTraceResponse,
+			"81.2.69.142", "<nil>", ""},
+		{"wrong family", FamilyIPv6, ExtractorText, "", http.StatusOK, "81.2.69.142", "", "",
+			"the test HTTP IP address provider returned 81.2.69.142 which is not an ipv6 address"},
+		{"regex match that is not an address", "", ExtractorRegex, `(?m)^ts=(\S+)$`, http.StatusOK,
+			httpTestTraceResponse, "", "", "the test HTTP IP address provider returned '1623327012.893' which is not an " +
+				"IP address"},
+		{"http error", "", ExtractorText, "", http.StatusTooManyRequests, "rate limited", "", "",
+			"the test HTTP IP address provider returned http status code 429"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statusCode)
+				_, _ = io.WriteString(w, test.body)
+			}))
+			defer server.Close()
+
+			ipProvider := HTTPSource{Name: "test", URL: server.URL, Family: test.family, Extractor: test.extractor,
+				Expression: test.expression}
+			ipv4, ipv6, err := ipProvider.GetPublicIPAddresses()
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("expected the error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+		})
+	}
+}
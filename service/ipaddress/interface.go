@@ -0,0 +1,222 @@
+package ipaddress
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// the address ranges that are never public facing, excluded by the InterfaceSource and GetIPv6
+var nonPublicRanges = parseCIDRs(
+	"0.0.0.0/8",       // this network
+	"10.0.0.0/8",      // RFC1918
+	"100.64.0.0/10",   // RFC6598 carrier grade NAT
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local
+	"172.16.0.0/12",   // RFC1918
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // RFC5737 documentation (TEST-NET-1)
+	"192.168.0.0/16",  // RFC1918
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // RFC5737 documentation (TEST-NET-2)
+	"203.0.113.0/24",  // RFC5737 documentation (TEST-NET-3)
+	"224.0.0.0/3",     // multicast and reserved
+	"::/128",          // unspecified
+	"::1/128",         // loopback
+	"64:ff9b::/96",    // NAT64
+	"2001:db8::/32",   // RFC3849 documentation
+	"fc00::/7",        // unique local addresses
+	"fe80::/10",       // link-local
+	"ff00::/8",        // multicast
+)
+
+// the RFC6598 shared address space used by carrier grade NAT
+var carrierGradeNATRange = parseCIDRs("100.64.0.0/10")
+
+/*
+The InterfaceSource type that has the ability to read the public IP addresses straight from a local network interface
+such as ppp0, wan or eth1, for hosts that sit directly on the WAN
+
+Private (RFC1918), carrier grade NAT (100.64.0.0/10), unique local (fc00::/7), documentation, link-local, loopback and
+multicast addresses are never selected. On linux temporary (privacy extension), deprecated, tentative and DAD failed IPv6
+addresses are skipped as well, their flags are read from /proc/net/if_inet6.
+
+Addresses within an Exclude range are never selected and addresses within a Prefer range are selected ahead of any
+other address of the same family, in the order of the Prefer ranges.
+*/
+type InterfaceSource struct {
+	Interface string   // The network interface name, all interfaces are considered when empty
+	Family    string   // ipv4, ipv6 or empty to read both address families
+	Prefer    []string // CIDR ranges to select addresses from first
+	Exclude   []string // CIDR ranges to never select addresses from
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider InterfaceSource) String() string {
+	if ipProvider.Interface == "" {
+		return "network interface IP address provider"
+	}
+	return fmt.Sprintf("%s network interface IP address provider", ipProvider.Interface)
+}
+
+// GetPublicIPAddresses reads the addresses of the configured network interface and returns the selected public IPv4
+// and IPv6 addresses. An error is returned when no public address is found for the configured families
+func (ipProvider InterfaceSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	prefer, err := parseCIDRList(ipProvider.Prefer)
+	if err != nil {
+		return nil, nil, err
+	}
+	exclude, err := parseCIDRList(ipProvider.Exclude)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addresses, err := getInterfaceAddresses(ipProvider.Interface)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ipv4, ipv6 net.IP
+	if ipProvider.Family != FamilyIPv6 {
+		ipv4 = selectAddress(addresses, FamilyIPv4, prefer, exclude)
+	}
+	if ipProvider.Family != FamilyIPv4 {
+		ipv6 = selectAddress(addresses, FamilyIPv6, prefer, exclude)
+	}
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s found no public IP address", ipProvider)
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider InterfaceSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getInterfaceAddresses returns the addresses of the named network interface or of all interfaces when no name is given
+func getInterfaceAddresses(name string) ([]net.IP, error) {
+	var addresses []net.Addr
+	if name == "" {
+		interfaceAddresses, err := net.InterfaceAddrs()
+		if err != nil {
+			return nil, err
+		}
+		addresses = interfaceAddresses
+	} else {
+		networkInterface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read network interface %s: %v", name, err)
+		}
+		interfaceAddresses, err := networkInterface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the addresses of network interface %s: %v", name, err)
+		}
+		addresses = interfaceAddresses
+	}
+
+	var ips []net.IP
+	for _, address := range addresses {
+		switch value := address.(type) {
+		case *net.IPNet:
+			ips = append(ips, value.IP)
+		case *net.IPAddr:
+			ips = append(ips, value.IP)
+		}
+	}
+	return ips, nil
+}
+
+//selectAddress returns the first public address of the supplied family that is not excluded, addresses within a
+//preferred range are returned ahead of all others
+func selectAddress(addresses []net.IP, family string, prefer []*net.IPNet, exclude []*net.IPNet) net.IP {
+	var unusableIPv6 map[string]bool
+	if family == FamilyIPv6 {
+		unusableIPv6 = getUnusableIPv6Addresses()
+	}
+
+	var candidates []net.IP
+	for _, ip := range addresses {
+		if (ip.To4() != nil) != (family == FamilyIPv4) {
+			continue
+		}
+		if family == FamilyIPv4 {
+			ip = ip.To4()
+		}
+		if !IsPublicAddress(ip) || containsAddress(exclude, ip) || unusableIPv6[ip.String()] {
+			continue
+		}
+		candidates = append(candidates, ip)
+	}
+
+	for _, network := range prefer {
+		for _, ip := range candidates {
+			if network.Contains(ip) {
+				return ip
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+	return nil
+}
+
+// IsPublicAddress returns an indicator that describes if the supplied address is a global address, outside of all
+// private, carrier grade NAT, documentation, loopback, link-local, multicast and other non public ranges
+func IsPublicAddress(ip net.IP) bool {
+	return ip != nil && !containsAddress(nonPublicRanges, ip)
+}
+
+// IsCarrierGradeNATAddress returns an indicator that describes if the supplied address is within the RFC6598 carrier
+// grade NAT shared address space 100.64.0.0/10
+func IsCarrierGradeNATAddress(ip net.IP) bool {
+	return ip != nil && containsAddress(carrierGradeNATRange, ip)
+}
+
+//containsAddress returns an indicator that describes if any of the supplied networks contains the address
+func containsAddress(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//parseCIDRList parses configured CIDR ranges, a bare address is treated as a single address range
+func parseCIDRList(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address range %s", value)
+			}
+			if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address range %s", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+//parseCIDRs parses the supplied built-in CIDR ranges
+func parseCIDRs(values ...string) []*net.IPNet {
+	networks, err := parseCIDRList(values)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
@@ -0,0 +1,55 @@
+package ipaddress
+
+import (
+	"bufio"
+	"encoding/hex"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// the IPv6 address flags of /proc/net/if_inet6 that make an address unsuitable as the public IPv6 address
+const (
+	ifaFlagTemporary  = 0x01
+	ifaFlagDadFailed  = 0x08
+	ifaFlagDeprecated = 0x20
+	ifaFlagTentative  = 0x40
+	ifaFlagsUnusable  = ifaFlagTemporary | ifaFlagDadFailed | ifaFlagDeprecated | ifaFlagTentative
+)
+
+//getUnusableIPv6Addresses returns the set of temporary, deprecated, tentative and DAD failed IPv6 addresses read from
+//the /proc/net/if_inet6 file, an empty set is returned when the file cannot be read
+func getUnusableIPv6Addresses() map[string]bool {
+	unusable := make(map[string]bool)
+	addresses, err := os.Open("/proc/net/if_inet6")
+	if err != nil {
+		return unusable
+	}
+	defer func() {
+		err := addresses.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	//address ifindex prefixlen scope flags name
+	scanner := bufio.NewScanner(addresses)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[4], 16, 32)
+		if err != nil || flags&ifaFlagsUnusable == 0 {
+			continue
+		}
+		addressBytes, err := hex.DecodeString(fields[0])
+		if err != nil || len(addressBytes) != net.IPv6len {
+			continue
+		}
+		unusable[net.IP(addressBytes).String()] = true
+	}
+	return unusable
+}
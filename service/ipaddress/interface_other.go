@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package ipaddress
+
+//getUnusableIPv6Addresses is only implemented on linux, IPv6 address flags are not available elsewhere
+func getUnusableIPv6Addresses() map[string]bool {
+	return map[string]bool{}
+}
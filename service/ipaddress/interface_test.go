@@ -0,0 +1,30 @@
+package ipaddress
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"81.2.69.142", true},
+		{"2a00:1450:4009:81d::200e", true},
+		{"10.0.0.1", false},
+		{"100.64.0.1", false},
+		{"192.168.1.254", false},
+		{"192.0.2.1", false},
+		{"198.51.100.1", false},
+		{"203.0.113.1", false},
+		{"2001:db8::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+	for _, test := range tests {
+		if public := IsPublicAddress(net.ParseIP(test.address)); public != test.public {
+			t.Errorf("expected IsPublicAddress(%s) to be %t, got %t", test.address, test.public, public)
+		}
+	}
+}
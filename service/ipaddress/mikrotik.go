@@ -0,0 +1,167 @@
+package ipaddress
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// the MikroTik RouterOS REST API base url used when no ipDetailsUrl is configured
+const defaultMikroTikUrl = "https://192.168.88.1/rest"
+
+/*
+The MikroTik type that has the ability to talk to a MikroTik RouterOS v7 router over its REST API to retrieve the public
+IPv4 address, the public IPv6 address and the delegated IPv6 prefix of the WAN interface
+
+RouterOS REST API docs: https://help.mikrotik.com/docs/display/ROS/REST+API
+
+request urls:
+	https://192.168.88.1/rest/ip/address?interface=ether1
+	https://192.168.88.1/rest/ipv6/address?interface=ether1
+	https://192.168.88.1/rest/ipv6/dhcp-client
+
+sample json responses:
+	[{".id": "*1", "address": "255.255.255.255/24", "interface": "ether1", "disabled": "false", "invalid": "false"}]
+	[{".id": "*2", "address": "2a00:2a00:2a00:2a00::1/64", "interface": "ether1", "link-local": "false"}]
+	[{".id": "*3", "interface": "ether1", "prefix": "2a00:2a00:2a00:2a00::/56, 2d23h59m", "status": "bound"}]
+
+The router configuration ipDetailsUrl holds the REST API base url, userName and password are used for basic
+authentication and interface is the WAN interface name (ether1 when empty).
+*/
+type MikroTik struct {
+	Config *config.RouterConfiguration
+}
+
+//routerOSAddress is a partial model of a RouterOS /ip/address and /ipv6/address REST API entry
+type routerOSAddress struct {
+	Address   string `json:"address"`
+	Interface string `json:"interface"`
+	Disabled  string `json:"disabled"`
+	Invalid   string `json:"invalid"`
+	LinkLocal string `json:"link-local"`
+}
+
+//routerOSDhcpClient is a partial model of a RouterOS /ipv6/dhcp-client REST API entry
+type routerOSDhcpClient struct {
+	Interface string `json:"interface"`
+	Prefix    string `json:"prefix"`
+	Status    string `json:"status"`
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider MikroTik) String() string {
+	return "MikroTik RouterOS IP address provider"
+}
+
+// GetPublicIPAddresses performs RouterOS REST API requests to retrieve and return the public IPv4 and IPv6 addresses of
+// the WAN interface. A missing address of one family is returned as nil and an error is only returned when neither
+// address is available. The delegated IPv6 prefix is recorded as a Diagnostic
+func (ipProvider MikroTik) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	if ipProvider.Config == nil {
+		return nil, nil, errors.New("config is nil and it needs to be supplied")
+	}
+	client := getRouterHttpClient(ipProvider.Config)
+
+	var ipv4Addresses []routerOSAddress
+	if err := ipProvider.get(client, "/ip/address", &ipv4Addresses); err != nil {
+		return nil, nil, err
+	}
+	var ipv4 net.IP
+	for _, address := range ipv4Addresses {
+		if ip, _ := parseAddressPrefix(address.Address); address.isUsable() && isUsableIPv4(ip) {
+			ipv4 = ip.To4()
+			break
+		}
+	}
+
+	var ipv6 net.IP
+	var ipv6Addresses []routerOSAddress
+	if err := ipProvider.get(client, "/ipv6/address", &ipv6Addresses); err != nil {
+		//the IPv6 package may be disabled
+		log.Printf("The %s could not read the IPv6 addresses: %v", ipProvider, err)
+	}
+	for _, address := range ipv6Addresses {
+		if ip, _ := parseAddressPrefix(address.Address); address.isUsable() && isGlobalIPv6(ip) {
+			ipv6 = ip
+			break
+		}
+	}
+
+	if prefix, err := ipProvider.getIPv6Prefix(client); err == nil {
+		RecordDiagnostic(Diagnostic{Source: "MikroTik", Family: FamilyIPv6, Address: prefix.String(),
+			Detail: "delegated IPv6 prefix"})
+	}
+
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s reports no public IPv4 or IPv6 address on interface %s",
+			ipProvider, ipProvider.getInterface())
+	}
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// GetIPv6Prefix performs a RouterOS REST API request to retrieve and return the IPv6 prefix delegated to the DHCPv6
+// client of the WAN interface
+func (ipProvider MikroTik) GetIPv6Prefix() (*net.IPNet, error) {
+	if ipProvider.Config == nil {
+		return nil, errors.New("config is nil and it needs to be supplied")
+	}
+	return ipProvider.getIPv6Prefix(getRouterHttpClient(ipProvider.Config))
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider MikroTik) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getIPv6Prefix returns the prefix of the first bound DHCPv6 client of the WAN interface
+func (ipProvider MikroTik) getIPv6Prefix(client *http.Client) (*net.IPNet, error) {
+	var dhcpClients []routerOSDhcpClient
+	if err := ipProvider.get(client, "/ipv6/dhcp-client", &dhcpClients); err != nil {
+		return nil, err
+	}
+	for _, dhcpClient := range dhcpClients {
+		if dhcpClient.Interface != ipProvider.getInterface() || dhcpClient.Status != "bound" {
+			continue
+		}
+		//the prefix is followed by its remaining lifetime: 2a00:2a00:2a00:2a00::/56, 2d23h59m
+		prefixStr := strings.TrimSpace(strings.Split(dhcpClient.Prefix, ",")[0])
+		if _, prefix, err := net.ParseCIDR(prefixStr); err == nil {
+			return prefix, nil
+		}
+	}
+	return nil, fmt.Errorf("the %s reports no delegated IPv6 prefix on interface %s", ipProvider, ipProvider.getInterface())
+}
+
+//get performs a RouterOS REST API GET request for the supplied path, filtered to the WAN interface where the path
+//supports it, and decodes the json response into result
+func (ipProvider MikroTik) get(client *http.Client, path string, result interface{}) error {
+	baseUrl := ipProvider.Config.IpDetailsUrl
+	if baseUrl == "" {
+		baseUrl = defaultMikroTikUrl
+	}
+	requestUrl := strings.TrimSuffix(baseUrl, "/") + path
+	if path != "/ipv6/dhcp-client" {
+		requestUrl += "?interface=" + url.QueryEscape(ipProvider.getInterface())
+	}
+	return performRouterJsonRequest(client, http.MethodGet, requestUrl,
+		ipProvider.Config.Username, ipProvider.Config.Password, nil, nil, result)
+}
+
+//getInterface returns the configured WAN interface name
+func (ipProvider MikroTik) getInterface() string {
+	if ipProvider.Config.Interface == "" {
+		return "ether1"
+	}
+	return ipProvider.Config.Interface
+}
+
+//isUsable returns an indicator that describes if the RouterOS address entry is enabled, valid and not link-local
+func (address routerOSAddress) isUsable() bool {
+	return address.Disabled != "true" && address.Invalid != "true" && address.LinkLocal != "true"
+}
@@ -0,0 +1,124 @@
+package ipaddress
+
+import (
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// the RouterOS /ip/address entries of ether1, a disabled address ahead of the active one
+const routerOSTestIPv4Addresses = `[
+	{".id": "*1", "address": "81.2.69.200/22", "network": "81.2.68.0", "interface": "ether1", "actual-interface": "ether1", "disabled": "true", "dynamic": "false", "invalid": "false"},
+	{".id": "*2", "address": "81.2.69.142/22", "network": "81.2.68.0", "interface": "ether1", "actual-interface": "ether1", "disabled": "false", "dynamic": "true", "invalid": "false"}
+]`
+
+// the RouterOS /ipv6/address entries of ether1, the link-local address ahead of the global one
+const routerOSTestIPv6Addresses = `[
+	{".id": "*3", "address": "fe80::4e5e:cff:fe10:1/64", "interface": "ether1", "link-local": "true", "disabled": "false", "invalid": "false"},
+	{".id": "*4", "address": "2a00:1450:4009:81d::200e/64", "interface": "ether1", "link-local": "false", "disabled": "false", "invalid": "false"}
+]`
+
+// the RouterOS /ipv6/dhcp-client entries, a bound /56 delegation on ether1
+const routerOSTestDhcpClients = `[
+	{".id": "*1", "interface": "ether2", "prefix": "", "status": "searching..."},
+	{".id": "*2", "interface": "ether1", "prefix": "2a00:1450:4009:8100::/56, 2d23h59m", "status": "bound"}
+]`
+
+//startRouterOSTestServer starts a fake RouterOS REST API accepting the user admin / secret-password and answering the
+//address and DHCPv6 client paths with the supplied json responses
+func startRouterOSTestServer(t *testing.T, ipv4, ipv6, dhcpClients string) *config.RouterConfiguration {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, `{"error": 401, "message": "Unauthorized"}`)
+			return
+		}
+		var response string
+		switch r.URL.Path {
+		case "/rest/ip/address":
+			response = ipv4
+		case "/rest/ipv6/address":
+			response = ipv6
+		case "/rest/ipv6/dhcp-client":
+			response = dhcpClients
+		}
+		if r.URL.Path != "/rest/ipv6/dhcp-client" && r.URL.Query().Get("interface") != "ether1" {
+			t.Errorf("unexpected interface filter %s", r.URL.RawQuery)
+		}
+		if response == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error": 400, "message": "Bad Request", "detail": "no such command prefix"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return &config.RouterConfiguration{
+		RouterType:   "MikroTik",
+		Username:     "admin",
+		Password:     "secret-password",
+		IpDetailsUrl: server.URL + "/rest",
+	}
+}
+
+func TestMikroTikGetPublicIPAddresses(t *testing.T) {
+	tests := []struct {
+		name    string
+		ipv4Get string
+		ipv6Get string
+		ipv4    string
+		ipv6    string
+	}{
+		{"dual stack", routerOSTestIPv4Addresses, routerOSTestIPv6Addresses, "81.2.69.142", "2a00:1450:4009:81d::200e"},
+		{"IPv4 only with the IPv6 package disabled", routerOSTestIPv4Addresses, "", "81.2.69.142", "<nil>"},
+		{"IPv6 only", "[]", routerOSTestIPv6Addresses, "<nil>", "2a00:1450:4009:81d::200e"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := startRouterOSTestServer(t, test.ipv4Get, test.ipv6Get, routerOSTestDhcpClients)
+			ipv4, ipv6, err := MikroTik{Config: routerConfig}.GetPublicIPAddresses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+		})
+	}
+}
+
+func TestMikroTikGetIPv6Prefix(t *testing.T) {
+	routerConfig := startRouterOSTestServer(t, "[]", "[]", routerOSTestDhcpClients)
+	prefix, err := MikroTik{Config: routerConfig}.GetIPv6Prefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix.String() != "2a00:1450:4009:8100::/56" {
+		t.Errorf("expected the prefix 2a00:1450:4009:8100::/56, got %s", prefix)
+	}
+}
+
+func TestMikroTikGetPublicIPAddressesFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		expected string
+	}{
+		{"no address of either family", "secret-password", "reports no public IPv4 or IPv6 address on interface ether1"},
+		{"rejected credentials", "wrong-password", "returned http status code 401"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := startRouterOSTestServer(t, "[]", "[]", "[]")
+			routerConfig.Password = test.password
+			_, _, err := MikroTik{Config: routerConfig}.GetPublicIPAddresses()
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected an error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
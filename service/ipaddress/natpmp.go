@@ -0,0 +1,291 @@
+package ipaddress
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// NAT-PMP (RFC 6886) and PCP (RFC 6887) constants
+const (
+	natPMPPort             = "5351"
+	natPMPVersion          = 0
+	natPMPOpExternalAddr   = 0
+	natPMPResponseBit      = 0x80
+	pcpVersion             = 2
+	pcpOpMap               = 1
+	pcpResponseBit         = 0x80
+	pcpHeaderLen           = 24
+	pcpMapPayloadLen       = 36
+	pcpMapLifetime         = 30
+	pcpProtocolUDP         = 17
+	natPMPInitialRetryWait = 250 * time.Millisecond
+)
+
+// NAT-PMP and PCP protocol names
+const (
+	ProtocolNATPMP = "natpmp"
+	ProtocolPCP    = "pcp"
+)
+
+// the timeout applied to NAT-PMP and PCP requests when no Timeout is set
+const defaultNATPMPSourceTimeout = 3 * time.Second
+
+// NAT-PMP result codes
+var natPMPResultCodes = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized or refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// PCP result codes
+var pcpResultCodes = map[byte]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "malformed request",
+	4:  "unsupported opcode",
+	5:  "unsupported option",
+	6:  "malformed option",
+	7:  "network failure",
+	8:  "no resources",
+	9:  "unsupported protocol",
+	10: "user exceeded quota",
+	11: "cannot provide external",
+	12: "address mismatch",
+	13: "excessive remote peers",
+}
+
+/*
+The NATPMPSource type that has the ability to ask the default gateway for its public IPv4 address using NAT-PMP or PCP
+
+NAT-PMP docs: https://datatracker.ietf.org/doc/html/rfc6886
+PCP docs:     https://datatracker.ietf.org/doc/html/rfc6887
+
+NAT-PMP has a dedicated external address request. PCP has no such request, the public address is read from the response
+to a short lived MAP request for the UDP port of this client which is deleted again straight away. When no Protocol is
+set NAT-PMP is tried first and PCP is tried when the gateway does not answer the NAT-PMP request successfully.
+*/
+type NATPMPSource struct {
+	Gateway  string        // The gateway host or host:port, discovered from the routing table when empty
+	Protocol string        // natpmp, pcp or empty to try both
+	Timeout  time.Duration // The request timeout including retransmissions
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider NATPMPSource) String() string {
+	return "NAT-PMP/PCP IP address provider"
+}
+
+// GetPublicIPAddresses asks the gateway for its public IPv4 address
+func (ipProvider NATPMPSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	gateway, err := ipProvider.getGatewayAddress()
+	if err != nil {
+		return nil, nil, err
+	}
+	timeout := ipProvider.Timeout
+	if timeout <= 0 {
+		timeout = defaultNATPMPSourceTimeout
+	}
+
+	var protocols []string
+	switch ipProvider.Protocol {
+	case "":
+		protocols = []string{ProtocolNATPMP, ProtocolPCP}
+	case ProtocolNATPMP, ProtocolPCP:
+		protocols = []string{ipProvider.Protocol}
+	default:
+		return nil, nil, fmt.Errorf("unsupported NAT-PMP/PCP protocol %s", ipProvider.Protocol)
+	}
+
+	var failures []string
+	for _, protocol := range protocols {
+		var ipv4 net.IP
+		if protocol == ProtocolNATPMP {
+			ipv4, err = natPMPExternalAddress(gateway, timeout)
+		} else {
+			ipv4, err = pcpExternalAddress(gateway, timeout)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", protocol, err))
+			continue
+		}
+
+		ipProvider.LogIPAddresses(ipv4, nil)
+		return ipv4, nil, nil
+	}
+	return nil, nil, fmt.Errorf("the %s failed to query %s: %s", ipProvider, gateway, strings.Join(failures, "; "))
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider NATPMPSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getGatewayAddress returns the gateway host:port address, discovering the default gateway when none is configured
+func (ipProvider NATPMPSource) getGatewayAddress() (string, error) {
+	if ipProvider.Gateway != "" {
+		if _, _, err := net.SplitHostPort(ipProvider.Gateway); err == nil {
+			return ipProvider.Gateway, nil
+		}
+		return net.JoinHostPort(ipProvider.Gateway, natPMPPort), nil
+	}
+
+	gateway, err := GetDefaultGateway()
+	if err != nil {
+		return "", fmt.Errorf("the default gateway could not be discovered, configure it explicitly: %v", err)
+	}
+	return net.JoinHostPort(gateway.String(), natPMPPort), nil
+}
+
+//natPMPExternalAddress sends a NAT-PMP external address request to the supplied gateway and returns the address
+func natPMPExternalAddress(gateway string, timeout time.Duration) (net.IP, error) {
+	conn, err := dialGateway(gateway, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer closeGatewayConn(conn)
+
+	request := []byte{natPMPVersion, natPMPOpExternalAddr}
+	response, err := udpExchange(conn, request, timeout, func(response []byte) bool {
+		return len(response) >= 2 && response[1] == natPMPResponseBit|natPMPOpExternalAddr
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(response) < 4 {
+		return nil, errors.New("the NAT-PMP response is truncated")
+	}
+	if resultCode := binary.BigEndian.Uint16(response[2:]); resultCode != 0 {
+		return nil, fmt.Errorf("the NAT-PMP request failed with result code %d (%s)",
+			resultCode, natPMPResultCodes[resultCode])
+	}
+	if len(response) < 12 {
+		return nil, errors.New("the NAT-PMP response is truncated")
+	}
+	return net.IPv4(response[8], response[9], response[10], response[11]).To4(), nil
+}
+
+//pcpExternalAddress sends a short lived PCP MAP request for the UDP port of this client to the supplied gateway and
+//returns the assigned external address. The mapping is deleted again once the address has been read
+func pcpExternalAddress(gateway string, timeout time.Duration) (net.IP, error) {
+	conn, err := dialGateway(gateway, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer closeGatewayConn(conn)
+
+	nonce := make([]byte, 12)
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	response, err := pcpMapExchange(conn, nonce, pcpMapLifetime, timeout)
+	if err != nil {
+		return nil, err
+	}
+	//the mapping was only needed to learn the assigned external address
+	if _, err = pcpMapExchange(conn, nonce, 0, timeout); err != nil {
+		log.Printf("The PCP mapping used to read the public address was not deleted: %v", err)
+	}
+
+	assigned := net.IP(response[pcpHeaderLen+20 : pcpHeaderLen+36])
+	ipv4 := assigned.To4()
+	if ipv4 == nil {
+		return nil, fmt.Errorf("the PCP MAP response holds the non IPv4 address %s", assigned)
+	}
+	return ipv4, nil
+}
+
+//pcpMapExchange sends a PCP MAP request for the local UDP port of conn with the supplied nonce and lifetime and
+//returns the validated response
+func pcpMapExchange(conn net.Conn, nonce []byte, lifetime uint32, timeout time.Duration) ([]byte, error) {
+	localAddr := conn.LocalAddr().(*net.UDPAddr)
+
+	request := make([]byte, pcpHeaderLen+pcpMapPayloadLen)
+	request[0] = pcpVersion
+	request[1] = pcpOpMap
+	binary.BigEndian.PutUint32(request[4:], lifetime)
+	copy(request[8:24], localAddr.IP.To16())
+	copy(request[24:36], nonce)
+	request[36] = pcpProtocolUDP
+	binary.BigEndian.PutUint16(request[40:], uint16(localAddr.Port))
+	binary.BigEndian.PutUint16(request[42:], uint16(localAddr.Port))
+	//an all zero IPv4-mapped suggested external address
+	copy(request[44:60], net.IPv4zero.To16())
+
+	response, err := udpExchange(conn, request, timeout, func(response []byte) bool {
+		return len(response) >= 4 && response[1] == pcpResponseBit|pcpOpMap
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response[0] != pcpVersion {
+		return nil, fmt.Errorf("the gateway answered with version %d instead of PCP version %d", response[0], pcpVersion)
+	}
+	if resultCode := response[3]; resultCode != 0 {
+		return nil, fmt.Errorf("the PCP MAP request failed with result code %d (%s)",
+			resultCode, pcpResultCodes[resultCode])
+	}
+	if len(response) < pcpHeaderLen+pcpMapPayloadLen || !bytes.Equal(response[24:36], nonce) {
+		return nil, errors.New("the PCP MAP response does not match the request")
+	}
+	return response, nil
+}
+
+//dialGateway returns a UDP connection to the supplied gateway host:port address
+func dialGateway(gateway string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout("udp4", gateway, timeout)
+}
+
+//closeGatewayConn closes the supplied gateway connection
+func closeGatewayConn(conn net.Conn) {
+	err := conn.Close()
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+//udpExchange sends the supplied request on conn, retransmitting it with a doubling wait as described by RFC 6886, and
+//returns the first response accepted by isResponse received within the supplied timeout
+func udpExchange(conn net.Conn, request []byte, timeout time.Duration, isResponse func([]byte) bool) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	wait := natPMPInitialRetryWait
+	buffer := make([]byte, 1100)
+	for time.Now().Before(deadline) {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+
+		readDeadline := time.Now().Add(wait)
+		if readDeadline.After(deadline) {
+			readDeadline = deadline
+		}
+		if err := conn.SetReadDeadline(readDeadline); err != nil {
+			return nil, err
+		}
+
+		for {
+			count, err := conn.Read(buffer)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if isResponse(buffer[:count]) {
+				return append([]byte{}, buffer[:count]...), nil
+			}
+		}
+		wait *= 2
+	}
+	return nil, fmt.Errorf("no response was received within %s", timeout)
+}
@@ -0,0 +1,231 @@
+package ipaddress
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net"
+	"net/http"
+)
+
+// the OpenWrt ubus url used when no ipDetailsUrl is configured
+const defaultOpenWrtUrl = "http://192.168.1.1/ubus"
+
+// the ubus session id used to perform the session login call
+const ubusNullSession = "00000000000000000000000000000000"
+
+/*
+The OpenWrt type that has the ability to talk to an OpenWrt router over the ubus JSON-RPC interface to retrieve the public
+IPv4 address, the public IPv6 address and the delegated IPv6 prefix of the WAN interface
+
+ubus docs: https://openwrt.org/docs/techref/ubus#access_to_ubus_over_http
+
+request url: http://192.168.1.1/ubus
+
+json payloads:
+	{"jsonrpc": "2.0", "id": 1, "method": "call", "params": ["00000000000000000000000000000000", "session", "login", {"username": "root", "password": "password"}]}
+	{"jsonrpc": "2.0", "id": 2, "method": "call", "params": ["<ubus_rpc_session>", "network.interface.wan", "status", {}]}
+
+sample json response:
+	{"jsonrpc": "2.0", "id": 2, "result": [0, {
+		"up": true,
+		"ipv4-address": [{"address": "255.255.255.255", "mask": 32}],
+		"ipv6-address": [{"address": "2a00:2a00:2a00:2a00::1", "mask": 64}],
+		"ipv6-prefix": [{"address": "2a00:2a00:2a00:2a00::", "mask": 56}]
+	}]}
+
+The router configuration ipDetailsUrl holds the ubus url, userName and password are the rpcd login credentials and
+interface is the logical WAN interface (wan when empty). The IPv6 address and prefix are read from the <interface>6
+interface (wan6) when the WAN interface does not carry them.
+*/
+type OpenWrt struct {
+	Config *config.RouterConfiguration
+}
+
+//ubusResponse is a partial model of a ubus JSON-RPC response
+type ubusResponse struct {
+	Result []json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+//ubusInterfaceStatus is a partial model of the network.interface status ubus response
+type ubusInterfaceStatus struct {
+	Up          bool                `json:"up"`
+	IPv4Address []ubusInterfaceAddr `json:"ipv4-address"`
+	IPv6Address []ubusInterfaceAddr `json:"ipv6-address"`
+	IPv6Prefix  []ubusInterfaceAddr `json:"ipv6-prefix"`
+}
+
+//ubusInterfaceAddr is a partial model of an address entry of the network.interface status ubus response
+type ubusInterfaceAddr struct {
+	Address string `json:"address"`
+	Mask    int    `json:"mask"`
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider OpenWrt) String() string {
+	return "OpenWrt IP address provider"
+}
+
+// GetPublicIPAddresses performs ubus JSON-RPC calls to an OpenWrt router to retrieve and return the public IPv4 and
+// IPv6 addresses of the WAN interface. A missing address of one family is returned as nil and an error is only returned
+// when neither address is available. The delegated IPv6 prefix is recorded as a Diagnostic
+func (ipProvider OpenWrt) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	wan, wan6, err := ipProvider.getInterfaceStatus()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !wan.Up && (wan6 == nil || !wan6.Up) {
+		return nil, nil, fmt.Errorf("the %s reports the WAN interface as down", ipProvider)
+	}
+
+	var ipv4, ipv6 net.IP
+	for _, address := range wan.IPv4Address {
+		if ip := net.ParseIP(address.Address); wan.Up && isUsableIPv4(ip) {
+			ipv4 = ip.To4()
+			break
+		}
+	}
+	for _, status := range []*ubusInterfaceStatus{wan, wan6} {
+		if status == nil || !status.Up || ipv6 != nil {
+			continue
+		}
+		for _, address := range status.IPv6Address {
+			if ip := net.ParseIP(address.Address); isGlobalIPv6(ip) {
+				ipv6 = ip
+				break
+			}
+		}
+	}
+
+	if prefix := getUbusPrefix(wan, wan6); prefix != nil {
+		RecordDiagnostic(Diagnostic{Source: "OpenWrt", Family: FamilyIPv6, Address: prefix.String(),
+			Detail: "delegated IPv6 prefix"})
+	}
+
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s reports no public IPv4 or IPv6 address on the WAN interface", ipProvider)
+	}
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// GetIPv6Prefix performs ubus JSON-RPC calls to an OpenWrt router to retrieve and return the delegated IPv6 prefix
+func (ipProvider OpenWrt) GetIPv6Prefix() (*net.IPNet, error) {
+	wan, wan6, err := ipProvider.getInterfaceStatus()
+	if err != nil {
+		return nil, err
+	}
+	if prefix := getUbusPrefix(wan, wan6); prefix != nil {
+		return prefix, nil
+	}
+	return nil, fmt.Errorf("the %s reports no delegated IPv6 prefix", ipProvider)
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider OpenWrt) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getInterfaceStatus logs in to ubus and returns the status of the WAN interface and, when present, of the IPv6 WAN
+//interface
+func (ipProvider OpenWrt) getInterfaceStatus() (*ubusInterfaceStatus, *ubusInterfaceStatus, error) {
+	if ipProvider.Config == nil {
+		return nil, nil, errors.New("config is nil and it needs to be supplied")
+	}
+	client := getRouterHttpClient(ipProvider.Config)
+
+	var login struct {
+		Session string `json:"ubus_rpc_session"`
+	}
+	credentials := map[string]string{"username": ipProvider.Config.Username, "password": ipProvider.Config.Password}
+	if err := ipProvider.call(client, ubusNullSession, "session", "login", credentials, &login); err != nil {
+		return nil, nil, err
+	}
+
+	wanInterface := ipProvider.Config.Interface
+	if wanInterface == "" {
+		wanInterface = "wan"
+	}
+
+	var wan ubusInterfaceStatus
+	err := ipProvider.call(client, login.Session, "network.interface."+wanInterface, "status", struct{}{}, &wan)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var wan6 ubusInterfaceStatus
+	err = ipProvider.call(client, login.Session, "network.interface."+wanInterface+"6", "status", struct{}{}, &wan6)
+	if err != nil {
+		//a router without a separate IPv6 WAN interface
+		return &wan, nil, nil
+	}
+	return &wan, &wan6, nil
+}
+
+//call performs a ubus JSON-RPC call and decodes the returned data into result
+func (ipProvider OpenWrt) call(
+	client *http.Client,
+	session string,
+	object string,
+	method string,
+	args interface{},
+	result interface{}) error {
+
+	ubusUrl := ipProvider.Config.IpDetailsUrl
+	if ubusUrl == "" {
+		ubusUrl = defaultOpenWrtUrl
+	}
+
+	payload := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "call",
+		"params":  []interface{}{session, object, method, args},
+	}
+	var response ubusResponse
+	if err := performRouterJsonRequest(
+		client, http.MethodPost, ubusUrl, "", "", nil, payload, &response); err != nil {
+		return err
+	}
+
+	if response.Error != nil {
+		return fmt.Errorf("the ubus %s %s call failed: %d %s", object, method, response.Error.Code, response.Error.Message)
+	}
+	if len(response.Result) == 0 {
+		return fmt.Errorf("the ubus %s %s call returned no result", object, method)
+	}
+	var status int
+	if err := json.Unmarshal(response.Result[0], &status); err != nil {
+		return err
+	}
+	if status != 0 {
+		//ubus status codes, 6 is permission denied
+		return fmt.Errorf("the ubus %s %s call failed with status %d", object, method, status)
+	}
+	if len(response.Result) < 2 {
+		return fmt.Errorf("the ubus %s %s call returned no data", object, method)
+	}
+	return json.Unmarshal(response.Result[1], result)
+}
+
+//getUbusPrefix returns the first delegated IPv6 prefix reported by the supplied interface statuses
+func getUbusPrefix(statuses ...*ubusInterfaceStatus) *net.IPNet {
+	for _, status := range statuses {
+		if status == nil {
+			continue
+		}
+		for _, prefix := range status.IPv6Prefix {
+			if ip := net.ParseIP(prefix.Address); isGlobalIPv6(ip) && prefix.Mask > 0 && prefix.Mask <= 128 {
+				mask := net.CIDRMask(prefix.Mask, 128)
+				return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package ipaddress
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// the network.interface status of a connected dual stack wan interface
+const ubusTestWan = `{"up": true, "l3_device": "pppoe-wan",
+	"ipv4-address": [{"address": "81.2.69.142", "mask": 32}],
+	"ipv6-address": [], "ipv6-prefix": []}`
+
+// the network.interface status of a connected wan6 interface with a delegated /56 prefix
+const ubusTestWan6 = `{"up": true, "l3_device": "pppoe-wan",
+	"ipv6-address": [{"address": "fe80::1", "mask": 64}, {"address": "2a00:1450:4009:81d::200e", "mask": 64}],
+	"ipv6-prefix": [{"address": "2a00:1450:4009:8100::", "mask": 56, "assigned": {"lan": {"address": "2a00:1450:4009:8100::", "mask": 64}}}]}`
+
+// the network.interface status of a wan interface without an IPv4 connection, such as on a DS-Lite line
+const ubusTestWanDown = `{"up": false, "ipv4-address": [], "ipv6-address": [], "ipv6-prefix": []}`
+
+//startUbusTestServer starts a fake OpenWrt ubus JSON-RPC endpoint accepting the rpcd login root / secret-password and
+//answering the wan and wan6 status calls with the supplied statuses, an empty status is an unknown interface
+func startUbusTestServer(t *testing.T, wan string, wan6 string) *config.RouterConfiguration {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			JsonRpc string            `json:"jsonrpc"`
+			Method  string            `json:"method"`
+			Params  []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.Method != http.MethodPost ||
+			request.JsonRpc != "2.0" || request.Method != "call" || len(request.Params) != 4 {
+			t.Errorf("unexpected ubus request %s %v", r.Method, request)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var session, object, method string
+		_ = json.Unmarshal(request.Params[0], &session)
+		_ = json.Unmarshal(request.Params[1], &object)
+		_ = json.Unmarshal(request.Params[2], &method)
+
+		result := "[6]"
+		switch {
+		case object == "session" && method == "login":
+			var credentials map[string]string
+			_ = json.Unmarshal(request.Params[3], &credentials)
+			if session == ubusNullSession && credentials["username"] == "root" &&
+				credentials["password"] == "secret-password" {
+				result = `[0, {"ubus_rpc_session": "c1ed6c7b025d0caca723a816fa61b668", "timeout": 300}]`
+			}
+		case session != "c1ed6c7b025d0caca723a816fa61b668":
+		case object == "network.interface.wan" && method == "status" && wan != "":
+			result = "[0, " + wan + "]"
+		case object == "network.interface.wan6" && method == "status" && wan6 != "":
+			result = "[0, " + wan6 + "]"
+		default:
+			_, _ = fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "error": {"code": -32000, "message": "Object not found"}}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"jsonrpc": "2.0", "id": 1, "result": %s}`, result)
+	}))
+	t.Cleanup(server.Close)
+	return &config.RouterConfiguration{
+		RouterType:   "OpenWrt",
+		Username:     "root",
+		Password:     "secret-password",
+		IpDetailsUrl: server.URL + "/ubus",
+	}
+}
+
+func TestOpenWrtGetPublicIPAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		wan  string
+		wan6 string
+		ipv4 string
+		ipv6 string
+	}{
+		{"dual stack", ubusTestWan, ubusTestWan6, "81.2.69.142", "2a00:1450:4009:81d::200e"},
+		{"IPv4 only without a wan6 interface", ubusTestWan, "", "81.2.69.142", "<nil>"},
+		{"DS-Lite without an IPv4 connection", ubusTestWanDown, ubusTestWan6, "<nil>", "2a00:1450:4009:81d::200e"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ipv4, ipv6, err := OpenWrt{Config: startUbusTestServer(t, test.wan, test.wan6)}.GetPublicIPAddresses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+		})
+	}
+}
+
+func TestOpenWrtGetIPv6Prefix(t *testing.T) {
+	prefix, err := OpenWrt{Config: startUbusTestServer(t, ubusTestWanDown, ubusTestWan6)}.GetIPv6Prefix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix.String() != "2a00:1450:4009:8100::/56" {
+		t.Errorf("expected the prefix 2a00:1450:4009:8100::/56, got %s", prefix)
+	}
+}
+
+func TestOpenWrtGetPublicIPAddressesFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		wan      string
+		wan6     string
+		password string
+		expected string
+	}{
+		{"WAN interfaces down", ubusTestWanDown, "", "secret-password", "reports the WAN interface as down"},
+		{"rejected login", ubusTestWan, ubusTestWan6, "wrong-password", "session login call failed with status 6"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := startUbusTestServer(t, test.wan, test.wan6)
+			routerConfig.Password = test.password
+			_, _, err := OpenWrt{Config: routerConfig}.GetPublicIPAddresses()
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected an error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
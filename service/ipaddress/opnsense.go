@@ -0,0 +1,351 @@
+package ipaddress
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// the OPNsense and pfSense REST API base url used when no ipDetailsUrl is configured
+const defaultFirewallUrl = "https://192.168.1.1"
+
+/*
+The OPNsense type that has the ability to talk to an OPNsense firewall over its REST API to retrieve the public IPv4
+address, the public IPv6 address and the delegated IPv6 prefix of the WAN interface
+
+OPNsense API docs: https://docs.opnsense.org/development/api/core/interfaces.html
+
+request url: https://192.168.1.1/api/interfaces/overview/interfacesInfo
+
+sample json response:
+	{"rows": [
+		{"identifier": "wan", "status": "up", "addr4": "255.255.255.255/24", "addr6": "2a00:2a00:2a00:2a00::1/64",
+		 "config": {"ipaddrv6": "dhcp6", "dhcp6-ia-pd-len": "8"}},
+		{"identifier": "lan", "status": "up", "addr4": "192.168.1.1/24", "addr6": "2a00:2a00:2a00:2a01::1/64",
+		 "config": {"ipaddrv6": "track6", "track6-interface": "wan"}}
+	]}
+
+The router configuration ipDetailsUrl holds the API base url, apiKey and apiSecret are the API key and secret and
+interface is the WAN interface identifier (wan when empty). The delegated prefix is derived from the address of an
+interface that tracks the WAN interface and the prefix delegation size requested on the WAN interface.
+*/
+type OPNsense struct {
+	Config *config.RouterConfiguration
+}
+
+/*
+The PfSense type that has the ability to talk to a pfSense firewall with the pfSense REST API package installed to
+retrieve the public IPv4 address, the public IPv6 address and the delegated IPv6 prefix of the WAN interface
+
+pfSense REST API docs: https://pfrest.org/
+
+request urls:
+	https://192.168.1.1/api/v2/status/interfaces
+	https://192.168.1.1/api/v2/interfaces
+
+sample json responses:
+	{"code": 200, "data": [{"name": "wan", "descr": "WAN", "status": "up", "ipaddr": "255.255.255.255", "ipaddrv6": "2a00:2a00:2a00:2a00::1"}]}
+	{"code": 200, "data": [{"id": "wan", "typev6": "dhcp6", "dhcp6_ia_pd_len": 8}, {"id": "lan", "typev6": "track6", "track6_interface": "wan"}]}
+
+The router configuration ipDetailsUrl holds the API base url, apiKey is sent as the X-API-Key header (userName and
+password are used for basic authentication instead when no apiKey is configured) and interface is the WAN interface
+name (wan when empty).
+*/
+type PfSense struct {
+	Config *config.RouterConfiguration
+}
+
+//opnsenseInterfacesInfo is a partial model of the OPNsense interfaces overview response
+type opnsenseInterfacesInfo struct {
+	Rows []struct {
+		Identifier string                 `json:"identifier"`
+		Status     string                 `json:"status"`
+		Addr4      string                 `json:"addr4"`
+		Addr6      string                 `json:"addr6"`
+		Config     map[string]interface{} `json:"config"`
+	} `json:"rows"`
+}
+
+//pfSenseInterfaceStatus is a partial model of the pfSense REST API status/interfaces response
+type pfSenseInterfaceStatus struct {
+	Data []struct {
+		Name     string `json:"name"`
+		Descr    string `json:"descr"`
+		Status   string `json:"status"`
+		IPAddr   string `json:"ipaddr"`
+		IPAddrV6 string `json:"ipaddrv6"`
+	} `json:"data"`
+}
+
+//pfSenseInterfaces is a partial model of the pfSense REST API interfaces response
+type pfSenseInterfaces struct {
+	Data []map[string]interface{} `json:"data"`
+}
+
+//firewallInterface is the WAN address detail read from an OPNsense or pfSense firewall
+type firewallInterface struct {
+	ipv4   net.IP
+	ipv6   net.IP
+	prefix *net.IPNet
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider OPNsense) String() string {
+	return "OPNsense IP address provider"
+}
+
+// GetPublicIPAddresses performs an OPNsense REST API request to retrieve and return the public IPv4 and IPv6 addresses
+// of the WAN interface. A missing address of one family is returned as nil and an error is only returned when neither
+// address is available. The delegated IPv6 prefix is recorded as a Diagnostic
+func (ipProvider OPNsense) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	wan, err := ipProvider.getWanInterface()
+	if err != nil {
+		return nil, nil, err
+	}
+	recordFirewallPrefix("OPNsense", wan)
+	if wan.ipv4 == nil && wan.ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s reports no public IPv4 or IPv6 address on interface %s",
+			ipProvider, getFirewallWanInterface(ipProvider.Config))
+	}
+	ipProvider.LogIPAddresses(wan.ipv4, wan.ipv6)
+	return wan.ipv4, wan.ipv6, nil
+}
+
+// GetIPv6Prefix performs an OPNsense REST API request to retrieve and return the delegated IPv6 prefix
+func (ipProvider OPNsense) GetIPv6Prefix() (*net.IPNet, error) {
+	wan, err := ipProvider.getWanInterface()
+	if err != nil {
+		return nil, err
+	}
+	if wan.prefix == nil {
+		return nil, fmt.Errorf("the %s reports no delegated IPv6 prefix", ipProvider)
+	}
+	return wan.prefix, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider OPNsense) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getWanInterface reads the interfaces overview and returns the WAN interface addresses and delegated prefix
+func (ipProvider OPNsense) getWanInterface() (*firewallInterface, error) {
+	if ipProvider.Config == nil {
+		return nil, errors.New("config is nil and it needs to be supplied")
+	}
+	wanInterface := getFirewallWanInterface(ipProvider.Config)
+
+	var info opnsenseInterfacesInfo
+	err := performRouterJsonRequest(
+		getRouterHttpClient(ipProvider.Config),
+		http.MethodGet,
+		getFirewallUrl(ipProvider.Config, "/api/interfaces/overview/interfacesInfo"),
+		ipProvider.Config.APIKey,
+		ipProvider.Config.APISecret,
+		nil,
+		nil,
+		&info)
+	if err != nil {
+		return nil, err
+	}
+
+	var wan firewallInterface
+	var pdLength interface{}
+	found := false
+	for _, row := range info.Rows {
+		if !strings.EqualFold(row.Identifier, wanInterface) {
+			continue
+		}
+		found = true
+		if row.Status != "" && row.Status != "up" {
+			return nil, fmt.Errorf("the %s reports the WAN interface %s as %s", ipProvider, wanInterface, row.Status)
+		}
+		if ip, _ := parseAddressPrefix(row.Addr4); isUsableIPv4(ip) {
+			wan.ipv4 = ip.To4()
+		}
+		if ip, _ := parseAddressPrefix(row.Addr6); isGlobalIPv6(ip) {
+			wan.ipv6 = ip
+		}
+		pdLength = row.Config["dhcp6-ia-pd-len"]
+	}
+	if !found {
+		return nil, fmt.Errorf("the %s reports no interface %s", ipProvider, wanInterface)
+	}
+
+	for _, row := range info.Rows {
+		if tracked, _ := row.Config["track6-interface"].(string); !strings.EqualFold(tracked, wanInterface) {
+			continue
+		}
+		if ip, _ := parseAddressPrefix(row.Addr6); isGlobalIPv6(ip) {
+			wan.prefix = getDelegatedPrefix(ip, pdLength)
+			break
+		}
+	}
+	return &wan, nil
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider PfSense) String() string {
+	return "pfSense IP address provider"
+}
+
+// GetPublicIPAddresses performs pfSense REST API requests to retrieve and return the public IPv4 and IPv6 addresses of
+// the WAN interface. A missing address of one family is returned as nil and an error is only returned when neither
+// address is available. The delegated IPv6 prefix is recorded as a Diagnostic
+func (ipProvider PfSense) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	wan, err := ipProvider.getWanInterface()
+	if err != nil {
+		return nil, nil, err
+	}
+	recordFirewallPrefix("PfSense", wan)
+	if wan.ipv4 == nil && wan.ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s reports no public IPv4 or IPv6 address on interface %s",
+			ipProvider, getFirewallWanInterface(ipProvider.Config))
+	}
+	ipProvider.LogIPAddresses(wan.ipv4, wan.ipv6)
+	return wan.ipv4, wan.ipv6, nil
+}
+
+// GetIPv6Prefix performs pfSense REST API requests to retrieve and return the delegated IPv6 prefix
+func (ipProvider PfSense) GetIPv6Prefix() (*net.IPNet, error) {
+	wan, err := ipProvider.getWanInterface()
+	if err != nil {
+		return nil, err
+	}
+	if wan.prefix == nil {
+		return nil, fmt.Errorf("the %s reports no delegated IPv6 prefix", ipProvider)
+	}
+	return wan.prefix, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider PfSense) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//getWanInterface reads the interface status and configuration and returns the WAN interface addresses and delegated
+//prefix
+func (ipProvider PfSense) getWanInterface() (*firewallInterface, error) {
+	if ipProvider.Config == nil {
+		return nil, errors.New("config is nil and it needs to be supplied")
+	}
+	wanInterface := getFirewallWanInterface(ipProvider.Config)
+	client := getRouterHttpClient(ipProvider.Config)
+
+	username, password := ipProvider.Config.Username, ipProvider.Config.Password
+	headers := make(map[string]string)
+	if ipProvider.Config.APIKey != "" {
+		username, password = "", ""
+		headers["X-API-Key"] = ipProvider.Config.APIKey
+	}
+
+	var status pfSenseInterfaceStatus
+	err := performRouterJsonRequest(client, http.MethodGet,
+		getFirewallUrl(ipProvider.Config, "/api/v2/status/interfaces"), username, password, headers, nil, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	var wan firewallInterface
+	found := false
+	for _, entry := range status.Data {
+		if !strings.EqualFold(entry.Name, wanInterface) && !strings.EqualFold(entry.Descr, wanInterface) {
+			continue
+		}
+		found = true
+		if entry.Status != "" && entry.Status != "up" {
+			return nil, fmt.Errorf("the %s reports the WAN interface %s as %s", ipProvider, wanInterface, entry.Status)
+		}
+		if ip := net.ParseIP(entry.IPAddr); isUsableIPv4(ip) {
+			wan.ipv4 = ip.To4()
+		}
+		if ip := net.ParseIP(entry.IPAddrV6); isGlobalIPv6(ip) {
+			wan.ipv6 = ip
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("the %s reports no interface %s", ipProvider, wanInterface)
+	}
+
+	var interfaces pfSenseInterfaces
+	err = performRouterJsonRequest(client, http.MethodGet,
+		getFirewallUrl(ipProvider.Config, "/api/v2/interfaces"), username, password, headers, nil, &interfaces)
+	if err != nil {
+		//the prefix is optional, the addresses are good to use without it
+		log.Printf("The %s could not read the interface configuration: %v", ipProvider, err)
+		return &wan, nil
+	}
+
+	var pdLength interface{}
+	for _, entry := range interfaces.Data {
+		if id, _ := entry["id"].(string); strings.EqualFold(id, wanInterface) {
+			pdLength = entry["dhcp6_ia_pd_len"]
+		}
+	}
+	for _, entry := range interfaces.Data {
+		if tracked, _ := entry["track6_interface"].(string); !strings.EqualFold(tracked, wanInterface) {
+			continue
+		}
+		id, _ := entry["id"].(string)
+		for _, statusEntry := range status.Data {
+			if !strings.EqualFold(statusEntry.Name, id) {
+				continue
+			}
+			if ip := net.ParseIP(statusEntry.IPAddrV6); isGlobalIPv6(ip) {
+				wan.prefix = getDelegatedPrefix(ip, pdLength)
+			}
+		}
+		if wan.prefix != nil {
+			break
+		}
+	}
+	return &wan, nil
+}
+
+//getFirewallUrl returns the absolute url of the supplied API path
+func getFirewallUrl(routerConfig *config.RouterConfiguration, path string) string {
+	baseUrl := routerConfig.IpDetailsUrl
+	if baseUrl == "" {
+		baseUrl = defaultFirewallUrl
+	}
+	return strings.TrimSuffix(baseUrl, "/") + path
+}
+
+//getFirewallWanInterface returns the configured WAN interface name
+func getFirewallWanInterface(routerConfig *config.RouterConfiguration) string {
+	if routerConfig.Interface == "" {
+		return "wan"
+	}
+	return routerConfig.Interface
+}
+
+//getDelegatedPrefix returns the delegated prefix containing the supplied address of an interface that tracks the WAN
+//interface. The pdLength is the OPNsense / pfSense prefix delegation size setting which holds the number of bits the
+//delegated prefix is shorter than a /64 (8 for a /56), a /64 is assumed when it is not set
+func getDelegatedPrefix(trackingAddress net.IP, pdLength interface{}) *net.IPNet {
+	bits := 0
+	switch value := pdLength.(type) {
+	case string:
+		bits, _ = strconv.Atoi(value)
+	case float64:
+		bits = int(value)
+	}
+	if bits < 0 || bits > 64 {
+		bits = 0
+	}
+	mask := net.CIDRMask(64-bits, 128)
+	return &net.IPNet{IP: trackingAddress.Mask(mask), Mask: mask}
+}
+
+//recordFirewallPrefix records the delegated prefix of the supplied WAN interface as a Diagnostic
+func recordFirewallPrefix(source string, wan *firewallInterface) {
+	if wan.prefix != nil {
+		RecordDiagnostic(Diagnostic{Source: source, Family: FamilyIPv6, Address: wan.prefix.String(),
+			Detail: "delegated IPv6 prefix"})
+	}
+}
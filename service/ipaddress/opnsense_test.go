@@ -0,0 +1,199 @@
+package ipaddress
+
+import (
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// the OPNsense interfaces overview of a dual stack WAN with a /56 delegated to the tracking LAN interface
+const opnsenseTestDualStack = `{"total": 2, "rowCount": 2, "current": 1, "rows": [
+	{"identifier": "wan", "description": "WAN", "status": "up", "addr4": "81.2.69.142/22",
+	 "addr6": "2a00:1450:4009:81d::200e/64", "config": {"ipaddr": "dhcp", "ipaddrv6": "dhcp6", "dhcp6-ia-pd-len": "8"}},
+	{"identifier": "lan", "description": "LAN", "status": "up", "addr4": "192.168.1.1/24",
+	 "addr6": "2a00:1450:4009:8100::1/64", "config": {"ipaddrv6": "track6", "track6-interface": "wan"}}
+]}`
+
+// the OPNsense interfaces overview of a DS-Lite WAN without an IPv4 address
+const opnsenseTestIPv6Only = `{"rows": [
+	{"identifier": "wan", "status": "up", "addr4": "", "addr6": "2a00:1450:4009:81d::200e/64",
+	 "config": {"ipaddrv6": "dhcp6"}}
+]}`
+
+// the OPNsense interfaces overview of a disconnected WAN
+const opnsenseTestDown = `{"rows": [{"identifier": "wan", "status": "down", "addr4": "", "addr6": "", "config": {}}]}`
+
+// the pfSense REST API status/interfaces response of a dual stack WAN and its tracking LAN interface
+const pfSenseTestStatus = `{"code": 200, "status": "ok", "data": [
+	{"name": "wan", "descr": "WAN", "status": "up", "ipaddr": "81.2.69.142", "ipaddrv6": "2a00:1450:4009:81d::200e"},
+	{"name": "lan", "descr": "LAN", "status": "up", "ipaddr": "192.168.1.1", "ipaddrv6": "2a00:1450:4009:8100::1"}
+]}`
+
+// the pfSense REST API status/interfaces response of a DS-Lite WAN without an IPv4 address
+const pfSenseTestStatusIPv6Only = `{"code": 200, "data": [
+	{"name": "wan", "descr": "WAN", "status": "up", "ipaddr": "", "ipaddrv6": "2a00:1450:4009:81d::200e"}
+]}`
+
+// the pfSense REST API interfaces response requesting a /56 delegation on the WAN interface
+const pfSenseTestInterfaces = `{"code": 200, "data": [
+	{"id": "wan", "typev6": "dhcp6", "dhcp6_ia_pd_len": 8},
+	{"id": "lan", "typev6": "track6", "track6_interface": "wan"}
+]}`
+
+//startFirewallTestServer starts a fake firewall REST API answering the supplied paths with the supplied json responses
+//for requests that carry the supplied credentials
+func startFirewallTestServer(
+	t *testing.T,
+	authorized func(r *http.Request) bool,
+	responses map[string]string) *config.RouterConfiguration {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, `{"status": 401, "message": "Authentication Failed"}`)
+			return
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return &config.RouterConfiguration{IpDetailsUrl: server.URL + "/"}
+}
+
+//opnsenseAuthorized checks the OPNsense API key and secret basic authentication of the supplied request
+func opnsenseAuthorized(r *http.Request) bool {
+	key, secret, ok := r.BasicAuth()
+	return ok && key == "w86XNZob" && secret == "/N4tKRw+"
+}
+
+//pfSenseAuthorized checks the pfSense REST API key header of the supplied request
+func pfSenseAuthorized(r *http.Request) bool {
+	return r.Header.Get("X-API-Key") == "f2b4c8d1e3"
+}
+
+func TestOPNsenseGetPublicIPAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		ipv4     string
+		ipv6     string
+		prefix   string
+	}{
+		{"dual stack", opnsenseTestDualStack, "81.2.69.142", "2a00:1450:4009:81d::200e", "2a00:1450:4009:8100::/56"},
+		{"IPv6 only", opnsenseTestIPv6Only, "<nil>", "2a00:1450:4009:81d::200e", "<nil>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := startFirewallTestServer(t, opnsenseAuthorized,
+				map[string]string{"/api/interfaces/overview/interfacesInfo": test.response})
+			routerConfig.APIKey, routerConfig.APISecret = "w86XNZob", "/N4tKRw+"
+			ipProvider := OPNsense{Config: routerConfig}
+
+			ipv4, ipv6, err := ipProvider.GetPublicIPAddresses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+			wan, err := ipProvider.getWanInterface()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wan.prefix.String() != test.prefix {
+				t.Errorf("expected the prefix %s, got %s", test.prefix, wan.prefix)
+			}
+		})
+	}
+}
+
+func TestOPNsenseGetPublicIPAddressesFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		apiSecret string
+		iface     string
+		expected  string
+	}{
+		{"WAN down", opnsenseTestDown, "/N4tKRw+", "", "reports the WAN interface wan as down"},
+		{"unknown interface", opnsenseTestDualStack, "/N4tKRw+", "opt1", "reports no interface opt1"},
+		{"rejected API key", opnsenseTestDualStack, "wrong", "", "returned http status code 401"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := startFirewallTestServer(t, opnsenseAuthorized,
+				map[string]string{"/api/interfaces/overview/interfacesInfo": test.response})
+			routerConfig.APIKey, routerConfig.APISecret, routerConfig.Interface = "w86XNZob", test.apiSecret, test.iface
+			_, _, err := OPNsense{Config: routerConfig}.GetPublicIPAddresses()
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected an error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestPfSenseGetPublicIPAddresses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		ipv4   string
+		ipv6   string
+		prefix string
+	}{
+		{"dual stack", pfSenseTestStatus, "81.2.69.142", "2a00:1450:4009:81d::200e", "2a00:1450:4009:8100::/56"},
+		{"IPv6 only", pfSenseTestStatusIPv6Only, "<nil>", "2a00:1450:4009:81d::200e", "<nil>"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			routerConfig := startFirewallTestServer(t, pfSenseAuthorized, map[string]string{
+				"/api/v2/status/interfaces": test.status,
+				"/api/v2/interfaces":        pfSenseTestInterfaces,
+			})
+			routerConfig.APIKey = "f2b4c8d1e3"
+			ipProvider := PfSense{Config: routerConfig}
+
+			ipv4, ipv6, err := ipProvider.GetPublicIPAddresses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != test.ipv4 || ipv6.String() != test.ipv6 {
+				t.Errorf("expected the addresses %s and %s, got %s and %s", test.ipv4, test.ipv6, ipv4, ipv6)
+			}
+			wan, err := ipProvider.getWanInterface()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wan.prefix.String() != test.prefix {
+				t.Errorf("expected the prefix %s, got %s", test.prefix, wan.prefix)
+			}
+		})
+	}
+}
+
+func TestGetDelegatedPrefix(t *testing.T) {
+	tests := []struct {
+		pdLength interface{}
+		expected string
+	}{
+		{nil, "2a00:1450:4009:8100::/64"},
+		{"8", "2a00:1450:4009:8100::/56"},
+		{float64(16), "2a00:1450:4009::/48"},
+		{"4", "2a00:1450:4009:8100::/60"},
+		{"80", "2a00:1450:4009:8100::/64"},
+	}
+	for _, test := range tests {
+		prefix := getDelegatedPrefix(net.ParseIP("2a00:1450:4009:8100::1"), test.pdLength)
+		if prefix.String() != test.expected {
+			t.Errorf("expected the prefix %s for the delegation size %v, got %s", test.expected, test.pdLength, prefix)
+		}
+	}
+}
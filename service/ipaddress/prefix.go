@@ -0,0 +1,86 @@
+package ipaddress
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+// the prefix length derived from a public IPv6 address when the source reports no delegated prefix
+const DefaultIPv6PrefixLength = 64
+
+// IPrefixProvider describes the interface of a type able to return the IPv6 prefix delegated to the network where this
+// code is running
+type IPrefixProvider interface {
+	// GetIPv6Prefix returns the delegated IPv6 prefix
+	GetIPv6Prefix() (*net.IPNet, error)
+}
+
+// GetIPv6Prefix returns the delegated IPv6 prefix reported by the supplied provider when it is an IPrefixProvider,
+// otherwise the prefix of prefixLength bits is derived from the supplied public IPv6 address
+func GetIPv6Prefix(provider IAddressProvider, ipv6 net.IP, prefixLength int) (*net.IPNet, error) {
+	if prefixProvider, ok := provider.(IPrefixProvider); ok {
+		prefix, err := prefixProvider.GetIPv6Prefix()
+		if err == nil {
+			return prefix, nil
+		}
+		log.Printf("The %s reports no delegated IPv6 prefix, deriving it from %s: %v", provider, ipv6, err)
+	}
+
+	if ipv6 == nil || ipv6.To4() != nil {
+		return nil, errors.New("there is no public IPv6 address to derive the IPv6 prefix from")
+	}
+	if prefixLength <= 0 {
+		prefixLength = DefaultIPv6PrefixLength
+	}
+	if prefixLength > 128 {
+		return nil, fmt.Errorf("invalid IPv6 prefix length %d", prefixLength)
+	}
+	mask := net.CIDRMask(prefixLength, 128)
+	return &net.IPNet{IP: ipv6.Mask(mask), Mask: mask}, nil
+}
+
+// CombineIPv6Prefix returns the IPv6 address made of the network bits of prefix and the remaining host bits of
+// interfaceID. Bits of interfaceID above the interface identifier, such as the subnet id 5 of 0:0:0:5::10, are kept
+// when the prefix is shorter than 64 bits
+func CombineIPv6Prefix(prefix *net.IPNet, interfaceID net.IP) net.IP {
+	combined := make(net.IP, net.IPv6len)
+	prefixIP := prefix.IP.To16()
+	interfaceIP := interfaceID.To16()
+	for index := 0; index < net.IPv6len; index++ {
+		combined[index] = prefixIP[index]&prefix.Mask[index] | interfaceIP[index]&^prefix.Mask[index]
+	}
+	return combined
+}
+
+// ParseInterfaceID returns the IPv6 interface ID given either as an address suffix such as ::10 or, when no suffix is
+// given, derived as a modified EUI-64 interface ID from a MAC address such as 00:11:22:33:44:55
+func ParseInterfaceID(suffix string, macAddress string) (net.IP, error) {
+	if suffix != "" {
+		interfaceID := net.ParseIP(suffix)
+		if interfaceID == nil || interfaceID.To4() != nil {
+			return nil, fmt.Errorf("invalid IPv6 interface ID %s", suffix)
+		}
+		return interfaceID, nil
+	}
+
+	mac, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("an EUI-64 interface ID needs a 48 bit MAC address, %s is not", macAddress)
+	}
+	//flip the universal/local bit and insert ff:fe in the middle (RFC 4291 appendix A)
+	interfaceID := make(net.IP, net.IPv6len)
+	interfaceID[8] = mac[0] ^ 0x02
+	interfaceID[9] = mac[1]
+	interfaceID[10] = mac[2]
+	interfaceID[11] = 0xff
+	interfaceID[12] = 0xfe
+	interfaceID[13] = mac[3]
+	interfaceID[14] = mac[4]
+	interfaceID[15] = mac[5]
+	return interfaceID, nil
+}
@@ -0,0 +1,148 @@
+package ipaddress
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+//prefixTestProvider is a stub IPrefixProvider returning a fixed delegated prefix or an error
+type prefixTestProvider struct {
+	chainTestProvider
+	prefix string
+	err    error
+}
+
+//GetIPv6Prefix returns the fixed delegated prefix or error of the stub provider
+func (ipProvider prefixTestProvider) GetIPv6Prefix() (*net.IPNet, error) {
+	if ipProvider.err != nil {
+		return nil, ipProvider.err
+	}
+	_, prefix, err := net.ParseCIDR(ipProvider.prefix)
+	return prefix, err
+}
+
+//mustParseCIDR returns the network of the supplied CIDR notation, keeping any host bits of the address
+func mustParseCIDR(t *testing.T, cidr string) *net.IPNet {
+	ip, prefix, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix.IP = ip
+	return prefix
+}
+
+func TestGetIPv6Prefix(t *testing.T) {
+	tests := []struct {
+		name         string
+		provider     IAddressProvider
+		ipv6         string
+		prefixLength int
+		expected     string
+		err          string
+	}{
+		{"derived /64 by default", chainTestProvider{name: "http"}, "2a00:1450:4009:81d::200e", 0,
+			"2a00:1450:4009:81d::/64", ""},
+		{"derived /48", chainTestProvider{name: "http"}, "2a00:1450:4009:81d::200e", 48, "2a00:1450:4009::/48", ""},
+		{"derived /56", chainTestProvider{name: "http"}, "2a00:1450:4009:81d::200e", 56,
+			"2a00:1450:4009:800::/56", ""},
+		{"derived /60", chainTestProvider{name: "http"}, "2a00:1450:4009:81d::200e", 60,
+			"2a00:1450:4009:810::/60", ""},
+		{"delegated prefix of the provider", prefixTestProvider{prefix: "2a00:1450:4009:8100::/56"},
+			"2a00:1450:4009:81d::200e", 64, "2a00:1450:4009:8100::/56", ""},
+		{"derived when the provider reports no prefix", prefixTestProvider{err: errors.New("no delegation")},
+			"2a00:1450:4009:81d::200e", 60, "2a00:1450:4009:810::/60", ""},
+		{"no IPv6 address", chainTestProvider{name: "http"}, "", 64, "",
+			"there is no public IPv6 address to derive the IPv6 prefix from"},
+		{"IPv4 address", chainTestProvider{name: "http"}, "81.2.69.142", 64, "",
+			"there is no public IPv6 address to derive the IPv6 prefix from"},
+		{"prefix length too long", chainTestProvider{name: "http"}, "2a00:1450:4009:81d::200e", 129, "",
+			"invalid IPv6 prefix length 129"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prefix, err := GetIPv6Prefix(test.provider, net.ParseIP(test.ipv6), test.prefixLength)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("expected the error %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prefix.String() != test.expected {
+				t.Errorf("expected the prefix %s, got %s", test.expected, prefix)
+			}
+		})
+	}
+}
+
+func TestCombineIPv6Prefix(t *testing.T) {
+	tests := []struct {
+		prefix      string
+		interfaceID string
+		expected    string
+	}{
+		{"2a00:1450:4009::/48", "0:0:0:5::10", "2a00:1450:4009:5::10"},
+		{"2a00:1450:4009::/48", "::10", "2a00:1450:4009::10"},
+		{"2a00:1450:4009:8100::/56", "0:0:0:5::10", "2a00:1450:4009:8105::10"},
+		{"2a00:1450:4009:8100::/56", "0:0:0:1ff::10", "2a00:1450:4009:81ff::10"},
+		{"2a00:1450:4009:8100::/60", "0:0:0:15::10", "2a00:1450:4009:8105::10"},
+		{"2a00:1450:4009:81d::/64", "::10", "2a00:1450:4009:81d::10"},
+		{"2a00:1450:4009:81d::/64", "0:0:0:5::10", "2a00:1450:4009:81d::10"},
+		{"2a00:1450:4009:81d::/64", "::211:22ff:fe33:4455", "2a00:1450:4009:81d:211:22ff:fe33:4455"},
+		{"2a00:1450:4009:81d::200e/64", "::10", "2a00:1450:4009:81d::10"},
+		{"2a00:1450:4009:81d::/64", "::", "2a00:1450:4009:81d::"},
+		{"2a00:1450:4009:8100::/56", "::", "2a00:1450:4009:8100::"},
+	}
+	for _, test := range tests {
+		combined := CombineIPv6Prefix(mustParseCIDR(t, test.prefix), net.ParseIP(test.interfaceID))
+		if combined.String() != test.expected {
+			t.Errorf("expected %s combined with %s to be %s, got %s", test.prefix, test.interfaceID, test.expected,
+				combined)
+		}
+	}
+}
+
+func TestParseInterfaceID(t *testing.T) {
+	tests := []struct {
+		name       string
+		suffix     string
+		macAddress string
+		expected   string
+		err        string
+	}{
+		{"suffix", "::10", "", "::10", ""},
+		{"suffix with a subnet id", "0:0:0:5::10", "", "::5:0:0:0:10", ""},
+		{"suffix takes precedence over the MAC address", "::10", "00:11:22:33:44:55", "::10", ""},
+		{"unspecified suffix", "::", "", "::", ""},
+		{"MAC address", "", "00:11:22:33:44:55", "::211:22ff:fe33:4455", ""},
+		{"locally administered MAC address", "", "02-11-22-33-44-55", "::11:22ff:fe33:4455", ""},
+		{"upper case MAC address", "", "AC:DE:48:00:11:22", "::aede:48ff:fe00:1122", ""},
+		{"IPv4 suffix", "10.0.0.1", "", "", "invalid IPv6 interface ID 10.0.0.1"},
+		{"malformed suffix", "::10::1", "", "", "invalid IPv6 interface ID ::10::1"},
+		{"malformed MAC address", "", "00:11:22:33:44", "", "address 00:11:22:33:44: invalid MAC address"},
+		{"no suffix or MAC address", "", "", "", "invalid MAC address"},
+		{"EUI-64 MAC address", "", "00:11:22:33:44:55:66:77", "",
+			"an EUI-64 interface ID needs a 48 bit MAC address, 00:11:22:33:44:55:66:77 is not"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interfaceID, err := ParseInterfaceID(test.suffix, test.macAddress)
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Errorf("expected an error starting %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if interfaceID.String() != test.expected {
+				t.Errorf("expected the interface ID %s, got %s", test.expected, interfaceID)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package ipaddress
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// the timeout applied to router API requests
+const routerRequestTimeout = 5 * time.Second
+
+//getRouterHttpClient returns a timeout bound http.Client for router API requests that skips TLS certificate
+//verification when the router configuration asks for it, routers commonly use self-signed certificates
+func getRouterHttpClient(routerConfig *config.RouterConfiguration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if routerConfig.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Timeout: routerRequestTimeout, Transport: transport}
+}
+
+//performRouterJsonRequest performs a router API request with an optional json payload and decodes the json response
+//into result
+func performRouterJsonRequest(
+	client *http.Client,
+	method string,
+	url string,
+	username string,
+	password string,
+	headers map[string]string,
+	payload interface{},
+	result interface{}) error {
+
+	var body io.Reader
+	if payload != nil {
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payloadBytes)
+	}
+
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	if username != "" && password != "" {
+		request.SetBasicAuth(username, password)
+	}
+	request.Header.Set("Accept", "application/json")
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := response.Body.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	responseBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned http status code %d and response \n%s",
+			method, request.URL.Path, response.StatusCode, string(responseBytes))
+	}
+	return json.Unmarshal(responseBytes, result)
+}
+
+//parseAddressPrefix parses an address with an optional /prefix length suffix such as 192.0.2.1/24 and returns the
+//address and its network, the network is nil when no prefix length is present
+func parseAddressPrefix(value string) (net.IP, *net.IPNet) {
+	value = strings.TrimSpace(value)
+	if ip, network, err := net.ParseCIDR(value); err == nil {
+		return ip, network
+	}
+	return net.ParseIP(value), nil
+}
+
+//isUsableIPv4 returns an indicator that describes if the supplied address is a usable IPv4 WAN address, one that is not
+//unspecified, loopback or link-local. Private and carrier grade NAT addresses are usable, a router behind another NAT
+//reports them and they are left to the change validation and NAT detection
+func isUsableIPv4(ip net.IP) bool {
+	return ip != nil && ip.To4() != nil && !ip.IsUnspecified() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+//isGlobalIPv6 returns an indicator that describes if the supplied address is a global unicast IPv6 address
+func isGlobalIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil && ip.IsGlobalUnicast() && ip[0]&0xfe != 0xfc
+}
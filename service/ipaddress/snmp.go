@@ -0,0 +1,847 @@
+package ipaddress
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SNMP versions, PDU types and User-based Security Model (RFC 3414) constants
+const (
+	snmpVersion2c              = 1
+	snmpVersion3               = 3
+	snmpGetRequest        byte = 0xa0
+	snmpGetNextRequest    byte = 0xa1
+	snmpResponse          byte = 0xa2
+	snmpReport            byte = 0xa8
+	snmpDefaultPort            = "161"
+	snmpMaxWalkLength          = 10000
+	snmpMaxMessageSize         = 65507
+	snmpSecurityModelUSM       = 3
+	snmpFlagAuth               = 0x01
+	snmpFlagPriv               = 0x02
+	snmpFlagReportable         = 0x04
+	snmpAuthParamsLength       = 12
+	snmpPasswordKeyLength      = 1048576
+	snmpRequestAttempts        = 3
+)
+
+// SNMP v3 authentication and privacy protocols
+const (
+	SNMPAuthMD5 = "MD5"
+	SNMPAuthSHA = "SHA"
+	SNMPPrivDES = "DES"
+	SNMPPrivAES = "AES"
+)
+
+// the timeout applied to SNMP IP address sources when no Timeout is set
+const defaultSNMPSourceTimeout = 5 * time.Second
+
+// the IF-MIB and IP-MIB objects read by the SNMPSource and the USM statistics reported by SNMP v3 agents
+var (
+	oidIfDescr          = parseObjectID("1.3.6.1.2.1.2.2.1.2")
+	oidIfName           = parseObjectID("1.3.6.1.2.1.31.1.1.1.1")
+	oidIPAddressIfIndex = parseObjectID("1.3.6.1.2.1.4.34.1.3")
+	oidIPAdEntIfIndex   = parseObjectID("1.3.6.1.2.1.4.20.1.2")
+	oidUsmStats         = parseObjectID("1.3.6.1.6.3.15.1.1")
+)
+
+// the usmStats counters reported by SNMP v3 agents, keyed by their usmStats object number
+var usmStatsReports = map[uint32]string{
+	1: "unsupported security level",
+	2: "not in time window",
+	3: "unknown user name",
+	4: "unknown engine id",
+	5: "wrong digest",
+	6: "decryption error",
+}
+
+// the usmStatsNotInTimeWindows report object number, a request is retried once with the reported engine time
+const usmStatsNotInTimeWindows = 2
+
+/*
+The SNMPSource type that has the ability to poll a router over SNMP v2c or v3 for the addresses of its WAN interface
+
+IP-MIB docs: https://datatracker.ietf.org/doc/html/rfc4293
+USM docs:    https://datatracker.ietf.org/doc/html/rfc3414
+
+The WAN interface is matched on IF-MIB::ifName, then IF-MIB::ifDescr, or is given as a numeric ifIndex. The addresses
+are walked from IP-MIB::ipAddressIfIndex (IPv4 and IPv6) falling back to the older IP-MIB::ipAdEntIfIndex (IPv4 only)
+for agents without the newer table. Private, CGNAT, ULA and link-local addresses are never selected.
+
+SNMP v3 uses the User-based Security Model, authentication with MD5 or SHA (HMAC-96) when an AuthPassword is set and
+privacy with AES-128 (CFB) or DES (CBC) when a PrivPassword is set as well.
+*/
+type SNMPSource struct {
+	Address      string        // The agent host or host:port, the port defaults to 161
+	Version      string        // 2c (the default) or 3
+	Community    string        // SNMP v2c, the community, public when empty
+	Username     string        // SNMP v3, the USM user name
+	AuthProtocol string        // SNMP v3, MD5 or SHA (the default)
+	AuthPassword string        // SNMP v3, the authentication password, noAuthNoPriv when empty
+	PrivProtocol string        // SNMP v3, DES or AES (the default)
+	PrivPassword string        // SNMP v3, the privacy password, authNoPriv when empty
+	Interface    string        // The WAN interface ifName, ifDescr or ifIndex, all interfaces when empty
+	Family       string        // ipv4, ipv6 or empty to read both address families
+	Timeout      time.Duration // The timeout of each SNMP request including retransmissions
+}
+
+//snmpClient performs SNMP requests against a single agent
+type snmpClient struct {
+	conn      net.Conn
+	timeout   time.Duration
+	version   int
+	community string
+	requestID int32
+	usm       *snmpUSM
+}
+
+//snmpUSM holds the SNMP v3 User-based Security Model state of an snmpClient
+type snmpUSM struct {
+	username     string
+	authProtocol string
+	privProtocol string
+	authPassword string
+	privPassword string
+	authKey      []byte
+	privKey      []byte
+	engineID     []byte
+	engineBoots  int64
+	engineTime   int64
+	discovered   time.Time
+	salt         uint64
+}
+
+//snmpVarBind is a decoded SNMP variable binding
+type snmpVarBind struct {
+	oid   []uint32
+	value berElement
+}
+
+//snmpV3Message is a decoded SNMP v3 message. The authParams slice shares the memory of the decoded buffer
+type snmpV3Message struct {
+	msgID       int64
+	flags       byte
+	engineID    []byte
+	engineBoots int64
+	engineTime  int64
+	authParams  []byte
+	privParams  []byte
+	msgData     berElement
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider SNMPSource) String() string {
+	return fmt.Sprintf("%s SNMP IP address provider", ipProvider.Address)
+}
+
+// GetPublicIPAddresses walks the IP-MIB address tables of the agent and returns the public IPv4 and IPv6 addresses of
+// the configured WAN interface
+func (ipProvider SNMPSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	client, err := ipProvider.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		err := client.conn.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	ifIndex := int64(-1)
+	if ipProvider.Interface != "" {
+		if ifIndex, err = client.findInterface(ipProvider.Interface); err != nil {
+			return nil, nil, fmt.Errorf("the %s failed: %v", ipProvider, err)
+		}
+	}
+
+	addresses, err := client.getInterfaceAddresses(ifIndex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the %s failed: %v", ipProvider, err)
+	}
+
+	var ipv4, ipv6 net.IP
+	if ipProvider.Family != FamilyIPv6 {
+		ipv4 = selectAddress(addresses, FamilyIPv4, nil, nil)
+	}
+	if ipProvider.Family != FamilyIPv4 {
+		ipv6 = selectAddress(addresses, FamilyIPv6, nil, nil)
+	}
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s found no public IP address on interface %s", ipProvider, ipProvider.Interface)
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider SNMPSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//connect dials the agent and, for SNMP v3, discovers the authoritative engine and localizes the keys
+func (ipProvider SNMPSource) connect() (*snmpClient, error) {
+	if ipProvider.Address == "" {
+		return nil, errors.New("the SNMP IP address source requires an agent address")
+	}
+	address := ipProvider.Address
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, snmpDefaultPort)
+	}
+	timeout := ipProvider.Timeout
+	if timeout <= 0 {
+		timeout = defaultSNMPSourceTimeout
+	}
+
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var requestID [4]byte
+	if _, err = rand.Read(requestID[:]); err != nil {
+		return nil, err
+	}
+	client := &snmpClient{
+		conn:      conn,
+		timeout:   timeout,
+		requestID: int32(binary.BigEndian.Uint32(requestID[:]) & 0x7fffffff),
+	}
+
+	switch ipProvider.Version {
+	case "", "2c":
+		client.version = snmpVersion2c
+		client.community = ipProvider.Community
+		if client.community == "" {
+			client.community = "public"
+		}
+		return client, nil
+	case "3":
+		client.version = snmpVersion3
+		client.usm = &snmpUSM{
+			username:     ipProvider.Username,
+			authProtocol: ipProvider.AuthProtocol,
+			privProtocol: ipProvider.PrivProtocol,
+			authPassword: ipProvider.AuthPassword,
+			privPassword: ipProvider.PrivPassword,
+		}
+		if err = client.discoverEngine(); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("the %s engine discovery failed: %v", ipProvider, err)
+		}
+		return client, nil
+	default:
+		_ = conn.Close()
+		return nil, fmt.Errorf("unsupported SNMP version %s", ipProvider.Version)
+	}
+}
+
+//findInterface returns the ifIndex of the interface with the supplied ifName, ifDescr or numeric ifIndex
+func (client *snmpClient) findInterface(name string) (int64, error) {
+	if ifIndex, err := strconv.ParseInt(name, 10, 32); err == nil {
+		return ifIndex, nil
+	}
+	for _, table := range [][]uint32{oidIfName, oidIfDescr} {
+		ifIndex := int64(-1)
+		err := client.walk(table, func(oid []uint32, value berElement) {
+			if ifIndex < 0 && value.tag == berOctetString && string(value.content) == name {
+				ifIndex = int64(oid[len(oid)-1])
+			}
+		})
+		if err != nil {
+			return 0, err
+		}
+		if ifIndex >= 0 {
+			return ifIndex, nil
+		}
+	}
+	return 0, fmt.Errorf("no interface named %s was found", name)
+}
+
+//getInterfaceAddresses returns the addresses of the interface with the supplied ifIndex, or of all interfaces when the
+//ifIndex is negative
+func (client *snmpClient) getInterfaceAddresses(ifIndex int64) ([]net.IP, error) {
+	var addresses []net.IP
+	//ipAddressIfIndex.<ipAddressAddrType>.<length>.<address octets> = ifIndex
+	err := client.walk(oidIPAddressIfIndex, func(oid []uint32, value berElement) {
+		index := oid[len(oidIPAddressIfIndex):]
+		if len(index) < 2 || int(index[1]) != len(index)-2 || !snmpIfIndexMatches(value, ifIndex) {
+			return
+		}
+		//address types 1 (ipv4) and 2 (ipv6), the zoned types are never public
+		if (index[0] == 1 && index[1] == net.IPv4len) || (index[0] == 2 && index[1] == net.IPv6len) {
+			addresses = append(addresses, snmpObjectIDToIP(index[2:]))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) > 0 {
+		return addresses, nil
+	}
+
+	//ipAdEntIfIndex.<a>.<b>.<c>.<d> = ifIndex
+	err = client.walk(oidIPAdEntIfIndex, func(oid []uint32, value berElement) {
+		index := oid[len(oidIPAdEntIfIndex):]
+		if len(index) == net.IPv4len && snmpIfIndexMatches(value, ifIndex) {
+			addresses = append(addresses, snmpObjectIDToIP(index))
+		}
+	})
+	return addresses, err
+}
+
+//walk performs GetNext requests from the supplied root and calls visit for each object below the root
+func (client *snmpClient) walk(root []uint32, visit func(oid []uint32, value berElement)) error {
+	current := root
+	for count := 0; count < snmpMaxWalkLength; count++ {
+		varBinds, err := client.request(snmpGetNextRequest, current)
+		if err != nil {
+			return err
+		}
+		if len(varBinds) == 0 {
+			return errors.New("the agent returned no variable bindings")
+		}
+		varBind := varBinds[0]
+		if varBind.value.tag == berEndOfMibView || !hasObjectIDPrefix(varBind.oid, root) {
+			return nil
+		}
+		if compareObjectIDs(varBind.oid, current) <= 0 {
+			return fmt.Errorf("the agent returned %s which does not follow %s",
+				formatObjectID(varBind.oid), formatObjectID(current))
+		}
+		visit(varBind.oid, varBind.value)
+		current = varBind.oid
+	}
+	return fmt.Errorf("the walk of %s exceeded %d objects", formatObjectID(root), snmpMaxWalkLength)
+}
+
+//request sends a PDU of the supplied type for a single object and returns the variable bindings of the response.
+//SNMP v3 requests rejected as not in time window are retried once with the engine time of the report
+func (client *snmpClient) request(pduType byte, oid []uint32) ([]snmpVarBind, error) {
+	varBinds, report, err := client.exchange(pduType, [][]uint32{oid})
+	if err == nil && report == usmStatsNotInTimeWindows {
+		varBinds, report, err = client.exchange(pduType, [][]uint32{oid})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report != 0 {
+		return nil, fmt.Errorf("the agent reported %s", usmStatsReports[report])
+	}
+	return varBinds, nil
+}
+
+//exchange sends a PDU and waits for the matching response, retransmitting the request when no response arrives. The
+//usmStats number of a Report PDU is returned instead of the variable bindings when the agent rejects the request
+func (client *snmpClient) exchange(pduType byte, oids [][]uint32) ([]snmpVarBind, uint32, error) {
+	client.requestID++
+	requestID := client.requestID
+
+	var varBindList [][]byte
+	for _, oid := range oids {
+		varBindList = append(varBindList,
+			berEncodeSequence(berSequence, berEncodeObjectID(oid), berEncode(berNull, nil)))
+	}
+	pdu := berEncodeSequence(pduType,
+		berEncodeInteger(int64(requestID)),
+		berEncodeInteger(0),
+		berEncodeInteger(0),
+		berEncodeSequence(berSequence, varBindList...))
+
+	var message []byte
+	var err error
+	if client.version == snmpVersion3 {
+		if message, err = client.usm.encodeMessage(int64(requestID), pdu); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		message = berEncodeSequence(berSequence,
+			berEncodeInteger(snmpVersion2c),
+			berEncodeOctetString([]byte(client.community)),
+			pdu)
+	}
+
+	buffer := make([]byte, snmpMaxMessageSize)
+	for attempt := 0; attempt < snmpRequestAttempts; attempt++ {
+		if _, err = client.conn.Write(message); err != nil {
+			return nil, 0, err
+		}
+		deadline := time.Now().Add(client.timeout / snmpRequestAttempts)
+		if err = client.conn.SetReadDeadline(deadline); err != nil {
+			return nil, 0, err
+		}
+		for {
+			length, err := client.conn.Read(buffer)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					break
+				}
+				return nil, 0, err
+			}
+			responsePDU, err := client.decodeMessage(buffer[:length], requestID)
+			if err != nil {
+				log.Printf("Discarding an SNMP response from %s: %v", client.conn.RemoteAddr(), err)
+				continue
+			}
+			if responsePDU == nil {
+				//a stale response to an earlier request
+				continue
+			}
+			return decodeSNMPPDU(*responsePDU)
+		}
+	}
+	return nil, 0, fmt.Errorf("no SNMP response was received from %s within %s", client.conn.RemoteAddr(), client.timeout)
+}
+
+//decodeMessage decodes an SNMP message and returns its PDU, nil is returned for messages of other requests
+func (client *snmpClient) decodeMessage(data []byte, requestID int32) (*berElement, error) {
+	if client.version == snmpVersion3 {
+		return client.usm.decodeMessage(data, int64(requestID))
+	}
+
+	top, _, err := berDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := berDecodeSequence(top, berSequence)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 3 {
+		return nil, errors.New("malformed SNMP message")
+	}
+	if version, err := berDecodeInteger(elements[0]); err != nil || version != snmpVersion2c {
+		return nil, errors.New("unexpected SNMP version")
+	}
+	pduElements, err := berDecodeSequence(berElement{tag: berSequence, content: elements[2].content}, berSequence)
+	if err != nil || len(pduElements) == 0 {
+		return nil, errors.New("malformed SNMP PDU")
+	}
+	if id, err := berDecodeInteger(pduElements[0]); err != nil || id != int64(requestID) {
+		return nil, nil
+	}
+	return &elements[2], nil
+}
+
+//discoverEngine discovers the authoritative engine id, boots and time of the agent (RFC 3414 section 4) and localizes
+//the authentication and privacy keys
+func (client *snmpClient) discoverEngine() error {
+	usm := client.usm
+	if usm.username == "" {
+		return errors.New("SNMP v3 requires a username")
+	}
+	if usm.authPassword == "" && usm.privPassword != "" {
+		return errors.New("SNMP v3 privacy requires an authentication password")
+	}
+
+	//the discovery request is sent unauthenticated with an empty engine id, the agent answers with a report
+	_, _, err := client.exchange(snmpGetRequest, nil)
+	if err != nil {
+		return err
+	}
+	if len(usm.engineID) == 0 {
+		return errors.New("the agent did not report its engine id")
+	}
+
+	if usm.authPassword == "" {
+		return nil
+	}
+	if usm.authKey, err = snmpLocalizeKey(usm.authProtocol, usm.authPassword, usm.engineID); err != nil {
+		return err
+	}
+	if usm.privPassword == "" {
+		return nil
+	}
+	switch usm.privProtocol {
+	case "", SNMPPrivAES, SNMPPrivDES:
+	default:
+		return fmt.Errorf("unsupported SNMP v3 privacy protocol %s", usm.privProtocol)
+	}
+	if usm.privKey, err = snmpLocalizeKey(usm.authProtocol, usm.privPassword, usm.engineID); err != nil {
+		return err
+	}
+	var salt [8]byte
+	if _, err = rand.Read(salt[:]); err != nil {
+		return err
+	}
+	usm.salt = binary.BigEndian.Uint64(salt[:])
+	return nil
+}
+
+//encodeMessage returns the SNMP v3 message carrying the supplied PDU, authenticated and encrypted when keys are set
+func (usm *snmpUSM) encodeMessage(msgID int64, pdu []byte) ([]byte, error) {
+	flags := byte(snmpFlagReportable)
+	engineTime := usm.engineTime
+	if !usm.discovered.IsZero() {
+		engineTime += int64(time.Since(usm.discovered).Seconds())
+	}
+
+	msgData := berEncodeSequence(berSequence, berEncodeOctetString(usm.engineID), berEncodeOctetString(nil), pdu)
+	var privParams []byte
+	if usm.privKey != nil {
+		flags |= snmpFlagPriv
+		encrypted, salt, err := usm.encrypt(msgData, usm.engineBoots, engineTime)
+		if err != nil {
+			return nil, err
+		}
+		msgData = berEncodeOctetString(encrypted)
+		privParams = salt
+	}
+	var authParams []byte
+	username := ""
+	if usm.authKey != nil {
+		flags |= snmpFlagAuth
+		authParams = make([]byte, snmpAuthParamsLength)
+	}
+	if usm.engineID != nil {
+		//the discovery request carries no user name
+		username = usm.username
+	}
+
+	securityParams := berEncodeSequence(berSequence,
+		berEncodeOctetString(usm.engineID),
+		berEncodeInteger(usm.engineBoots),
+		berEncodeInteger(engineTime),
+		berEncodeOctetString([]byte(username)),
+		berEncodeOctetString(authParams),
+		berEncodeOctetString(privParams))
+	message := berEncodeSequence(berSequence,
+		berEncodeInteger(snmpVersion3),
+		berEncodeSequence(berSequence,
+			berEncodeInteger(msgID),
+			berEncodeInteger(snmpMaxMessageSize),
+			berEncodeOctetString([]byte{flags}),
+			berEncodeInteger(snmpSecurityModelUSM)),
+		berEncodeOctetString(securityParams),
+		msgData)
+
+	if usm.authKey != nil {
+		//the digest is calculated over the whole message with zeroed authParams and written into the authParams
+		decoded, err := decodeSNMPV3Message(message)
+		if err != nil {
+			return nil, err
+		}
+		copy(decoded.authParams, usm.digest(message))
+	}
+	return message, nil
+}
+
+//decodeMessage verifies and decrypts an SNMP v3 message and returns its PDU, nil is returned for messages of other
+//requests. The engine id, boots and time of reports are kept for the following requests
+func (usm *snmpUSM) decodeMessage(data []byte, msgID int64) (*berElement, error) {
+	message, err := decodeSNMPV3Message(data)
+	if err != nil {
+		return nil, err
+	}
+	if message.msgID != msgID {
+		return nil, nil
+	}
+
+	if message.flags&snmpFlagAuth != 0 {
+		if usm.authKey == nil || len(message.authParams) != snmpAuthParamsLength {
+			return nil, errors.New("unexpected authenticated SNMP v3 message")
+		}
+		received := append([]byte(nil), message.authParams...)
+		for index := range message.authParams {
+			message.authParams[index] = 0
+		}
+		expected := usm.digest(data)
+		copy(message.authParams, received)
+		if !hmac.Equal(received, expected) {
+			return nil, errors.New("the SNMP v3 message digest is wrong")
+		}
+	}
+
+	scopedPDU := message.msgData
+	if message.flags&snmpFlagPriv != 0 {
+		if usm.privKey == nil || scopedPDU.tag != berOctetString {
+			return nil, errors.New("unexpected encrypted SNMP v3 message")
+		}
+		plaintext, err := usm.decrypt(scopedPDU.content, message.privParams, message.engineBoots, message.engineTime)
+		if err != nil {
+			return nil, err
+		}
+		if scopedPDU, _, err = berDecode(plaintext); err != nil {
+			return nil, err
+		}
+	}
+	elements, err := berDecodeSequence(scopedPDU, berSequence)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 3 {
+		return nil, errors.New("malformed SNMP v3 scoped PDU")
+	}
+
+	if elements[2].tag == snmpReport || usm.engineID == nil {
+		usm.engineID = append([]byte(nil), message.engineID...)
+		usm.engineBoots = message.engineBoots
+		usm.engineTime = message.engineTime
+		usm.discovered = time.Now()
+	}
+	return &elements[2], nil
+}
+
+//digest returns the HMAC-96 digest of the supplied message
+func (usm *snmpUSM) digest(message []byte) []byte {
+	mac := hmac.New(snmpHash(usm.authProtocol), usm.authKey)
+	mac.Write(message)
+	return mac.Sum(nil)[:snmpAuthParamsLength]
+}
+
+//encrypt encrypts the scoped PDU with AES-128 CFB (RFC 3826) or DES CBC (RFC 3414) and returns it with the salt that
+//is sent as the privacy parameters
+func (usm *snmpUSM) encrypt(plaintext []byte, engineBoots, engineTime int64) ([]byte, []byte, error) {
+	usm.salt++
+	salt := make([]byte, 8)
+	if usm.privProtocol == SNMPPrivDES {
+		binary.BigEndian.PutUint32(salt, uint32(engineBoots))
+		binary.BigEndian.PutUint32(salt[4:], uint32(usm.salt))
+		block, err := des.NewCipher(usm.privKey[:8])
+		if err != nil {
+			return nil, nil, err
+		}
+		padded := append([]byte(nil), plaintext...)
+		if remainder := len(padded) % des.BlockSize; remainder != 0 {
+			padded = append(padded, make([]byte, des.BlockSize-remainder)...)
+		}
+		ciphertext := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, snmpDESIV(usm.privKey, salt)).CryptBlocks(ciphertext, padded)
+		return ciphertext, salt, nil
+	}
+
+	binary.BigEndian.PutUint64(salt, usm.salt)
+	block, err := aes.NewCipher(usm.privKey[:16])
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCFBEncrypter(block, snmpAESIV(engineBoots, engineTime, salt)).XORKeyStream(ciphertext, plaintext)
+	return ciphertext, salt, nil
+}
+
+//decrypt decrypts an encrypted scoped PDU using the salt received as the privacy parameters
+func (usm *snmpUSM) decrypt(ciphertext, salt []byte, engineBoots, engineTime int64) ([]byte, error) {
+	if len(salt) != 8 {
+		return nil, errors.New("invalid SNMP v3 privacy parameters")
+	}
+	if usm.privProtocol == SNMPPrivDES {
+		if len(ciphertext)%des.BlockSize != 0 {
+			return nil, errors.New("invalid SNMP v3 DES encrypted PDU length")
+		}
+		block, err := des.NewCipher(usm.privKey[:8])
+		if err != nil {
+			return nil, err
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, snmpDESIV(usm.privKey, salt)).CryptBlocks(plaintext, ciphertext)
+		return plaintext, nil
+	}
+
+	block, err := aes.NewCipher(usm.privKey[:16])
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCFBDecrypter(block, snmpAESIV(engineBoots, engineTime, salt)).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+//decodeSNMPV3Message decodes the header and security parameters of an SNMP v3 message
+func decodeSNMPV3Message(data []byte) (*snmpV3Message, error) {
+	top, _, err := berDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := berDecodeSequence(top, berSequence)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 4 {
+		return nil, errors.New("malformed SNMP v3 message")
+	}
+	if version, err := berDecodeInteger(elements[0]); err != nil || version != snmpVersion3 {
+		return nil, errors.New("unexpected SNMP version")
+	}
+
+	globalData, err := berDecodeSequence(elements[1], berSequence)
+	if err != nil || len(globalData) != 4 || len(globalData[2].content) != 1 {
+		return nil, errors.New("malformed SNMP v3 global data")
+	}
+	message := &snmpV3Message{flags: globalData[2].content[0], msgData: elements[3]}
+	if message.msgID, err = berDecodeInteger(globalData[0]); err != nil {
+		return nil, err
+	}
+	if model, err := berDecodeInteger(globalData[3]); err != nil || model != snmpSecurityModelUSM {
+		return nil, errors.New("unsupported SNMP v3 security model")
+	}
+
+	if elements[2].tag != berOctetString {
+		return nil, errors.New("malformed SNMP v3 security parameters")
+	}
+	securityElement, _, err := berDecode(elements[2].content)
+	if err != nil {
+		return nil, err
+	}
+	securityParams, err := berDecodeSequence(securityElement, berSequence)
+	if err != nil || len(securityParams) != 6 {
+		return nil, errors.New("malformed SNMP v3 security parameters")
+	}
+	message.engineID = securityParams[0].content
+	if message.engineBoots, err = berDecodeInteger(securityParams[1]); err != nil {
+		return nil, err
+	}
+	if message.engineTime, err = berDecodeInteger(securityParams[2]); err != nil {
+		return nil, err
+	}
+	message.authParams = securityParams[4].content
+	message.privParams = securityParams[5].content
+	return message, nil
+}
+
+//decodeSNMPPDU decodes a Response or Report PDU and returns its variable bindings, or the usmStats number of a Report
+func decodeSNMPPDU(pdu berElement) ([]snmpVarBind, uint32, error) {
+	if pdu.tag != snmpResponse && pdu.tag != snmpReport {
+		return nil, 0, fmt.Errorf("unexpected SNMP PDU type 0x%02x", pdu.tag)
+	}
+	elements, err := berDecodeSequence(berElement{tag: berSequence, content: pdu.content}, berSequence)
+	if err != nil || len(elements) != 4 {
+		return nil, 0, errors.New("malformed SNMP PDU")
+	}
+	errorStatus, err := berDecodeInteger(elements[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	if errorStatus != 0 {
+		return nil, 0, fmt.Errorf("the agent returned SNMP error status %d", errorStatus)
+	}
+
+	varBindList, err := berDecodeSequence(elements[3], berSequence)
+	if err != nil {
+		return nil, 0, err
+	}
+	var varBinds []snmpVarBind
+	for _, element := range varBindList {
+		pair, err := berDecodeSequence(element, berSequence)
+		if err != nil || len(pair) != 2 {
+			return nil, 0, errors.New("malformed SNMP variable binding")
+		}
+		oid, err := berDecodeObjectID(pair[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		varBinds = append(varBinds, snmpVarBind{oid: oid, value: pair[1]})
+	}
+
+	if pdu.tag == snmpReport {
+		//usmStats.<number>.0
+		for _, varBind := range varBinds {
+			if hasObjectIDPrefix(varBind.oid, oidUsmStats) {
+				return nil, varBind.oid[len(oidUsmStats)], nil
+			}
+		}
+		//the engine discovery report
+		return nil, 0, nil
+	}
+	return varBinds, 0, nil
+}
+
+//snmpLocalizeKey derives the localized key of a password for the supplied engine id (RFC 3414 appendix A.2)
+func snmpLocalizeKey(authProtocol, password string, engineID []byte) ([]byte, error) {
+	if len(password) < 8 {
+		return nil, errors.New("SNMP v3 passwords need at least 8 characters")
+	}
+	switch authProtocol {
+	case "", SNMPAuthSHA, SNMPAuthMD5:
+	default:
+		return nil, fmt.Errorf("unsupported SNMP v3 authentication protocol %s", authProtocol)
+	}
+
+	newHash := snmpHash(authProtocol)
+	passwordHash := newHash()
+	block := make([]byte, 64)
+	for count := 0; count < snmpPasswordKeyLength; count += len(block) {
+		for index := range block {
+			block[index] = password[(count+index)%len(password)]
+		}
+		passwordHash.Write(block)
+	}
+	key := passwordHash.Sum(nil)
+
+	localizedHash := newHash()
+	localizedHash.Write(key)
+	localizedHash.Write(engineID)
+	localizedHash.Write(key)
+	return localizedHash.Sum(nil), nil
+}
+
+//snmpHash returns the hash function of the supplied authentication protocol
+func snmpHash(authProtocol string) func() hash.Hash {
+	if authProtocol == SNMPAuthMD5 {
+		return md5.New
+	}
+	return sha1.New
+}
+
+//snmpAESIV returns the AES initialization vector made of the engine boots, engine time and salt (RFC 3826)
+func snmpAESIV(engineBoots, engineTime int64, salt []byte) []byte {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint32(iv, uint32(engineBoots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
+
+//snmpDESIV returns the DES initialization vector, the pre-IV of the privacy key XOR the salt (RFC 3414 8.1.1.1)
+func snmpDESIV(privKey, salt []byte) []byte {
+	iv := make([]byte, des.BlockSize)
+	for index := range iv {
+		iv[index] = privKey[8+index] ^ salt[index]
+	}
+	return iv
+}
+
+//snmpIfIndexMatches returns an indicator that describes if an ifIndex value matches the supplied ifIndex, any
+//ifIndex matches a negative ifIndex
+func snmpIfIndexMatches(value berElement, ifIndex int64) bool {
+	if ifIndex < 0 {
+		return true
+	}
+	valueIfIndex, err := berDecodeInteger(value)
+	return err == nil && value.tag == berInteger && valueIfIndex == ifIndex
+}
+
+//snmpObjectIDToIP returns the address held by the supplied object identifier arcs, one arc per address octet
+func snmpObjectIDToIP(arcs []uint32) net.IP {
+	ip := make(net.IP, len(arcs))
+	for index, arc := range arcs {
+		ip[index] = byte(arc)
+	}
+	return ip
+}
+
+//compareObjectIDs compares two object identifiers in lexicographic order
+func compareObjectIDs(a, b []uint32) int {
+	for index := 0; index < len(a) && index < len(b); index++ {
+		if a[index] != b[index] {
+			if a[index] < b[index] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(a) - len(b)
+}
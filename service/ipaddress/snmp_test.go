@@ -0,0 +1,581 @@
+package ipaddress
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// the engine id of the RFC 3414 appendix A.3 key localization vectors, also used by the test agent
+const snmpTestEngineID = "000000000000000000000002"
+
+// the Counter32 application tag of the usmStats report values
+const snmpTestCounter32 byte = 0x41
+
+//snmpTestObject is an object of the MIB served by an snmpTestAgent
+type snmpTestObject struct {
+	oid   []uint32
+	value []byte
+}
+
+//snmpTestAgent is an SNMP agent stand-in answering GetNext requests from a fixed MIB over UDP on 127.0.0.1. SNMP v3
+//requests are verified and decrypted independently of the snmpUSM of the client
+type snmpTestAgent struct {
+	t            *testing.T
+	conn         net.PacketConn
+	mib          []snmpTestObject
+	community    string
+	authProtocol string
+	authPassword string
+	privProtocol string
+	privPassword string
+	authKey      []byte
+	privKey      []byte
+	engineID     []byte
+	engineBoots  int64
+	engineTime   int64
+	salt         uint64
+
+	rebootAfterDiscovery bool  // the engine boots after the discovery so the first request is not in the time window
+	timeWindowReports    int32 // the number of usmStatsNotInTimeWindows reports sent
+}
+
+//start starts the agent and returns its address, the agent is stopped when the test completes
+func (agent *snmpTestAgent) start(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	agent.t = t
+	agent.conn = conn
+
+	sort.Slice(agent.mib, func(i, j int) bool {
+		return compareObjectIDs(agent.mib[i].oid, agent.mib[j].oid) < 0
+	})
+	if agent.engineID != nil {
+		if agent.authKey, err = snmpLocalizeKey(agent.authProtocol, agent.authPassword, agent.engineID); err != nil {
+			t.Fatal(err)
+		}
+		if agent.privKey, err = snmpLocalizeKey(agent.authProtocol, agent.privPassword, agent.engineID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	go agent.serve()
+	return conn.LocalAddr().String()
+}
+
+//serve answers the requests until the connection is closed
+func (agent *snmpTestAgent) serve() {
+	buffer := make([]byte, snmpMaxMessageSize)
+	for {
+		length, address, err := agent.conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		var response []byte
+		if agent.engineID == nil {
+			response, err = agent.handleV2c(buffer[:length])
+		} else {
+			response, err = agent.handleV3(buffer[:length])
+		}
+		if err != nil {
+			agent.t.Errorf("the SNMP test agent rejected a request: %v", err)
+			continue
+		}
+		if _, err = agent.conn.WriteTo(response, address); err != nil {
+			return
+		}
+	}
+}
+
+//handleV2c answers an SNMP v2c message
+func (agent *snmpTestAgent) handleV2c(data []byte) ([]byte, error) {
+	top, _, err := berDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := berDecodeSequence(top, berSequence)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 3 {
+		return nil, errors.New("malformed SNMP v2c message")
+	}
+	if version, err := berDecodeInteger(elements[0]); err != nil || version != snmpVersion2c {
+		return nil, errors.New("unexpected SNMP version")
+	}
+	if string(elements[1].content) != agent.community {
+		return nil, fmt.Errorf("unexpected community %s", elements[1].content)
+	}
+	response, err := agent.respond(elements[2])
+	if err != nil {
+		return nil, err
+	}
+	return berEncodeSequence(berSequence,
+		berEncodeInteger(snmpVersion2c),
+		berEncodeOctetString(elements[1].content),
+		response), nil
+}
+
+//handleV3 answers an SNMP v3 engine discovery request or authPriv request
+func (agent *snmpTestAgent) handleV3(data []byte) ([]byte, error) {
+	message, err := decodeSNMPV3Message(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(message.engineID) == 0 {
+		//the discovery request is answered with an unauthenticated usmStatsUnknownEngineIDs report
+		response, err := agent.encodeV3(message.msgID, 0, agent.scopedPDU(agent.report(4)))
+		if agent.rebootAfterDiscovery {
+			agent.rebootAfterDiscovery = false
+			agent.engineBoots++
+			agent.engineTime = 5
+		}
+		return response, err
+	}
+
+	if message.flags&(snmpFlagAuth|snmpFlagPriv) != snmpFlagAuth|snmpFlagPriv {
+		return nil, fmt.Errorf("expected an authPriv request, the flags are 0x%02x", message.flags)
+	}
+	if !bytes.Equal(message.engineID, agent.engineID) {
+		return nil, fmt.Errorf("unexpected engine id %x", message.engineID)
+	}
+	if err = agent.verify(data, message.authParams); err != nil {
+		return nil, err
+	}
+	if message.engineBoots != agent.engineBoots || message.engineTime > agent.engineTime+150 ||
+		message.engineTime < agent.engineTime-150 {
+		atomic.AddInt32(&agent.timeWindowReports, 1)
+		return agent.encodeV3(message.msgID, snmpFlagAuth, agent.scopedPDU(agent.report(usmStatsNotInTimeWindows)))
+	}
+
+	if message.msgData.tag != berOctetString {
+		return nil, errors.New("the scoped PDU is not encrypted")
+	}
+	plaintext, err := agent.decrypt(message.msgData.content, message.privParams, message.engineBoots,
+		message.engineTime)
+	if err != nil {
+		return nil, err
+	}
+	scopedPDU, _, err := berDecode(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := berDecodeSequence(scopedPDU, berSequence)
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 3 {
+		return nil, errors.New("malformed SNMP v3 scoped PDU")
+	}
+	response, err := agent.respond(elements[2])
+	if err != nil {
+		return nil, err
+	}
+	return agent.encodeV3(message.msgID, snmpFlagAuth|snmpFlagPriv, agent.scopedPDU(response))
+}
+
+//respond returns the Response PDU of a GetNext request
+func (agent *snmpTestAgent) respond(pdu berElement) ([]byte, error) {
+	if pdu.tag != snmpGetNextRequest {
+		return nil, fmt.Errorf("unexpected PDU type 0x%02x", pdu.tag)
+	}
+	elements, err := berDecodeSequence(berElement{tag: berSequence, content: pdu.content}, berSequence)
+	if err != nil || len(elements) != 4 {
+		return nil, errors.New("malformed SNMP PDU")
+	}
+	requestID, err := berDecodeInteger(elements[0])
+	if err != nil {
+		return nil, err
+	}
+	varBinds, err := berDecodeSequence(elements[3], berSequence)
+	if err != nil || len(varBinds) != 1 {
+		return nil, errors.New("expected a single variable binding")
+	}
+	pair, err := berDecodeSequence(varBinds[0], berSequence)
+	if err != nil || len(pair) != 2 {
+		return nil, errors.New("malformed SNMP variable binding")
+	}
+	oid, err := berDecodeObjectID(pair[0])
+	if err != nil {
+		return nil, err
+	}
+
+	varBind := berEncodeSequence(berSequence, berEncodeObjectID(oid), berEncode(berEndOfMibView, nil))
+	for _, object := range agent.mib {
+		if compareObjectIDs(object.oid, oid) > 0 {
+			varBind = berEncodeSequence(berSequence, berEncodeObjectID(object.oid), object.value)
+			break
+		}
+	}
+	return berEncodeSequence(snmpResponse,
+		berEncodeInteger(requestID),
+		berEncodeInteger(0),
+		berEncodeInteger(0),
+		berEncodeSequence(berSequence, varBind)), nil
+}
+
+//report returns a Report PDU carrying the supplied usmStats counter
+func (agent *snmpTestAgent) report(usmStats uint32) []byte {
+	oid := append(append([]uint32(nil), oidUsmStats...), usmStats, 0)
+	return berEncodeSequence(snmpReport,
+		berEncodeInteger(0),
+		berEncodeInteger(0),
+		berEncodeInteger(0),
+		berEncodeSequence(berSequence,
+			berEncodeSequence(berSequence, berEncodeObjectID(oid), berEncode(snmpTestCounter32, []byte{1}))))
+}
+
+//scopedPDU returns the scoped PDU of the agent context carrying the supplied PDU
+func (agent *snmpTestAgent) scopedPDU(pdu []byte) []byte {
+	return berEncodeSequence(berSequence, berEncodeOctetString(agent.engineID), berEncodeOctetString(nil), pdu)
+}
+
+//encodeV3 returns the SNMP v3 message carrying the supplied scoped PDU at the security level of the supplied flags
+func (agent *snmpTestAgent) encodeV3(msgID int64, flags byte, scopedPDU []byte) ([]byte, error) {
+	msgData := scopedPDU
+	var authParams, privParams []byte
+	if flags&snmpFlagPriv != 0 {
+		encrypted, salt, err := agent.encrypt(scopedPDU)
+		if err != nil {
+			return nil, err
+		}
+		msgData = berEncodeOctetString(encrypted)
+		privParams = salt
+	}
+	if flags&snmpFlagAuth != 0 {
+		authParams = make([]byte, snmpAuthParamsLength)
+	}
+
+	securityParams := berEncodeSequence(berSequence,
+		berEncodeOctetString(agent.engineID),
+		berEncodeInteger(agent.engineBoots),
+		berEncodeInteger(agent.engineTime),
+		berEncodeOctetString(nil),
+		berEncodeOctetString(authParams),
+		berEncodeOctetString(privParams))
+	message := berEncodeSequence(berSequence,
+		berEncodeInteger(snmpVersion3),
+		berEncodeSequence(berSequence,
+			berEncodeInteger(msgID),
+			berEncodeInteger(snmpMaxMessageSize),
+			berEncodeOctetString([]byte{flags}),
+			berEncodeInteger(snmpSecurityModelUSM)),
+		berEncodeOctetString(securityParams),
+		msgData)
+
+	if flags&snmpFlagAuth != 0 {
+		decoded, err := decodeSNMPV3Message(message)
+		if err != nil {
+			return nil, err
+		}
+		copy(decoded.authParams, agent.digest(message))
+	}
+	return message, nil
+}
+
+//verify checks the HMAC-96 digest of a received message
+func (agent *snmpTestAgent) verify(data []byte, authParams []byte) error {
+	if len(authParams) != snmpAuthParamsLength {
+		return fmt.Errorf("unexpected authentication parameters of %d bytes", len(authParams))
+	}
+	received := append([]byte(nil), authParams...)
+	for index := range authParams {
+		authParams[index] = 0
+	}
+	expected := agent.digest(data)
+	copy(authParams, received)
+	if !hmac.Equal(received, expected) {
+		return errors.New("wrong digest")
+	}
+	return nil
+}
+
+//digest returns the HMAC-MD5-96 or HMAC-SHA-96 digest of the supplied message
+func (agent *snmpTestAgent) digest(message []byte) []byte {
+	newHash := sha1.New
+	if agent.authProtocol == SNMPAuthMD5 {
+		newHash = md5.New
+	}
+	mac := hmac.New(newHash, agent.authKey)
+	mac.Write(message)
+	return mac.Sum(nil)[:snmpAuthParamsLength]
+}
+
+//encrypt encrypts a scoped PDU with AES-128 CFB or DES CBC and returns it with the salt
+func (agent *snmpTestAgent) encrypt(plaintext []byte) ([]byte, []byte, error) {
+	agent.salt++
+	salt := make([]byte, 8)
+	if agent.privProtocol == SNMPPrivDES {
+		binary.BigEndian.PutUint32(salt, uint32(agent.engineBoots))
+		binary.BigEndian.PutUint32(salt[4:], uint32(agent.salt))
+		block, err := des.NewCipher(agent.privKey[:8])
+		if err != nil {
+			return nil, nil, err
+		}
+		padded := append(append([]byte(nil), plaintext...), make([]byte, (8-len(plaintext)%8)%8)...)
+		ciphertext := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, agent.desIV(salt)).CryptBlocks(ciphertext, padded)
+		return ciphertext, salt, nil
+	}
+
+	binary.BigEndian.PutUint64(salt, agent.salt)
+	block, err := aes.NewCipher(agent.privKey[:16])
+	if err != nil {
+		return nil, nil, err
+	}
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCFBEncrypter(block, agent.aesIV(agent.engineBoots, agent.engineTime, salt)).
+		XORKeyStream(ciphertext, plaintext)
+	return ciphertext, salt, nil
+}
+
+//decrypt decrypts a scoped PDU encrypted by the client
+func (agent *snmpTestAgent) decrypt(ciphertext, salt []byte, engineBoots, engineTime int64) ([]byte, error) {
+	if len(salt) != 8 {
+		return nil, fmt.Errorf("unexpected privacy parameters of %d bytes", len(salt))
+	}
+	plaintext := make([]byte, len(ciphertext))
+	if agent.privProtocol == SNMPPrivDES {
+		if len(ciphertext)%8 != 0 {
+			return nil, errors.New("the DES ciphertext is not a multiple of the block size")
+		}
+		block, err := des.NewCipher(agent.privKey[:8])
+		if err != nil {
+			return nil, err
+		}
+		cipher.NewCBCDecrypter(block, agent.desIV(salt)).CryptBlocks(plaintext, ciphertext)
+		return plaintext, nil
+	}
+
+	block, err := aes.NewCipher(agent.privKey[:16])
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCFBDecrypter(block, agent.aesIV(engineBoots, engineTime, salt)).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+//aesIV returns the RFC 3826 initialization vector, the engine boots, engine time and salt
+func (agent *snmpTestAgent) aesIV(engineBoots, engineTime int64, salt []byte) []byte {
+	var iv [16]byte
+	binary.BigEndian.PutUint32(iv[:], uint32(engineBoots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv[:]
+}
+
+//desIV returns the RFC 3414 initialization vector, the second half of the privacy key XOR the salt
+func (agent *snmpTestAgent) desIV(salt []byte) []byte {
+	var iv [8]byte
+	for index := range iv {
+		iv[index] = agent.privKey[8+index] ^ salt[index]
+	}
+	return iv[:]
+}
+
+//snmpTestAddress returns the ipAddressIfIndex object of the supplied address
+func snmpTestAddress(address string, ifIndex int64) snmpTestObject {
+	ip := net.ParseIP(address)
+	index := []uint32{2, net.IPv6len}
+	if ip.To4() != nil {
+		ip = ip.To4()
+		index = []uint32{1, net.IPv4len}
+	}
+	for _, octet := range ip {
+		index = append(index, uint32(octet))
+	}
+	return snmpTestObject{
+		oid:   append(append([]uint32(nil), oidIPAddressIfIndex...), index...),
+		value: berEncodeInteger(ifIndex),
+	}
+}
+
+//snmpTestAdEnt returns the ipAdEntIfIndex object of the supplied IPv4 address
+func snmpTestAdEnt(address string, ifIndex int64) snmpTestObject {
+	oid := append([]uint32(nil), oidIPAdEntIfIndex...)
+	for _, octet := range net.ParseIP(address).To4() {
+		oid = append(oid, uint32(octet))
+	}
+	return snmpTestObject{oid: oid, value: berEncodeInteger(ifIndex)}
+}
+
+//snmpTestIfName returns the ifName object of the supplied interface
+func snmpTestIfName(ifIndex uint32, name string) snmpTestObject {
+	return snmpTestObject{
+		oid:   append(append([]uint32(nil), oidIfName...), ifIndex),
+		value: berEncodeOctetString([]byte(name)),
+	}
+}
+
+//checkSNMPAddresses checks the addresses returned by an SNMPSource
+func checkSNMPAddresses(t *testing.T, source SNMPSource, expectedIPv4, expectedIPv6 string) {
+	t.Helper()
+	ipv4, ipv6, err := source.GetPublicIPAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4.String() != expectedIPv4 {
+		t.Errorf("expected the IPv4 address %s, got %s", expectedIPv4, ipv4)
+	}
+	if ipv6.String() != expectedIPv6 {
+		t.Errorf("expected the IPv6 address %s, got %s", expectedIPv6, ipv6)
+	}
+}
+
+func TestSNMPSourceV2cWalksIPAddressIfIndex(t *testing.T) {
+	agent := &snmpTestAgent{
+		community: "secret",
+		mib: []snmpTestObject{
+			snmpTestIfName(1, "lo"),
+			snmpTestIfName(2, "lan"),
+			snmpTestIfName(3, "ppp0"),
+			snmpTestAddress("127.0.0.1", 1),
+			snmpTestAddress("192.168.1.1", 2),
+			snmpTestAddress("81.2.69.1", 2),
+			snmpTestAddress("81.2.69.142", 3),
+			snmpTestAddress("fe80::1", 3),
+			snmpTestAddress("2a00:1450:4009:81d::1", 2),
+			snmpTestAddress("2a00:1450:4009:81d::200e", 3),
+			snmpTestAdEnt("81.2.69.200", 3),
+		},
+	}
+	source := SNMPSource{
+		Address:   agent.start(t),
+		Community: "secret",
+		Interface: "ppp0",
+		Timeout:   2 * time.Second,
+	}
+	checkSNMPAddresses(t, source, "81.2.69.142", "2a00:1450:4009:81d::200e")
+}
+
+func TestSNMPSourceV2cFallsBackToIPAdEntIfIndex(t *testing.T) {
+	agent := &snmpTestAgent{
+		community: "public",
+		mib: []snmpTestObject{
+			snmpTestAdEnt("10.0.0.1", 2),
+			snmpTestAdEnt("81.2.69.1", 2),
+			snmpTestAdEnt("81.2.69.142", 3),
+		},
+	}
+	source := SNMPSource{
+		Address:   agent.start(t),
+		Interface: "3",
+		Timeout:   2 * time.Second,
+	}
+	checkSNMPAddresses(t, source, "81.2.69.142", "<nil>")
+}
+
+func TestSNMPLocalizeKey(t *testing.T) {
+	engineID, _ := hex.DecodeString(snmpTestEngineID)
+	tests := []struct {
+		authProtocol string
+		expected     string
+	}{
+		//RFC 3414 appendix A.3.1 and A.3.2
+		{SNMPAuthMD5, "526f5eed9fcce26f8964c2930787d82b"},
+		{SNMPAuthSHA, "6695febc9288e36282235fc7151f128497b38f3f"},
+	}
+	for _, test := range tests {
+		key, err := snmpLocalizeKey(test.authProtocol, "maplesyrup", engineID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(key) != test.expected {
+			t.Errorf("expected the %s localized key %s, got %x", test.authProtocol, test.expected, key)
+		}
+	}
+}
+
+func TestSNMPSourceV3AuthPriv(t *testing.T) {
+	tests := []struct {
+		authProtocol string
+		privProtocol string
+	}{
+		{SNMPAuthSHA, SNMPPrivAES},
+		{SNMPAuthMD5, SNMPPrivDES},
+	}
+	for _, test := range tests {
+		t.Run(test.authProtocol+"/"+test.privProtocol, func(t *testing.T) {
+			engineID, _ := hex.DecodeString(snmpTestEngineID)
+			agent := &snmpTestAgent{
+				authProtocol: test.authProtocol,
+				authPassword: "maplesyrup",
+				privProtocol: test.privProtocol,
+				privPassword: "privpassword",
+				engineID:     engineID,
+				engineBoots:  7,
+				engineTime:   1000,
+				mib: []snmpTestObject{
+					snmpTestIfName(1, "lan"),
+					snmpTestIfName(2, "wan"),
+					snmpTestAddress("192.168.1.1", 1),
+					snmpTestAddress("81.2.69.142", 2),
+					snmpTestAddress("2a00:1450:4009:81d::200e", 2),
+				},
+			}
+			source := SNMPSource{
+				Address:      agent.start(t),
+				Version:      "3",
+				Username:     "ddns",
+				AuthProtocol: test.authProtocol,
+				AuthPassword: "maplesyrup",
+				PrivProtocol: test.privProtocol,
+				PrivPassword: "privpassword",
+				Interface:    "wan",
+				Timeout:      2 * time.Second,
+			}
+			checkSNMPAddresses(t, source, "81.2.69.142", "2a00:1450:4009:81d::200e")
+			if reports := atomic.LoadInt32(&agent.timeWindowReports); reports != 0 {
+				t.Errorf("expected no usmStatsNotInTimeWindows report, got %d", reports)
+			}
+		})
+	}
+}
+
+func TestSNMPSourceV3RetriesNotInTimeWindow(t *testing.T) {
+	engineID, _ := hex.DecodeString(snmpTestEngineID)
+	agent := &snmpTestAgent{
+		authProtocol:         SNMPAuthSHA,
+		authPassword:         "maplesyrup",
+		privProtocol:         SNMPPrivAES,
+		privPassword:         "privpassword",
+		engineID:             engineID,
+		engineBoots:          1,
+		engineTime:           1000,
+		rebootAfterDiscovery: true,
+		mib: []snmpTestObject{
+			snmpTestAdEnt("81.2.69.142", 2),
+		},
+	}
+	source := SNMPSource{
+		Address:      agent.start(t),
+		Version:      "3",
+		Username:     "ddns",
+		AuthPassword: "maplesyrup",
+		PrivPassword: "privpassword",
+		Family:       FamilyIPv4,
+		Timeout:      2 * time.Second,
+	}
+	checkSNMPAddresses(t, source, "81.2.69.142", "<nil>")
+	if reports := atomic.LoadInt32(&agent.timeWindowReports); reports != 1 {
+		t.Errorf("expected a single usmStatsNotInTimeWindows report, got %d", reports)
+	}
+}
@@ -0,0 +1,261 @@
+package ipaddress
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//soapFault is a partial model of a UPnP SOAP fault response
+type soapFault struct {
+	FaultString      string `xml:"Body>Fault>faultstring"`
+	ErrorCode        string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+	ErrorDescription string `xml:"Body>Fault>detail>UPnPError>errorDescription"`
+}
+
+//soapCall calls the supplied UPnP SOAP action without arguments on the supplied control url and returns the output
+//arguments of the action response by name. When a username is supplied the request is retried with HTTP digest
+//authentication in answer to a digest challenge
+func soapCall(
+	client *http.Client,
+	controlURL string,
+	serviceType string,
+	action string,
+	username string,
+	password string) (map[string]string, error) {
+
+	envelope := fmt.Sprintf(`<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+<s:Body><u:%s xmlns:u="%s"></u:%s></s:Body>
+</s:Envelope>`, action, serviceType, action)
+
+	newRequest := func() (*http.Request, error) {
+		request, err := http.NewRequest(http.MethodPost, controlURL, strings.NewReader(envelope))
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+		request.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, serviceType, action))
+		return request, nil
+	}
+
+	statusCode, responseBytes, err := performDigestRequest(client, newRequest, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		var fault soapFault
+		if xml.Unmarshal(responseBytes, &fault) == nil && fault.FaultString != "" {
+			return nil, fmt.Errorf("%s returned http status code %d: %s %s %s",
+				action, statusCode, fault.FaultString, fault.ErrorCode, fault.ErrorDescription)
+		}
+		return nil, fmt.Errorf("%s returned http status code %d", action, statusCode)
+	}
+
+	arguments, err := parseSOAPResponse(responseBytes, action+"Response")
+	if err != nil {
+		return nil, fmt.Errorf("%s returned an unreadable response: %v", action, err)
+	}
+	return arguments, nil
+}
+
+//parseSOAPResponse returns the text of the child elements of the supplied response element by their local name
+func parseSOAPResponse(responseBytes []byte, responseElement string) (map[string]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(responseBytes))
+	arguments := make(map[string]string)
+	inResponse := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			if element.Name.Local == responseElement {
+				inResponse = true
+			} else if inResponse {
+				var value string
+				if err = decoder.DecodeElement(&value, &element); err != nil {
+					return nil, err
+				}
+				arguments[element.Name.Local] = strings.TrimSpace(value)
+			}
+		case xml.EndElement:
+			if element.Name.Local == responseElement {
+				return arguments, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no %s element was found", responseElement)
+}
+
+//performDigestRequest performs the request returned by newRequest and, when a username is supplied and the server
+//answers with a digest challenge, performs it a second time with a digest Authorization header. The status code and
+//response body of the last request are returned
+func performDigestRequest(
+	client *http.Client,
+	newRequest func() (*http.Request, error),
+	username string,
+	password string) (int, []byte, error) {
+
+	request, err := newRequest()
+	if err != nil {
+		return 0, nil, err
+	}
+	statusCode, responseBytes, challenge, err := doRequest(client, request)
+	if err != nil {
+		return 0, nil, err
+	}
+	if statusCode != http.StatusUnauthorized || username == "" {
+		return statusCode, responseBytes, nil
+	}
+	if !strings.HasPrefix(strings.ToLower(challenge), "digest ") {
+		return 0, nil, errors.New("the server did not answer with a digest authentication challenge")
+	}
+
+	request, err = newRequest()
+	if err != nil {
+		return 0, nil, err
+	}
+	authorization, err := digestAuthorization(challenge, request.Method, request.URL, username, password)
+	if err != nil {
+		return 0, nil, err
+	}
+	request.Header.Set("Authorization", authorization)
+
+	statusCode, responseBytes, _, err = doRequest(client, request)
+	if err != nil {
+		return 0, nil, err
+	}
+	if statusCode == http.StatusUnauthorized {
+		return 0, nil, fmt.Errorf("the digest authentication of user %s was rejected", username)
+	}
+	return statusCode, responseBytes, nil
+}
+
+//doRequest performs the supplied request and returns the status code, response body and WWW-Authenticate header
+func doRequest(client *http.Client, request *http.Request) (int, []byte, string, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	defer func() {
+		err := response.Body.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	responseBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	return response.StatusCode, responseBytes, response.Header.Get("WWW-Authenticate"), nil
+}
+
+//digestAuthorization returns the RFC 7616 digest Authorization header value answering the supplied challenge
+func digestAuthorization(challenge, method string, requestURL *url.URL, username, password string) (string, error) {
+	params := parseDigestChallenge(challenge[len("digest "):])
+
+	var newHash func() hash.Hash
+	algorithm := params["algorithm"]
+	switch strings.ToUpper(algorithm) {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+	digest := func(value string) string {
+		h := newHash()
+		_, _ = io.WriteString(h, value)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	cnonceBytes := make([]byte, 8)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	nc := "00000001"
+	uri := requestURL.RequestURI()
+
+	ha1 := digest(fmt.Sprintf("%s:%s:%s", username, params["realm"], password))
+	ha2 := digest(fmt.Sprintf("%s:%s", method, uri))
+
+	var response string
+	qop := ""
+	for _, option := range strings.Split(params["qop"], ",") {
+		if strings.TrimSpace(option) == "auth" {
+			qop = "auth"
+		}
+	}
+	if qop != "" {
+		response = digest(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, params["nonce"], nc, cnonce, qop, ha2))
+	} else {
+		response = digest(fmt.Sprintf("%s:%s:%s", ha1, params["nonce"], ha2))
+	}
+
+	var builder strings.Builder
+	_, _ = fmt.Fprintf(&builder, `Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		username, params["realm"], params["nonce"], uri, response)
+	if algorithm != "" {
+		_, _ = fmt.Fprintf(&builder, ", algorithm=%s", algorithm)
+	}
+	if qop != "" {
+		_, _ = fmt.Fprintf(&builder, `, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, ok := params["opaque"]; ok {
+		_, _ = fmt.Fprintf(&builder, `, opaque="%s"`, opaque)
+	}
+	return builder.String(), nil
+}
+
+//parseDigestChallenge parses the comma separated key=value parameters of a digest challenge
+func parseDigestChallenge(challenge string) map[string]string {
+	params := make(map[string]string)
+	for len(challenge) > 0 {
+		challenge = strings.TrimLeft(challenge, " ,")
+		equals := strings.Index(challenge, "=")
+		if equals < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(challenge[:equals]))
+		challenge = challenge[equals+1:]
+
+		var value string
+		if strings.HasPrefix(challenge, `"`) {
+			end := strings.Index(challenge[1:], `"`)
+			if end < 0 {
+				value, challenge = challenge[1:], ""
+			} else {
+				value, challenge = challenge[1:end+1], challenge[end+2:]
+			}
+		} else {
+			end := strings.Index(challenge, ",")
+			if end < 0 {
+				value, challenge = challenge, ""
+			} else {
+				value, challenge = challenge[:end], challenge[end+1:]
+			}
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
@@ -0,0 +1,282 @@
+package ipaddress
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// STUN message types, attributes and constants (RFC 5389)
+const (
+	stunBindingRequest       uint16 = 0x0001
+	stunBindingResponse      uint16 = 0x0101
+	stunBindingErrorResponse uint16 = 0x0111
+	stunAttrMappedAddress    uint16 = 0x0001
+	stunAttrXorMappedAddress uint16 = 0x0020
+	stunAttrErrorCode        uint16 = 0x0009
+	stunMagicCookie          uint32 = 0x2112A442
+	stunHeaderLen                   = 20
+	stunDefaultPort                 = "3478"
+)
+
+// the timeout applied to STUN IP address source requests when no Timeout is set
+const defaultSTUNSourceTimeout = 5 * time.Second
+
+// the STUN servers used when no Servers are configured
+var defaultSTUNServers = []string{"stun.l.google.com:19302", "stun.cloudflare.com:3478"}
+
+/*
+The STUNSource type that has the ability to discover the public IP address and mapped port by sending RFC 5389 STUN
+Binding Requests to STUN servers
+
+STUN docs: https://datatracker.ietf.org/doc/html/rfc5389
+
+The Binding Request is sent over IPv4 transport to discover the public IPv4 address and over IPv6 transport to discover
+the public IPv6 address. The XOR-MAPPED-ADDRESS (or MAPPED-ADDRESS from older servers) of the Binding Response holds the
+address and port observed by the server. The mapped addresses and ports are recorded as a Diagnostic.
+*/
+type STUNSource struct {
+	Servers []string      // STUN server host:port addresses, the port defaults to 3478
+	Family  string        // ipv4, ipv6 or empty to discover both address families
+	Timeout time.Duration // The timeout of each Binding Request
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider STUNSource) String() string {
+	return "STUN IP address provider"
+}
+
+// GetPublicIPAddresses sends STUN Binding Requests over IPv4 and IPv6 transport and returns the public IP addresses.
+// An error is only returned when no address could be discovered at all
+func (ipProvider STUNSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	var ipv4, ipv6 net.IP
+	var failures []string
+	if ipProvider.Family != FamilyIPv6 {
+		ip, err := ipProvider.discover(FamilyIPv4)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		ipv4 = ip
+	}
+	if ipProvider.Family != FamilyIPv4 {
+		ip, err := ipProvider.discover(FamilyIPv6)
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+		ipv6 = ip
+	}
+
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, fmt.Errorf("the %s failed: %s", ipProvider, strings.Join(failures, "; "))
+	}
+	if len(failures) > 0 {
+		log.Printf("The %s only partially succeeded: %s", ipProvider, strings.Join(failures, "; "))
+	}
+
+	ipProvider.LogIPAddresses(ipv4, ipv6)
+	return ipv4, ipv6, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider STUNSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//discover sends a Binding Request to each configured server in turn over the supplied family transport and returns the
+//first mapped address received
+func (ipProvider STUNSource) discover(family string) (net.IP, error) {
+	servers := ipProvider.Servers
+	if len(servers) == 0 {
+		servers = defaultSTUNServers
+	}
+	timeout := ipProvider.Timeout
+	if timeout <= 0 {
+		timeout = defaultSTUNSourceTimeout
+	}
+	network := "udp4"
+	if family == FamilyIPv6 {
+		network = "udp6"
+	}
+
+	var failures []string
+	for _, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), stunDefaultPort)
+		}
+		serverAddr, err := net.ResolveUDPAddr(network, server)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", server, err))
+			continue
+		}
+
+		ip, port, err := stunBindingExchange(network, serverAddr, timeout)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", server, err))
+			continue
+		}
+		if (ip.To4() != nil) != (family == FamilyIPv4) {
+			failures = append(failures, fmt.Sprintf("%s: mapped address %s is not an %s address", server, ip, family))
+			continue
+		}
+
+		RecordDiagnostic(Diagnostic{
+			Source:  "STUN",
+			Family:  family,
+			Server:  server,
+			Address: ip.String(),
+			Port:    port,
+		})
+		if family == FamilyIPv4 {
+			return ip.To4(), nil
+		}
+		return ip, nil
+	}
+	return nil, fmt.Errorf("no %s STUN Binding Response was received: %s", family, strings.Join(failures, "; "))
+}
+
+//stunBindingExchange sends a Binding Request to the supplied server and returns the mapped address and port of the
+//Binding Response
+func stunBindingExchange(network string, serverAddr *net.UDPAddr, timeout time.Duration) (net.IP, int, error) {
+	request, transactionID, err := buildSTUNBindingRequest()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	conn, err := net.DialUDP(network, nil, serverAddr)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		err := conn.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, 0, err
+	}
+	if _, err = conn.Write(request); err != nil {
+		return nil, 0, err
+	}
+
+	buffer := make([]byte, 1500)
+	for {
+		count, err := conn.Read(buffer)
+		if err != nil {
+			return nil, 0, err
+		}
+		if count < stunHeaderLen || !bytes.Equal(buffer[8:20], transactionID) {
+			//not a response to this request, keep waiting
+			continue
+		}
+		return parseSTUNBindingResponse(buffer[:count], transactionID)
+	}
+}
+
+//buildSTUNBindingRequest returns an attribute-less Binding Request and its random transaction id
+func buildSTUNBindingRequest() ([]byte, []byte, error) {
+	request := make([]byte, stunHeaderLen)
+	binary.BigEndian.PutUint16(request[0:], stunBindingRequest)
+	binary.BigEndian.PutUint16(request[2:], 0)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return nil, nil, err
+	}
+	return request, request[8:20], nil
+}
+
+//parseSTUNBindingResponse parses the supplied Binding Response and returns the mapped address and port, preferring the
+//XOR-MAPPED-ADDRESS attribute over the MAPPED-ADDRESS attribute
+func parseSTUNBindingResponse(message []byte, transactionID []byte) (net.IP, int, error) {
+	messageType := binary.BigEndian.Uint16(message[0:])
+	length := int(binary.BigEndian.Uint16(message[2:]))
+	if binary.BigEndian.Uint32(message[4:]) != stunMagicCookie {
+		return nil, 0, errors.New("the STUN response does not carry the magic cookie")
+	}
+	if stunHeaderLen+length > len(message) {
+		return nil, 0, errors.New("the STUN response is truncated")
+	}
+
+	var mappedIP, xorMappedIP net.IP
+	var mappedPort, xorMappedPort int
+	var errorReason string
+	attributes := message[stunHeaderLen : stunHeaderLen+length]
+	for len(attributes) >= 4 {
+		attrType := binary.BigEndian.Uint16(attributes[0:])
+		attrLength := int(binary.BigEndian.Uint16(attributes[2:]))
+		if 4+attrLength > len(attributes) {
+			return nil, 0, errors.New("the STUN response attribute is truncated")
+		}
+		value := attributes[4 : 4+attrLength]
+
+		switch attrType {
+		case stunAttrXorMappedAddress:
+			xorMappedIP, xorMappedPort = decodeSTUNAddress(value, transactionID, true)
+		case stunAttrMappedAddress:
+			mappedIP, mappedPort = decodeSTUNAddress(value, transactionID, false)
+		case stunAttrErrorCode:
+			if len(value) >= 4 {
+				errorReason = fmt.Sprintf("%d %s", int(value[2])*100+int(value[3]), string(value[4:]))
+			}
+		}
+
+		//attributes are padded to a multiple of 4 bytes
+		padded := (attrLength + 3) &^ 3
+		if 4+padded > len(attributes) {
+			break
+		}
+		attributes = attributes[4+padded:]
+	}
+
+	switch messageType {
+	case stunBindingResponse:
+	case stunBindingErrorResponse:
+		return nil, 0, fmt.Errorf("the STUN server returned an error response: %s", errorReason)
+	default:
+		return nil, 0, fmt.Errorf("unexpected STUN message type 0x%04x", messageType)
+	}
+
+	if xorMappedIP != nil {
+		return xorMappedIP, xorMappedPort, nil
+	}
+	if mappedIP != nil {
+		return mappedIP, mappedPort, nil
+	}
+	return nil, 0, errors.New("the STUN response holds no mapped address")
+}
+
+//decodeSTUNAddress decodes a MAPPED-ADDRESS or, when xor is set, a XOR-MAPPED-ADDRESS attribute value
+func decodeSTUNAddress(value []byte, transactionID []byte, xor bool) (net.IP, int) {
+	if len(value) < 4 {
+		return nil, 0
+	}
+	family := value[1]
+	port := binary.BigEndian.Uint16(value[2:])
+	var ip net.IP
+	switch {
+	case family == 0x01 && len(value) >= 8:
+		ip = append(net.IP{}, value[4:8]...)
+	case family == 0x02 && len(value) >= 20:
+		ip = append(net.IP{}, value[4:20]...)
+	default:
+		return nil, 0
+	}
+
+	if xor {
+		port ^= uint16(stunMagicCookie >> 16)
+		key := make([]byte, 16)
+		binary.BigEndian.PutUint32(key, stunMagicCookie)
+		copy(key[4:], transactionID)
+		for i := range ip {
+			ip[i] ^= key[i]
+		}
+	}
+	return ip, int(port)
+}
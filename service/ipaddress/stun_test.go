@@ -0,0 +1,212 @@
+package ipaddress
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+//stunTestServer is a STUN server stand-in answering each Binding Request over UDP with the responses built for its
+//transaction id
+type stunTestServer struct {
+	t         *testing.T
+	conn      net.PacketConn
+	responses func(transactionID []byte) [][]byte
+}
+
+//start starts the server on the supplied loopback address and returns its address, the server is stopped when the
+//test completes. The test is skipped when the loopback address is not available
+func (server *stunTestServer) start(t *testing.T, network, address string) *net.UDPAddr {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		t.Skipf("the %s loopback is not available: %v", network, err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	server.t = t
+	server.conn = conn
+	go server.serve()
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+//serve answers the Binding Requests until the connection is closed
+func (server *stunTestServer) serve() {
+	buffer := make([]byte, 1500)
+	for {
+		length, address, err := server.conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		request := buffer[:length]
+		if length != stunHeaderLen || binary.BigEndian.Uint16(request) != stunBindingRequest ||
+			binary.BigEndian.Uint16(request[2:]) != 0 || binary.BigEndian.Uint32(request[4:]) != stunMagicCookie {
+			server.t.Errorf("the STUN test server received a malformed Binding Request %x", request)
+			continue
+		}
+		for _, response := range server.responses(append([]byte(nil), request[8:20]...)) {
+			if _, err = server.conn.WriteTo(response, address); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//stunTestMessage returns a STUN message of the supplied type, magic cookie and transaction id
+func stunTestMessage(messageType uint16, cookie uint32, transactionID []byte, attributes ...[]byte) []byte {
+	message := make([]byte, stunHeaderLen)
+	binary.BigEndian.PutUint16(message, messageType)
+	binary.BigEndian.PutUint32(message[4:], cookie)
+	copy(message[8:], transactionID)
+	for _, attribute := range attributes {
+		message = append(message, attribute...)
+	}
+	binary.BigEndian.PutUint16(message[2:], uint16(len(message)-stunHeaderLen))
+	return message
+}
+
+//stunTestAttribute returns a STUN attribute padded to a multiple of 4 bytes
+func stunTestAttribute(attrType uint16, value []byte) []byte {
+	attribute := make([]byte, 4, 4+len(value)+3)
+	binary.BigEndian.PutUint16(attribute, attrType)
+	binary.BigEndian.PutUint16(attribute[2:], uint16(len(value)))
+	attribute = append(attribute, value...)
+	return append(attribute, make([]byte, (4-len(value)%4)%4)...)
+}
+
+//stunTestAddress returns a MAPPED-ADDRESS attribute or, when xor is set, a XOR-MAPPED-ADDRESS attribute holding the
+//supplied address and port
+func stunTestAddress(address string, port uint16, transactionID []byte, xor bool) []byte {
+	ip := net.ParseIP(address)
+	family := byte(0x02)
+	if ip.To4() != nil {
+		ip = ip.To4()
+		family = 0x01
+	}
+	value := append([]byte{0, family, byte(port >> 8), byte(port)}, ip...)
+	if !xor {
+		return stunTestAttribute(stunAttrMappedAddress, value)
+	}
+
+	//the port is xored with the most significant half of the magic cookie, the address with the magic cookie followed
+	//by the transaction id
+	key := append([]byte{0x21, 0x12, 0xa4, 0x42}, transactionID...)
+	value[2] ^= key[0]
+	value[3] ^= key[1]
+	for index := range ip {
+		value[4+index] ^= key[index]
+	}
+	return stunTestAttribute(stunAttrXorMappedAddress, value)
+}
+
+func TestSTUNBindingExchange(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses func(transactionID []byte) [][]byte
+	}{
+		{"xor mapped address", func(transactionID []byte) [][]byte {
+			return [][]byte{stunTestMessage(stunBindingResponse, stunMagicCookie, transactionID,
+				stunTestAddress("81.2.69.142", 54321, transactionID, true))}
+		}},
+		{"mapped address", func(transactionID []byte) [][]byte {
+			return [][]byte{stunTestMessage(stunBindingResponse, stunMagicCookie, transactionID,
+				stunTestAddress("81.2.69.142", 54321, transactionID, false))}
+		}},
+		{"xor mapped address preferred", func(transactionID []byte) [][]byte {
+			return [][]byte{stunTestMessage(stunBindingResponse, stunMagicCookie, transactionID,
+				stunTestAddress("192.0.2.1", 1, transactionID, false),
+				stunTestAddress("81.2.69.142", 54321, transactionID, true))}
+		}},
+		{"response to another transaction ignored", func(transactionID []byte) [][]byte {
+			other := append([]byte(nil), transactionID...)
+			other[0] ^= 0xff
+			return [][]byte{
+				stunTestMessage(stunBindingResponse, stunMagicCookie, other,
+					stunTestAddress("192.0.2.1", 1, other, true)),
+				stunTestMessage(stunBindingResponse, stunMagicCookie, transactionID,
+					stunTestAddress("81.2.69.142", 54321, transactionID, true)),
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &stunTestServer{responses: test.responses}
+			ip, port, err := stunBindingExchange("udp4", server.start(t, "udp4", "127.0.0.1:0"), 2*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ip.String() != "81.2.69.142" || port != 54321 {
+				t.Errorf("expected the mapped address 81.2.69.142:54321, got %s:%d", ip, port)
+			}
+		})
+	}
+}
+
+func TestSTUNBindingExchangeFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  string
+		responses func(transactionID []byte) [][]byte
+	}{
+		{"wrong magic cookie", "magic cookie", func(transactionID []byte) [][]byte {
+			return [][]byte{stunTestMessage(stunBindingResponse, 0, transactionID,
+				stunTestAddress("81.2.69.142", 54321, transactionID, false))}
+		}},
+		{"error response", "400 Bad Request", func(transactionID []byte) [][]byte {
+			return [][]byte{stunTestMessage(stunBindingErrorResponse, stunMagicCookie, transactionID,
+				stunTestAttribute(stunAttrErrorCode, append([]byte{0, 0, 4, 0}, "Bad Request"...)))}
+		}},
+		{"no mapped address", "no mapped address", func(transactionID []byte) [][]byte {
+			return [][]byte{stunTestMessage(stunBindingResponse, stunMagicCookie, transactionID)}
+		}},
+		{"no response to this transaction", "timeout", func(transactionID []byte) [][]byte {
+			other := append([]byte(nil), transactionID...)
+			other[11] ^= 0xff
+			return [][]byte{stunTestMessage(stunBindingResponse, stunMagicCookie, other,
+				stunTestAddress("81.2.69.142", 54321, other, true))}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &stunTestServer{responses: test.responses}
+			_, _, err := stunBindingExchange("udp4", server.start(t, "udp4", "127.0.0.1:0"), 500*time.Millisecond)
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected an error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestSTUNSourceOverIPv6(t *testing.T) {
+	server := &stunTestServer{responses: func(transactionID []byte) [][]byte {
+		return [][]byte{stunTestMessage(stunBindingResponse, stunMagicCookie, transactionID,
+			stunTestAddress("2a00:1450:4009:81d::200e", 54321, transactionID, true))}
+	}}
+	address := server.start(t, "udp6", "[::1]:0")
+	source := STUNSource{
+		Servers: []string{fmt.Sprintf("[::1]:%d", address.Port)},
+		Family:  FamilyIPv6,
+		Timeout: 2 * time.Second,
+	}
+	ipv4, ipv6, err := source.GetPublicIPAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4 != nil || ipv6.String() != "2a00:1450:4009:81d::200e" {
+		t.Errorf("expected only the IPv6 address 2a00:1450:4009:81d::200e, got %s and %s", ipv4, ipv6)
+	}
+
+	for _, diagnostic := range GetDiagnostics() {
+		if diagnostic.Source == "STUN" && diagnostic.Family == FamilyIPv6 {
+			if diagnostic.Address != ipv6.String() || diagnostic.Port != 54321 {
+				t.Errorf("expected the mapped address diagnostic [%s]:54321, got [%s]:%d", ipv6, diagnostic.Address,
+					diagnostic.Port)
+			}
+			return
+		}
+	}
+	t.Error("expected a STUN IPv6 diagnostic")
+}
@@ -0,0 +1,243 @@
+package ipaddress
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the SSDP multicast address M-SEARCH requests are sent to when no SearchAddress is set
+const defaultSSDPAddress = "239.255.255.250:1900"
+
+// the timeout applied to UPnP IP address source discovery and requests when no Timeout is set
+const defaultUPnPSourceTimeout = 5 * time.Second
+
+// the SSDP search targets of the UPnP Internet Gateway Device and its WAN connection services
+var ssdpSearchTargets = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+/*
+The UPnPSource type that has the ability to talk to a UPnP Internet Gateway Device (IGD) on the LAN to retrieve the
+public IPv4 address
+
+UPnP IGD docs: https://openconnectivity.org/developer/specifications/upnp-resources/upnp/internet-gateway-device-igd-v-2-0/
+
+The IGD is discovered with an SSDP M-SEARCH request, its device description is retrieved from the LOCATION url of the
+SSDP response and GetExternalIPAddress is called on the WANIPConnection or WANPPPConnection service.
+
+sample SOAP response:
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">
+            <NewExternalIPAddress>255.255.255.255</NewExternalIPAddress>
+        </u:GetExternalIPAddressResponse>
+    </s:Body>
+</s:Envelope>
+*/
+type UPnPSource struct {
+	SearchAddress string        // The SSDP host:port address, defaults to the 239.255.255.250:1900 multicast address
+	Location      string        // An optional device description url that skips SSDP discovery
+	Timeout       time.Duration // The discovery and request timeout
+}
+
+//upnpDevice is a partial model of a UPnP device description device element
+type upnpDevice struct {
+	DeviceType string        `xml:"deviceType"`
+	Services   []upnpService `xml:"serviceList>service"`
+	Devices    []upnpDevice  `xml:"deviceList>device"`
+}
+
+//upnpService is a partial model of a UPnP device description service element
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+//upnpRoot is a partial model of a UPnP device description document
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+//String implements the Stringer interface to return the name of this IAddressProvider
+func (ipProvider UPnPSource) String() string {
+	return "UPnP IGD IP address provider"
+}
+
+// GetPublicIPAddresses discovers the Internet Gateway Device and returns the public IPv4 address reported by its
+// GetExternalIPAddress action
+func (ipProvider UPnPSource) GetPublicIPAddresses() (net.IP, net.IP, error) {
+	timeout := ipProvider.Timeout
+	if timeout <= 0 {
+		timeout = defaultUPnPSourceTimeout
+	}
+
+	location := ipProvider.Location
+	if location == "" {
+		var err error
+		if location, err = ipProvider.discover(timeout); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	client := &http.Client{Timeout: timeout}
+	controlURL, serviceType, err := getWANConnectionService(client, location)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the %s failed to read %s: %v", ipProvider, location, err)
+	}
+	ipv4, err := getExternalIPAddress(client, controlURL, serviceType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("the %s failed to call %s: %v", ipProvider, controlURL, err)
+	}
+
+	ipProvider.LogIPAddresses(ipv4, nil)
+	return ipv4, nil, nil
+}
+
+// LogIPAddresses logs the public IP addresses
+func (ipProvider UPnPSource) LogIPAddresses(ipv4, ipv6 net.IP) {
+	log.Printf("The %s reports the public IPv4 as %s and the public IPv6 as %s", ipProvider, ipv4, ipv6)
+}
+
+//discover sends SSDP M-SEARCH requests and returns the LOCATION url of the first response received within the supplied
+//timeout
+func (ipProvider UPnPSource) discover(timeout time.Duration) (string, error) {
+	searchAddress := ipProvider.SearchAddress
+	if searchAddress == "" {
+		searchAddress = defaultSSDPAddress
+	}
+	destination, err := net.ResolveUDPAddr("udp4", searchAddress)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		err := conn.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	for _, searchTarget := range ssdpSearchTargets {
+		request := fmt.Sprintf("M-SEARCH * HTTP/1.1\r\nHOST: %s\r\nMAN: \"ssdp:discover\"\r\nMX: 2\r\nST: %s\r\n\r\n",
+			searchAddress, searchTarget)
+		if _, err = conn.WriteToUDP([]byte(request), destination); err != nil {
+			return "", err
+		}
+	}
+
+	if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+
+	buffer := make([]byte, 2048)
+	for {
+		count, _, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return "", fmt.Errorf("no UPnP Internet Gateway Device answered the SSDP search within %s", timeout)
+			}
+			return "", err
+		}
+
+		response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buffer[:count])), nil)
+		if err != nil {
+			continue
+		}
+		//the first gateway response is good enough, gateways answer every search target
+		if location := response.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+//getWANConnectionService retrieves the device description at the supplied location and returns the absolute control
+//url and service type of its WANIPConnection or WANPPPConnection service
+func getWANConnectionService(client *http.Client, location string) (string, string, error) {
+	response, err := client.Get(location)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		err := response.Body.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("the device description GET returned http status code %d", response.StatusCode)
+	}
+
+	var root upnpRoot
+	if err = xml.NewDecoder(response.Body).Decode(&root); err != nil {
+		return "", "", err
+	}
+
+	service := findWANConnectionService(&root.Device)
+	if service == nil {
+		return "", "", errors.New("the device has no WANIPConnection or WANPPPConnection service")
+	}
+
+	base := location
+	if root.URLBase != "" {
+		base = root.URLBase
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", "", err
+	}
+	controlURL, err := baseURL.Parse(strings.TrimSpace(service.ControlURL))
+	if err != nil {
+		return "", "", err
+	}
+	return controlURL.String(), strings.TrimSpace(service.ServiceType), nil
+}
+
+//findWANConnectionService searches the supplied device and its embedded devices for a WAN connection service
+func findWANConnectionService(device *upnpDevice) *upnpService {
+	for index, service := range device.Services {
+		if strings.Contains(service.ServiceType, ":WANIPConnection:") ||
+			strings.Contains(service.ServiceType, ":WANPPPConnection:") {
+			return &device.Services[index]
+		}
+	}
+	for index := range device.Devices {
+		if service := findWANConnectionService(&device.Devices[index]); service != nil {
+			return service
+		}
+	}
+	return nil
+}
+
+//getExternalIPAddress calls the GetExternalIPAddress SOAP action on the supplied control url and returns the address
+func getExternalIPAddress(client *http.Client, controlURL string, serviceType string) (net.IP, error) {
+	arguments, err := soapCall(client, controlURL, serviceType, "GetExternalIPAddress", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	ipv4 := net.ParseIP(arguments["NewExternalIPAddress"]).To4()
+	if ipv4 == nil {
+		return nil, fmt.Errorf("GetExternalIPAddress returned '%s' which is not an IPv4 address",
+			arguments["NewExternalIPAddress"])
+	}
+	return ipv4, nil
+}
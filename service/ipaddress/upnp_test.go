@@ -0,0 +1,240 @@
+package ipaddress
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the device description of the fake IGD, the WAN connection service is embedded in the WANConnectionDevice
+const upnpTestDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>%s
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:Layer3Forwarding:1</serviceType>
+        <controlURL>/ctl/L3F</controlURL>
+      </service>
+    </serviceList>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <serviceList>
+          <service>
+            <serviceType>urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1</serviceType>
+            <controlURL>/ctl/CmnIfCfg</controlURL>
+          </service>
+        </serviceList>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>%s</serviceType>
+                <controlURL> %s </controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+// the GetExternalIPAddress response of the fake IGD
+const upnpTestResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetExternalIPAddressResponse xmlns:u="%s">
+      <NewExternalIPAddress>81.2.69.142</NewExternalIPAddress>
+    </u:GetExternalIPAddressResponse>
+  </s:Body>
+</s:Envelope>`
+
+// the SOAP fault of a fake IGD without a connected WAN
+const upnpTestFault = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <s:Fault>
+      <faultcode>s:Client</faultcode>
+      <faultstring>UPnPError</faultstring>
+      <detail>
+        <UPnPError xmlns="urn:schemas-upnp-org:control-1-0">
+          <errorCode>501</errorCode>
+          <errorDescription>Action Failed</errorDescription>
+        </UPnPError>
+      </detail>
+    </s:Fault>
+  </s:Body>
+</s:Envelope>`
+
+//upnpTestIGD is a fake Internet Gateway Device serving its device description at /rootDesc.xml and answering
+//GetExternalIPAddress on the control url of its WAN connection service
+type upnpTestIGD struct {
+	serviceType string
+	controlPath string
+	urlBase     bool
+	fault       bool
+}
+
+//start starts the fake IGD and returns the url of its device description, the IGD is stopped when the test completes
+func (igd *upnpTestIGD) start(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rootDesc.xml":
+			urlBase := ""
+			if igd.urlBase {
+				urlBase = fmt.Sprintf("\n  <URLBase>http://%s/</URLBase>", r.Host)
+			}
+			w.Header().Set("Content-Type", "text/xml")
+			_, _ = fmt.Fprintf(w, upnpTestDescription, urlBase, igd.serviceType, igd.controlPath)
+		case r.Method == http.MethodPost && r.URL.Path == igd.controlPath:
+			if err := igd.checkAction(r); err != nil {
+				t.Error(err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+			if igd.fault {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = io.WriteString(w, upnpTestFault)
+				return
+			}
+			_, _ = fmt.Fprintf(w, upnpTestResponse, igd.serviceType)
+		default:
+			t.Errorf("unexpected fake IGD request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/rootDesc.xml"
+}
+
+//checkAction checks the SOAPAction header and envelope of a GetExternalIPAddress request
+func (igd *upnpTestIGD) checkAction(r *http.Request) error {
+	if soapAction := r.Header.Get("SOAPAction"); soapAction != `"`+igd.serviceType+`#GetExternalIPAddress"` {
+		return fmt.Errorf("unexpected SOAPAction %s", soapAction)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(body, []byte(`<u:GetExternalIPAddress xmlns:u="`+igd.serviceType+`">`)) {
+		return fmt.Errorf("unexpected envelope %s", body)
+	}
+	return nil
+}
+
+//startSSDPResponder starts an SSDP responder stand-in on 127.0.0.1 answering the InternetGatewayDevice:1 M-SEARCH
+//with the supplied location, preceded by a datagram that is not an HTTP response. The responder address is returned
+func startSSDPResponder(t *testing.T, location string) string {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	go func() {
+		buffer := make([]byte, 2048)
+		for {
+			count, address, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buffer[:count])))
+			if err != nil || request.Method != "M-SEARCH" || request.RequestURI != "*" {
+				t.Errorf("the SSDP responder received a malformed M-SEARCH %q", buffer[:count])
+				continue
+			}
+			if man := request.Header.Get("MAN"); man != `"ssdp:discover"` {
+				t.Errorf("unexpected M-SEARCH MAN header %s", man)
+			}
+			if request.Header.Get("ST") != ssdpSearchTargets[0] {
+				continue
+			}
+			responses := []string{
+				"NOTIFY garbage",
+				"HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nEXT:\r\nLOCATION: " + location +
+					"\r\nSERVER: Linux/5.10 UPnP/1.0 MiniUPnPd/2.2\r\nST: " + ssdpSearchTargets[0] + "\r\n\r\n",
+			}
+			for _, response := range responses {
+				if _, err = conn.WriteTo([]byte(response), address); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUPnPSourceWANConnectionServices(t *testing.T) {
+	tests := []struct {
+		name string
+		igd  upnpTestIGD
+	}{
+		{"WANIPConnection", upnpTestIGD{
+			serviceType: "urn:schemas-upnp-org:service:WANIPConnection:1",
+			controlPath: "/ctl/IPConn",
+		}},
+		{"WANPPPConnection", upnpTestIGD{
+			serviceType: "urn:schemas-upnp-org:service:WANPPPConnection:1",
+			controlPath: "/ctl/PPPConn",
+		}},
+		{"URLBase", upnpTestIGD{
+			serviceType: "urn:schemas-upnp-org:service:WANIPConnection:2",
+			controlPath: "/upnp/control/WANIPConn1",
+			urlBase:     true,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := UPnPSource{Location: test.igd.start(t), Timeout: 2 * time.Second}
+			ipv4, ipv6, err := source.GetPublicIPAddresses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ipv4.String() != "81.2.69.142" || ipv6 != nil {
+				t.Errorf("expected only the IPv4 address 81.2.69.142, got %s and %s", ipv4, ipv6)
+			}
+		})
+	}
+}
+
+func TestUPnPSourceDiscoversOverSSDP(t *testing.T) {
+	igd := &upnpTestIGD{
+		serviceType: "urn:schemas-upnp-org:service:WANIPConnection:1",
+		controlPath: "/ctl/IPConn",
+	}
+	source := UPnPSource{SearchAddress: startSSDPResponder(t, igd.start(t)), Timeout: 2 * time.Second}
+	ipv4, _, err := source.GetPublicIPAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipv4.String() != "81.2.69.142" {
+		t.Errorf("expected the IPv4 address 81.2.69.142, got %s", ipv4)
+	}
+}
+
+func TestUPnPSourceReportsSOAPFault(t *testing.T) {
+	igd := &upnpTestIGD{
+		serviceType: "urn:schemas-upnp-org:service:WANIPConnection:1",
+		controlPath: "/ctl/IPConn",
+		fault:       true,
+	}
+	source := UPnPSource{Location: igd.start(t), Timeout: 2 * time.Second}
+	_, _, err := source.GetPublicIPAddresses()
+	if err == nil || !strings.Contains(err.Error(), "501 Action Failed") {
+		t.Errorf("expected the UPnPError 501 to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"github.com/bebo-dot-dev/go-ddns-client/service/ipaddress"
+	"github.com/bebo-dot-dev/go-ddns-client/service/notifications"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the IP address source types that report the address observed by an external echo service
+var echoSourceTypes = map[string]bool{"Default": true, "HTTP": true, "DNS": true, "STUN": true}
+
+//natWarning describes a detected carrier grade or double NAT, served by the /json endpoint
+type natWarning struct {
+	RouterAddress   string `json:"routerAddress"`
+	ExternalAddress string `json:"externalAddress,omitempty"`
+	Detail          string `json:"detail"`
+	Timestamp       string `json:"timestamp"`
+}
+
+//natStatus holds the current NAT warning, nil when no carrier grade or double NAT is detected
+var natStatus = struct {
+	mu      sync.Mutex
+	warning *natWarning
+}{}
+
+//getNATWarning returns the current NAT warning, nil when there is none
+func getNATWarning() *natWarning {
+	natStatus.mu.Lock()
+	defer natStatus.mu.Unlock()
+	return natStatus.warning
+}
+
+//detectNAT compares the IPv4 address reported by the configured source chain, typically the WAN address of a router,
+//with the address an external echo service observes. A router address in 100.64.0.0/10 or an address that differs
+//from the externally observed address means the host is behind carrier grade or double NAT. The warning is logged,
+//served by the /json endpoint and notified once, the returned IPv4 address is the one to publish. A source chain made of
+//echo services only already reports the externally observed address, no further lookup is made
+func detectNAT(cfg *config.Configuration, ipv4 net.IP) net.IP {
+	detection := cfg.NATDetection
+	if detection == nil || !detection.Enabled || ipv4 == nil {
+		return ipv4
+	}
+
+	var external net.IP
+	var err error
+	if isEchoSourceChain(cfg) {
+		//the source chain already asked an echo service, its address is the externally observed address
+		external = ipv4
+	} else if external, err = lookupExternalIPv4(cfg, detection); err != nil {
+		log.Printf("NAT detection could not determine the externally observed IPv4 address: %v", err)
+	}
+
+	isCarrierGradeNAT := ipaddress.IsCarrierGradeNATAddress(ipv4)
+	if external == nil {
+		//without the externally observed address NAT is not ruled out, the current warning of the WAN address stands
+		//rather than flapping with the availability of the echo source
+		current := getNATWarning()
+		if !isCarrierGradeNAT || (current != nil && current.RouterAddress == formatAddress(ipv4)) {
+			return ipv4
+		}
+	}
+
+	var details []string
+	if isCarrierGradeNAT {
+		details = append(details, fmt.Sprintf("the WAN address %s is in the carrier grade NAT range 100.64.0.0/10", ipv4))
+	}
+	if external != nil && !external.Equal(ipv4) {
+		details = append(details, fmt.Sprintf(
+			"the WAN address %s differs from the externally observed address %s", ipv4, external))
+	}
+
+	if len(details) == 0 {
+		if setNATWarning(nil) {
+			log.Printf("The WAN address %s matches the externally observed address, NAT is no longer detected", ipv4)
+		}
+		return ipv4
+	}
+
+	warning := &natWarning{
+		RouterAddress:   formatAddress(ipv4),
+		ExternalAddress: formatAddress(external),
+		Detail:          "the host is behind carrier grade or double NAT, " + strings.Join(details, " and "),
+		Timestamp:       time.Now().Format(time.RFC3339),
+	}
+	log.Printf("Warning: %s", warning.Detail)
+	if setNATWarning(warning) {
+		event := &notifications.Event{
+			Type:   notifications.EventNATDetected,
+			IPv4:   formatAddress(ipv4),
+			Reason: warning.Detail,
+		}
+		if err = sendNotifications(cfg, event); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if detection.Publish == config.NATPublishExternal && external != nil {
+		log.Printf("Publishing the externally observed IPv4 address %s rather than the WAN address %s", external, ipv4)
+		return external
+	}
+	return ipv4
+}
+
+//lookupExternalIPv4 returns the IPv4 address observed by the configured echo source or the ipify-v4 HTTP preset
+func lookupExternalIPv4(cfg *config.Configuration, detection *config.NATDetection) (net.IP, error) {
+	echoSource := detection.EchoSource
+	if echoSource == nil {
+		echoSource = &config.IPSource{SourceType: "HTTP", Preset: "ipify-v4"}
+	}
+	provider, err := getIpSourceProvider(echoSource, &cfg.Router)
+	if err != nil {
+		return nil, err
+	}
+	external, _, err := provider.GetPublicIPAddresses()
+	if err != nil {
+		return nil, err
+	}
+	if external == nil {
+		return nil, fmt.Errorf("the %s reported no IPv4 address", provider)
+	}
+	return external, nil
+}
+
+//isEchoSourceChain returns an indicator that describes if every source of the IPv4 source chain is an external echo
+//service, in which case the IPv4 address the chain reports already is the externally observed address
+func isEchoSourceChain(cfg *config.Configuration) bool {
+	ipSources := cfg.IPSources
+	if cfg.IPv4 != nil && cfg.IPv4.IPSources != nil && len(cfg.IPv4.IPSources.Sources) > 0 {
+		ipSources = cfg.IPv4.IPSources
+	}
+	if ipSources == nil || len(ipSources.Sources) == 0 {
+		//without IP sources the router section is queried, or the Default ipify source when no router is configured
+		return cfg.Router.RouterType == ""
+	}
+	for _, source := range ipSources.Sources {
+		if !echoSourceTypes[source.SourceType] {
+			return false
+		}
+	}
+	return true
+}
+
+//setNATWarning replaces the current NAT warning and returns an indicator that describes if the detail changed, so a
+//warning that persists across runs is only notified once
+func setNATWarning(warning *natWarning) bool {
+	natStatus.mu.Lock()
+	defer natStatus.mu.Unlock()
+
+	previous := ""
+	if natStatus.warning != nil {
+		previous = natStatus.warning.Detail
+	}
+	current := ""
+	if warning != nil {
+		current = warning.Detail
+	}
+	natStatus.warning = warning
+	return previous != current
+}
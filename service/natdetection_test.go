@@ -0,0 +1,110 @@
+package service
+
+import (
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+//startEchoTestServer starts a fake echo service answering with the supplied plain text IPv4 address, or with an http
+//error when the address is empty, and returns the echo source of it with a counter of the requests it served
+func startEchoTestServer(t *testing.T, address string) (*config.IPSource, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if address == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, address+"\n")
+	}))
+	t.Cleanup(server.Close)
+	return &config.IPSource{SourceType: "HTTP", Url: server.URL, Family: "ipv4"}, &requests
+}
+
+func TestDetectNAT(t *testing.T) {
+	routerChain := func(cfg *config.Configuration) {
+		cfg.Router.RouterType = "FritzBox"
+	}
+	tests := []struct {
+		name      string
+		sources   func(cfg *config.Configuration)
+		publish   string
+		wan       string
+		echo      string
+		published string
+		requests  int32
+		warning   string
+	}{
+		{"router address matches the echo service", routerChain, "", "81.2.69.142", "81.2.69.142", "81.2.69.142", 1,
+			""},
+		{"double NAT", routerChain, "", "81.2.69.160", "81.2.69.142", "81.2.69.160", 1,
+			"the WAN address 81.2.69.160 differs from the externally observed address 81.2.69.142"},
+		{"carrier grade NAT published as the external address", routerChain, config.NATPublishExternal,
+			"100.64.12.34", "81.2.69.142", "81.2.69.142", 1,
+			"the WAN address 100.64.12.34 is in the carrier grade NAT range 100.64.0.0/10 and the WAN address " +
+				"100.64.12.34 differs from the externally observed address 81.2.69.142"},
+		{"carrier grade NAT with the echo service unavailable", routerChain, config.NATPublishExternal,
+			"100.64.12.34", "", "100.64.12.34", 1,
+			"the WAN address 100.64.12.34 is in the carrier grade NAT range 100.64.0.0/10"},
+		{"router address with the echo service unavailable", routerChain, "", "81.2.69.160", "", "81.2.69.160", 1, ""},
+		{"echo source chain is not looked up again", func(cfg *config.Configuration) {
+			routerChain(cfg)
+			cfg.IPSources = &config.IPSources{Sources: []config.IPSource{
+				{SourceType: "HTTP", Preset: "ipify-v4"},
+				{SourceType: "STUN"},
+			}}
+		}, config.NATPublishExternal, "81.2.69.160", "81.2.69.142", "81.2.69.160", 0, ""},
+		{"echo IPv4 family chain is not looked up again", func(cfg *config.Configuration) {
+			routerChain(cfg)
+			cfg.IPv4 = &config.AddressFamily{IPSources: &config.IPSources{Sources: []config.IPSource{
+				{SourceType: "DNS", Preset: "opendns"},
+			}}}
+		}, "", "81.2.69.160", "81.2.69.142", "81.2.69.160", 0, ""},
+		{"default source is not looked up again", func(cfg *config.Configuration) {}, "", "81.2.69.160",
+			"81.2.69.142", "81.2.69.160", 0, ""},
+		{"chain with a router source is looked up", func(cfg *config.Configuration) {
+			cfg.IPSources = &config.IPSources{Sources: []config.IPSource{
+				{SourceType: "HTTP", Preset: "ipify-v4"},
+				{SourceType: "Router", Router: &config.RouterConfiguration{RouterType: "OpenWrt"}},
+			}}
+		}, "", "81.2.69.160", "81.2.69.142", "81.2.69.160", 1,
+			"the WAN address 81.2.69.160 differs from the externally observed address 81.2.69.142"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setNATWarning(nil)
+			t.Cleanup(func() {
+				setNATWarning(nil)
+			})
+			echoSource, requests := startEchoTestServer(t, test.echo)
+			cfg := &config.Configuration{
+				NATDetection: &config.NATDetection{Enabled: true, EchoSource: echoSource, Publish: test.publish},
+			}
+			test.sources(cfg)
+
+			published := detectNAT(cfg, net.ParseIP(test.wan).To4())
+			if published.String() != test.published {
+				t.Errorf("expected the published address %s, got %s", test.published, published)
+			}
+			if *requests != test.requests {
+				t.Errorf("expected %d echo service requests, got %d", test.requests, *requests)
+			}
+			warning := getNATWarning()
+			if test.warning == "" {
+				if warning != nil {
+					t.Errorf("expected no NAT warning, got %s", warning.Detail)
+				}
+				return
+			}
+			if warning == nil || !strings.HasSuffix(warning.Detail, test.warning) {
+				t.Errorf("expected the NAT warning %q, got %+v", test.warning, warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"time"
+)
+
+// the quiet period that ends a burst of address change events when no debounce is configured
+const defaultAddressEventsDebounce = 5 * time.Second
+
+//WatchAddressEvents subscribes to the address and route change events of the operating system when addressEvents are
+//enabled and returns a channel that receives a value once a burst of changes on a watched interface has settled for
+//the debounce period, such as the address and route changes of a PPPoE reconnect. The returned channel is nil, and
+//so never receives, when address events are disabled. Address events are only supported on linux and the
+//addressEvents section is read once at startup
+func WatchAddressEvents(cfg *config.Configuration) <-chan struct{} {
+	events := cfg.AddressEvents
+	if events == nil || !events.Enabled {
+		return nil
+	}
+	debounce := defaultAddressEventsDebounce
+	if events.Debounce != "" {
+		var err error
+		if debounce, err = time.ParseDuration(events.Debounce); err != nil {
+			log.Printf("Address change events are disabled, invalid addressEvents debounce: %v", err)
+			return nil
+		}
+	}
+
+	changes := make(chan string, 64)
+	triggers := make(chan struct{}, 1)
+	go func() {
+		err := readAddressEvents(changes)
+		log.Printf("Address change events stopped, relying on the update interval alone: %v", err)
+	}()
+	go debounceAddressEvents(changes, triggers, events.Interfaces, debounce)
+	return triggers
+}
+
+//debounceAddressEvents receives the names of changed interfaces and sends a trigger once the changes on the watched
+//interfaces have been quiet for the debounce period. A pending trigger is never queued twice
+func debounceAddressEvents(changes <-chan string, triggers chan<- struct{}, interfaces []string, debounce time.Duration) {
+	var settled <-chan time.Time
+	for {
+		select {
+		case name := <-changes:
+			if !isWatchedInterface(interfaces, name) {
+				continue
+			}
+			if settled == nil {
+				log.Printf("An address or route change was detected on interface %s", name)
+			}
+			settled = time.After(debounce)
+		case <-settled:
+			settled = nil
+			select {
+			case triggers <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+//isWatchedInterface returns an indicator that describes if changes on the named interface trigger an update, all
+//interfaces are watched when none are configured and an interface that no longer exists has an empty name
+func isWatchedInterface(interfaces []string, name string) bool {
+	if len(interfaces) == 0 || name == "" {
+		return true
+	}
+	for _, watched := range interfaces {
+		if watched == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// the rtnetlink multicast groups of linux/rtnetlink.h, not exported by the syscall package
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6IfAddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+)
+
+//readAddressEvents subscribes to the rtnetlink IPv4 and IPv6 address and route groups and sends the name of the
+//interface of each relevant change on changes until the netlink socket fails. Relevant changes are RTM_NEWADDR and
+//RTM_DELADDR of global scope addresses and RTM_NEWROUTE of default routes in the main routing table
+func readAddressEvents(changes chan<- string) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("the netlink socket could not be opened: %v", err)
+	}
+	defer func() {
+		_ = syscall.Close(fd)
+	}()
+
+	address := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr | rtmgrpIPv4Route | rtmgrpIPv6Route,
+	}
+	if err = syscall.Bind(fd, address); err != nil {
+		return fmt.Errorf("the netlink socket could not be bound: %v", err)
+	}
+
+	buffer := make([]byte, 65536)
+	for {
+		length, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.ENOBUFS {
+			//events were dropped during a burst, treat it as a change of an unknown interface
+			changes <- ""
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("the netlink socket could not be read: %v", err)
+		}
+
+		messages, err := syscall.ParseNetlinkMessage(buffer[:length])
+		if err != nil {
+			return fmt.Errorf("invalid netlink message: %v", err)
+		}
+		for index := range messages {
+			if interfaceIndex, ok := getChangedInterfaceIndex(&messages[index]); ok {
+				changes <- getInterfaceName(interfaceIndex)
+			}
+		}
+	}
+}
+
+//getChangedInterfaceIndex returns the index of the interface of a relevant address or route change message
+func getChangedInterfaceIndex(message *syscall.NetlinkMessage) (uint32, bool) {
+	switch message.Header.Type {
+	case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+		if len(message.Data) < syscall.SizeofIfAddrmsg {
+			return 0, false
+		}
+		ifAddress := (*syscall.IfAddrmsg)(unsafe.Pointer(&message.Data[0]))
+		//link-local and host scope addresses come and go without affecting the public addresses
+		return ifAddress.Index, ifAddress.Scope == syscall.RT_SCOPE_UNIVERSE
+	case syscall.RTM_NEWROUTE:
+		if len(message.Data) < syscall.SizeofRtMsg {
+			return 0, false
+		}
+		route := (*syscall.RtMsg)(unsafe.Pointer(&message.Data[0]))
+		if route.Dst_len != 0 || route.Table != syscall.RT_TABLE_MAIN {
+			return 0, false
+		}
+		attributes, err := syscall.ParseNetlinkRouteAttr(message)
+		if err != nil {
+			return 0, false
+		}
+		for _, attribute := range attributes {
+			if attribute.Attr.Type == syscall.RTA_OIF && len(attribute.Value) >= 4 {
+				return *(*uint32)(unsafe.Pointer(&attribute.Value[0])), true
+			}
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+//getInterfaceName returns the name of the interface with the supplied index, an empty name when it no longer exists
+func getInterfaceName(index uint32) string {
+	if index == 0 {
+		return ""
+	}
+	netInterface, err := net.InterfaceByIndex(int(index))
+	if err != nil {
+		return ""
+	}
+	return netInterface.Name
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package service
+
+import "errors"
+
+//readAddressEvents is only implemented on linux, where rtnetlink delivers address and route change events
+func readAddressEvents(changes chan<- string) error {
+	return errors.New("address change events are only supported on linux")
+}
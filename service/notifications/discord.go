@@ -0,0 +1,95 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+)
+
+// the Discord embed colours of the event severities
+const (
+	discordColorInfo    = 0x2eb67d
+	discordColorWarning = 0xecb22e
+	discordColorError   = 0xe01e5a
+)
+
+// the maximum number of fields of a Discord embed
+const discordMaxEmbedFields = 25
+
+//DiscordNotifier implements the Discord channel webhook sender
+/*
+discord docs:
+	https://discord.com/developers/docs/resources/webhook#execute-webhook
+	https://discord.com/developers/docs/resources/channel#embed-object
+
+json payload:
+	{
+		"embeds": [
+			{
+				"title": "go ddns client ip address update",
+				"description": "The IP addresses for domain 'example.com' were updated by host",
+				"color": 3061373,
+				"fields": [
+					{"name": "IPv4", "value": "255.255.255.254 → 255.255.255.255", "inline": true},
+					{"name": "example.com", "value": "✅ DuckDNS updated", "inline": false}
+				],
+				"timestamp": "2021-01-01T00:00:00Z"
+			}
+		]
+	}
+*/
+type DiscordNotifier struct {
+	conf *config.Discord
+}
+
+//Send sends the Discord notification
+func (notifier DiscordNotifier) Send(event *Event) error {
+	var fields []map[string]interface{}
+	for _, change := range event.AddressChanges() {
+		fields = append(fields, map[string]interface{}{"name": change.Family, "value": change.String(), "inline": true})
+	}
+	for _, result := range event.Services {
+		if len(fields) == discordMaxEmbedFields {
+			break
+		}
+		icon := "✅"
+		if !result.Success {
+			icon = "❌"
+		}
+		fields = append(fields, map[string]interface{}{
+			"name":   result.Domain,
+			"value":  fmt.Sprintf("%s %s %s", icon, result.ServiceType, event.ServiceStatus(result)),
+			"inline": false,
+		})
+	}
+
+	color := discordColorInfo
+	switch event.Severity() {
+	case SeverityWarning:
+		color = discordColorWarning
+	case SeverityError:
+		color = discordColorError
+	}
+
+	embed := map[string]interface{}{
+		"title":       event.Subject(),
+		"description": event.Description(),
+		"color":       color,
+		"fields":      fields,
+	}
+	if !event.Timestamp.IsZero() {
+		embed["timestamp"] = event.Timestamp
+	}
+	payload := map[string]interface{}{"embeds": []interface{}{embed}}
+	if notifier.conf.Username != "" {
+		payload["username"] = notifier.conf.Username
+	}
+
+	if _, err := sendJSON(http.MethodPost, notifier.conf.WebhookUrl, payload, nil); err != nil {
+		return fmt.Errorf("discord error: %v", err)
+	}
+
+	log.Println("Discord notification sent")
+	return nil
+}
@@ -1,34 +1,75 @@
 package notifications
 
 import (
+	"bytes"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/bebo-dot-dev/go-ddns-client/service/config"
 	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net"
 	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"os"
 	"strings"
+	"time"
 )
 
-//EmailNotifier implements a simple email sender
+// the email security types
+const (
+	emailSecuritySSL         = "SSL"         // implicit TLS, typically port 465
+	emailSecurityTLS         = "TLS"         // STARTTLS is required, typically port 587
+	emailSecurityOptionalTLS = "OptionalTLS" // STARTTLS when the server offers it, plain otherwise
+	emailSecurityNone        = "None"        // plain SMTP, typically a local relay on port 25
+)
+
+// the time allowed for the whole exchange with the SMTP server
+const emailTimeout = 30 * time.Second
+
+/*
+EmailNotifier implements an email sender. The message is a RFC 5322 / RFC 2045 multipart/alternative message with a
+quoted-printable UTF-8 plain text part and html part, RFC 2047 encoded headers and Date and Message-ID headers.
+
+Server certificates are verified against the system roots or the configured caFile unless insecureSkipVerify is set.
+PLAIN, LOGIN and XOAUTH2 credentials are only sent over TLS or to localhost, no authentication is performed when no
+username is configured.
+*/
 type EmailNotifier struct {
 	conf *config.Email
 }
 
 //Send sends the email notification
-func (notifier EmailNotifier) Send(hostname string, domainCount int, domainsStr, ipv4, ipv6 string) error {
-	host, _, _ := net.SplitHostPort(notifier.conf.SmtpServer)
-	auth := smtp.PlainAuth("", notifier.conf.Username, notifier.conf.Password, host)
+func (notifier EmailNotifier) Send(event *Event) error {
+	host, _, err := net.SplitHostPort(notifier.conf.SmtpServer)
+	if err != nil {
+		return notifier.emailError(err)
+	}
+	auth, err := notifier.getAuth(host)
+	if err != nil {
+		return notifier.emailError(err)
+	}
 
 	client, err := notifier.getSmtpClient(host)
 	if err != nil {
 		return notifier.emailError(err)
 	}
+	defer func() {
+		//after a successful Quit the connection is already closed
+		_ = client.Close()
+	}()
 
 	// Auth
-	if err := client.Auth(auth); err != nil {
-		return notifier.emailError(err)
+	if auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return notifier.emailError(err)
+		}
 	}
 
 	from, recipients, err := notifier.getAddresses(client)
@@ -36,17 +77,17 @@ func (notifier EmailNotifier) Send(hostname string, domainCount int, domainsStr,
 		return notifier.emailError(err)
 	}
 
-	// Data
-	w, err := client.Data()
+	emailMsg, err := notifier.buildMessage(from, *recipients, event)
 	if err != nil {
 		return notifier.emailError(err)
 	}
 
-	emailMsg, err := notifier.buildMessage(from, *recipients, hostname, domainCount, domainsStr, ipv4, ipv6)
+	// Data
+	w, err := client.Data()
 	if err != nil {
 		return notifier.emailError(err)
 	}
-	_, err = w.Write([]byte(emailMsg))
+	_, err = w.Write(emailMsg)
 	if err != nil {
 		return notifier.emailError(err)
 	}
@@ -63,42 +104,100 @@ func (notifier EmailNotifier) Send(hostname string, domainCount int, domainsStr,
 	return err
 }
 
-//getSmtpClient returns an smtp.Client setup according to the configured notifier.conf.SecurityType (SSL or TLS)
+//getAuth returns the smtp.Auth of the configured notifier.conf.AuthType, nil when no username is configured
+func (notifier EmailNotifier) getAuth(host string) (smtp.Auth, error) {
+	if notifier.conf.Username == "" {
+		return nil, nil
+	}
+	switch strings.ToUpper(notifier.conf.AuthType) {
+	case "", "PLAIN":
+		return smtp.PlainAuth("", notifier.conf.Username, notifier.conf.Password, host), nil
+	case "LOGIN":
+		return &loginAuth{username: notifier.conf.Username, password: notifier.conf.Password, host: host}, nil
+	case "CRAM-MD5":
+		return smtp.CRAMMD5Auth(notifier.conf.Username, notifier.conf.Password), nil
+	case "XOAUTH2":
+		return &xoauth2Auth{username: notifier.conf.Username, token: notifier.conf.Password}, nil
+	default:
+		return nil, fmt.Errorf("unsupported email auth type %s", notifier.conf.AuthType)
+	}
+}
+
+//getSmtpClient returns an smtp.Client setup according to the configured notifier.conf.SecurityType (SSL, TLS,
+//OptionalTLS or None)
 func (notifier EmailNotifier) getSmtpClient(host string) (*smtp.Client, error) {
-	var client *smtp.Client
-	var err error
+	securityType := notifier.conf.SecurityType
+	switch securityType {
+	case emailSecuritySSL, emailSecurityTLS, emailSecurityOptionalTLS, emailSecurityNone:
+	default:
+		return nil, fmt.Errorf("unsupported email security type %s", securityType)
+	}
 
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: true,
-		ServerName:         host,
+	tlsConfig, err := notifier.getTLSConfig(host)
+	if err != nil {
+		return nil, err
 	}
 
-	switch notifier.conf.SecurityType {
-	case "SSL":
-		conn, err := tls.Dial("tcp", notifier.conf.SmtpServer, tlsConfig)
-		if err != nil {
-			break
-		}
+	dialer := &net.Dialer{Timeout: emailTimeout}
+	var conn net.Conn
+	if securityType == emailSecuritySSL {
+		conn, err = tls.DialWithDialer(dialer, "tcp", notifier.conf.SmtpServer, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", notifier.conf.SmtpServer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
 
-		client, err = smtp.NewClient(conn, host)
-		if err != nil {
-			break
-		}
-	case "TLS":
-		client, err = smtp.Dial(notifier.conf.SmtpServer)
-		if err != nil {
-			break
-		}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if securityType == emailSecuritySSL || securityType == emailSecurityNone {
+		return client, nil
+	}
 
-		err := client.StartTLS(tlsConfig)
-		if err != nil {
-			break
+	if ok, _ := client.Extension("STARTTLS"); !ok {
+		if securityType == emailSecurityTLS {
+			_ = client.Close()
+			return nil, errors.New("the SMTP server does not support STARTTLS")
 		}
-	default:
-		log.Panic("unsupported email security type " + notifier.conf.SecurityType)
+		log.Printf("The SMTP server %s does not support STARTTLS, the email is sent unencrypted", host)
+		return client, nil
 	}
+	if err = client.StartTLS(tlsConfig); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return client, nil
+}
 
-	return client, err
+//getTLSConfig returns the tls.Config of the SMTP server, the certificate is verified against the system roots or the
+//CA certificates of the configured notifier.conf.CAFile
+func (notifier EmailNotifier) getTLSConfig(host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: notifier.conf.InsecureSkipVerify,
+		ServerName:         host,
+	}
+	if notifier.conf.CAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(notifier.conf.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no CA certificates were found in %s", notifier.conf.CAFile)
+	}
+	tlsConfig.RootCAs = roots
+	return tlsConfig, nil
 }
 
 //getAddresses constructs email addresses and validates them against the supplied smtp.Client
@@ -120,61 +219,79 @@ func (notifier EmailNotifier) getAddresses(client *smtp.Client) (*mail.Address,
 	return &from, &recipients, nil
 }
 
-//buildMessage builds the email message to be sent
+//buildMessage builds the multipart/alternative email message to be sent, the headers are written in a fixed order
 func (notifier EmailNotifier) buildMessage(
 	from *mail.Address,
 	recipients []mail.Address,
-	hostname string,
-	domainCount int,
-	domainsStr,
-	ipv4,
-	ipv6 string) (string, error) {
-
-	plural := ""
-	if domainCount > 1 {
-		plural = "s"
-	}
-
-	subject := "go ddns client ip address update"
-	body := fmt.Sprintf("The IP addresses for domain%s '%s' were updated to:\n%s\n%s\nby: %s",
-		plural, domainsStr, ipv4, ipv6, hostname)
-
-	var rb strings.Builder
-	for index, recipient := range recipients {
-		if recipient.Name != "" {
-			_, err := fmt.Fprintf(&rb, "\"%s\" ", recipient.Name)
-			if err != nil {
-				return "", nil
-			}
-		}
+	event *Event) ([]byte, error) {
 
-		_, err := fmt.Fprintf(&rb, "<%s>", recipient.Address)
-		if err != nil {
-			return "", nil
-		}
-		if index < (len(recipients) - 1) {
-			_, err = fmt.Fprint(&rb, ", ")
-			if err != nil {
-				return "", nil
-			}
-		}
+	var to []string
+	for _, recipient := range recipients {
+		to = append(to, recipient.String())
+	}
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, err
 	}
 
-	headers := make(map[string]string)
-	headers["From"] = from.String()
-	headers["To"] = rb.String()
-	headers["Subject"] = subject
-
-	//construct the message
-	message := ""
-	for k, v := range headers {
-		message += fmt.Sprintf("%s: %s\r\n", k, v)
+	var message bytes.Buffer
+	body := multipart.NewWriter(&message)
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", event.Subject())},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": body.Boundary()})},
+	}
+	for _, header := range headers {
+		_, _ = fmt.Fprintf(&message, "%s: %s\r\n", header[0], header[1])
 	}
-	message += "\r\n" + body
+	message.WriteString("\r\n")
 
-	return message, nil
+	//the preferred html alternative comes last
+	if err = writeTextPart(body, "text/plain", event.Message()); err != nil {
+		return nil, err
+	}
+	if err = writeTextPart(body, "text/html", event.HTML()); err != nil {
+		return nil, err
+	}
+	if err = body.Close(); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
 }
 
 func (notifier EmailNotifier) emailError(err error) error {
 	return fmt.Errorf("email notifier error: %v", err)
 }
+
+//writeTextPart writes a quoted-printable UTF-8 part of the supplied media type to the multipart body
+func writeTextPart(body *multipart.Writer, mediaType string, text string) error {
+	part, err := body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mediaType + "; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	encoder := quotedprintable.NewWriter(part)
+	if _, err = encoder.Write([]byte(text)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+//newMessageID returns a unique Message-ID in the domain of the sender address
+func newMessageID(fromAddress string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if index := strings.LastIndex(fromAddress, "@"); index >= 0 && index < len(fromAddress)-1 {
+		domain = fromAddress[index+1:]
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain), nil
+}
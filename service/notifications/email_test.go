@@ -0,0 +1,281 @@
+package notifications
+
+import (
+	"bytes"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the email message of the fr ip_changed test event with the date, message id and multipart boundary replaced by
+// placeholders, lines end in CRLF once the placeholders are replaced
+const emailTestGolden = `From: "go ddns client" <ddns@example.com>
+To: =?utf-8?q?J=C3=BCrgen_M=C3=BCller?= <juergen@example.com>, <ops@example.com>
+Subject: =?utf-8?q?go_ddns_client_mise_=C3=A0_jour_de_l'adresse_IP?=
+Date: DATE
+Message-ID: MESSAGE-ID
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary=BOUNDARY
+
+--BOUNDARY
+Content-Transfer-Encoding: quoted-printable
+Content-Type: text/plain; charset=UTF-8
+
+Les adresses IP pour le domaine 'home.example.com' ont =C3=A9t=C3=A9 mises =
+=C3=A0 jour vers :
+81.2.69.142
+2a00:1450:4009:81d::200e
+par : host
+--BOUNDARY
+Content-Transfer-Encoding: quoted-printable
+Content-Type: text/html; charset=UTF-8
+
+<!DOCTYPE html>
+<html>
+<body>
+<p>Les adresses IP pour le domaine &#39;home.example.com&#39; ont =C3=A9t=
+=C3=A9 mises =C3=A0 jour vers :<br>
+81.2.69.142<br>
+2a00:1450:4009:81d::200e<br>
+par : host</p>
+</body>
+</html>
+
+--BOUNDARY--
+`
+
+var emailTestMessageID = regexp.MustCompile(`^<[0-9]+\.[0-9a-f]{32}@example\.com>$`)
+
+//buildTestMessage builds the email message of the supplied event from the go ddns client to two recipients
+func buildTestMessage(t *testing.T, event *Event) []byte {
+	message, err := EmailNotifier{conf: &config.Email{}}.buildMessage(
+		&mail.Address{Name: "go ddns client", Address: "ddns@example.com"},
+		[]mail.Address{{Name: "Jürgen Müller", Address: "juergen@example.com"}, {Address: "ops@example.com"}},
+		event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestBuildMessageGolden(t *testing.T) {
+	message := buildTestMessage(t, templateTestEvent("fr"))
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date, err := mail.ParseDate(parsed.Header.Get("Date"))
+	if err != nil || time.Since(date) > time.Minute {
+		t.Errorf("expected the current RFC 1123 date, got %s: %v", parsed.Header.Get("Date"), err)
+	}
+	messageID := parsed.Header.Get("Message-ID")
+	if !emailTestMessageID.MatchString(messageID) {
+		t.Errorf("expected a unique Message-ID in the example.com domain, got %s", messageID)
+	}
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	normalized := strings.NewReplacer(
+		parsed.Header.Get("Date"), "DATE",
+		messageID, "MESSAGE-ID",
+		params["boundary"], "BOUNDARY",
+	).Replace(string(message))
+	if expected := strings.ReplaceAll(emailTestGolden, "\n", "\r\n"); normalized != expected {
+		t.Errorf("expected the message\n%s\ngot\n%s", expected, normalized)
+	}
+}
+
+func TestBuildMessageParts(t *testing.T) {
+	event := templateTestEvent("de")
+	message := buildTestMessage(t, event)
+	parsed, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []string
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			break
+		}
+		decoded, err := ioutil.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part.Header.Get("Content-Type")+"\n"+strings.ReplaceAll(string(decoded), "\r\n", "\n"))
+	}
+	expected := []string{"text/plain; charset=UTF-8\n" + event.Message(), "text/html; charset=UTF-8\n" + event.HTML()}
+	if len(parts) != 2 || parts[0] != expected[0] || parts[1] != expected[1] {
+		t.Errorf("expected the plain text and html alternatives %q, got %q", expected, parts)
+	}
+}
+
+func TestBuildMessageSubject(t *testing.T) {
+	tests := []struct {
+		subject  string
+		expected string
+	}{
+		{"go ddns client ip address update", "go ddns client ip address update"},
+		{"go ddns client actualización de la dirección IP",
+			"=?utf-8?q?go_ddns_client_actualizaci=C3=B3n_de_la_direcci=C3=B3n_IP?="},
+		{"a_b=c?d é", "=?utf-8?q?a=5Fb=3Dc=3Fd_=C3=A9?="},
+		{"go ddns client IP-Adressaktualisierung für home.example.com, büro.example.com und ferienhaus.example.com",
+			"=?utf-8?q?go_ddns_client_IP-Adressaktualisierung_f=C3=BCr_home.example.co?= " +
+				"=?utf-8?q?m,_b=C3=BCro.example.com_und_ferienhaus.example.com?="},
+	}
+	for _, test := range tests {
+		event := templateTestEvent("")
+		event.templates = []map[string]config.MessageTemplate{{defaultTemplateKey: {Subject: test.subject}}}
+		parsed, err := mail.ReadMessage(bytes.NewReader(buildTestMessage(t, event)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := parsed.Header.Get("Subject")
+		if header != test.expected {
+			t.Errorf("expected the subject header %s, got %s", test.expected, header)
+		}
+		if decoded, err := new(mime.WordDecoder).DecodeHeader(header); err != nil || decoded != test.subject {
+			t.Errorf("expected the subject header to decode to %q, got %q: %v", test.subject, decoded, err)
+		}
+	}
+}
+
+func TestLoginAuth(t *testing.T) {
+	auth := &loginAuth{username: "ddns@example.com", password: "secret-password", host: "smtp.example.com"}
+	tests := []struct {
+		server *smtp.ServerInfo
+		err    string
+	}{
+		{&smtp.ServerInfo{Name: "smtp.example.com", TLS: true}, ""},
+		{&smtp.ServerInfo{Name: "smtp.example.com"}, "unencrypted connection"},
+		{&smtp.ServerInfo{Name: "smtp.example.org", TLS: true}, "wrong host name"},
+	}
+	for _, test := range tests {
+		mechanism, response, err := auth.Start(test.server)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected the error %q starting with %+v, got %v", test.err, test.server, err)
+			}
+			continue
+		}
+		if mechanism != "LOGIN" || response != nil || err != nil {
+			t.Errorf("expected the LOGIN mechanism without an initial response, got %s %q %v", mechanism, response, err)
+		}
+	}
+
+	exchange := []struct {
+		challenge string
+		more      bool
+		response  string
+		err       string
+	}{
+		{"Username:", true, "ddns@example.com", ""},
+		{"Password:", true, "secret-password", ""},
+		{"USER NAME", true, "ddns@example.com", ""},
+		{" password ", true, "secret-password", ""},
+		{"Token:", true, "", "unexpected LOGIN challenge Token:"},
+		{"", false, "", ""},
+	}
+	for _, step := range exchange {
+		response, err := auth.Next([]byte(step.challenge), step.more)
+		if step.err != "" {
+			if err == nil || err.Error() != step.err {
+				t.Errorf("expected the error %q answering %q, got %v", step.err, step.challenge, err)
+			}
+			continue
+		}
+		if err != nil || string(response) != step.response {
+			t.Errorf("expected the response %q to %q, got %q %v", step.response, step.challenge, response, err)
+		}
+	}
+}
+
+func TestCRAMMD5Auth(t *testing.T) {
+	//the exchange of RFC 2195 section 2
+	auth, err := EmailNotifier{conf: &config.Email{Username: "tim", Password: "tanstaaftanstaaf", AuthType: "cram-md5"}}.
+		getAuth("smtp.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mechanism, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	if mechanism != "CRAM-MD5" || err != nil {
+		t.Fatalf("expected the CRAM-MD5 mechanism, got %s %v", mechanism, err)
+	}
+	response, err := auth.Next([]byte("<1896.697170952@postoffice.reston.mci.net>"), true)
+	if err != nil || string(response) != "tim b913a602c7eda7a495b4e6e7334d3890" {
+		t.Errorf("expected the response tim b913a602c7eda7a495b4e6e7334d3890, got %q %v", response, err)
+	}
+}
+
+func TestXOAUTH2Auth(t *testing.T) {
+	auth := &xoauth2Auth{username: "ddns@example.com", token: "ya29.a0AfH6SMC"}
+	if _, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.gmail.com"}); err == nil {
+		t.Error("expected the token not to be sent over an unencrypted connection")
+	}
+	for _, server := range []*smtp.ServerInfo{{Name: "smtp.gmail.com", TLS: true}, {Name: "localhost"}} {
+		mechanism, response, err := auth.Start(server)
+		expected := "user=ddns@example.com\x01auth=Bearer ya29.a0AfH6SMC\x01\x01"
+		if mechanism != "XOAUTH2" || string(response) != expected || err != nil {
+			t.Errorf("expected the XOAUTH2 initial response %q, got %s %q %v", expected, mechanism, response, err)
+		}
+	}
+
+	rejection := `{"status":"401","schemes":"bearer","scope":"https://mail.google.com/"}`
+	if _, err := auth.Next([]byte(rejection), true); err == nil ||
+		err.Error() != "XOAUTH2 authentication failed: "+rejection {
+		t.Errorf("expected the rejected token to fail with the server error description, got %v", err)
+	}
+	if response, err := auth.Next(nil, false); response != nil || err != nil {
+		t.Errorf("expected the accepted token to end the exchange, got %q %v", response, err)
+	}
+}
+
+func TestGetAuth(t *testing.T) {
+	tests := []struct {
+		username  string
+		authType  string
+		mechanism string
+		err       string
+	}{
+		{"", "LOGIN", "", ""},
+		{"ddns@example.com", "", "PLAIN", ""},
+		{"ddns@example.com", "plain", "PLAIN", ""},
+		{"ddns@example.com", "Login", "LOGIN", ""},
+		{"ddns@example.com", "CRAM-MD5", "CRAM-MD5", ""},
+		{"ddns@example.com", "xoauth2", "XOAUTH2", ""},
+		{"ddns@example.com", "NTLM", "", "unsupported email auth type NTLM"},
+	}
+	for _, test := range tests {
+		conf := &config.Email{Username: test.username, Password: "secret-password", AuthType: test.authType}
+		auth, err := EmailNotifier{conf: conf}.getAuth("smtp.example.com")
+		if test.err != "" || test.mechanism == "" {
+			if auth != nil || (test.err != "" && (err == nil || err.Error() != test.err)) {
+				t.Errorf("expected no auth and the error %q of %s %s, got %v %v", test.err, test.username,
+					test.authType, auth, err)
+			}
+			continue
+		}
+		mechanism, _, err := auth.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true, Auth: []string{"PLAIN"}})
+		if err != nil || mechanism != test.mechanism {
+			t.Errorf("expected the %s mechanism of the auth type %q, got %s %v", test.mechanism, test.authType,
+				mechanism, err)
+		}
+	}
+}
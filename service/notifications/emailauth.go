@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+//loginAuth implements the LOGIN authentication mechanism. Like smtp.PlainAuth it only sends the credentials over TLS
+//or to localhost
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+//Start begins the LOGIN authentication with the server
+func (auth *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkAuthConnection(server); err != nil {
+		return "", nil, err
+	}
+	if server.Name != auth.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+//Next answers the username and password challenges of the server
+func (auth *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	challenge := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(challenge, "user"):
+		return []byte(auth.username), nil
+	case strings.HasPrefix(challenge, "pass"):
+		return []byte(auth.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %s", fromServer)
+	}
+}
+
+//xoauth2Auth implements the XOAUTH2 authentication mechanism of Gmail and Microsoft 365 with an OAuth2 access token.
+//The token is only sent over TLS or to localhost
+type xoauth2Auth struct {
+	username string
+	token    string
+}
+
+//Start sends the username and bearer token to the server
+func (auth *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkAuthConnection(server); err != nil {
+		return "", nil, err
+	}
+	return "XOAUTH2", []byte("user=" + auth.username + "\x01auth=Bearer " + auth.token + "\x01\x01"), nil
+}
+
+//Next fails with the json error description the server sends when it rejects the token
+func (auth *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, fmt.Errorf("XOAUTH2 authentication failed: %s", fromServer)
+	}
+	return nil, nil
+}
+
+//checkAuthConnection returns an error when the credentials would be sent over an unencrypted connection to a remote
+//server
+func checkAuthConnection(server *smtp.ServerInfo) error {
+	if server.TLS {
+		return nil
+	}
+	switch server.Name {
+	case "localhost", "127.0.0.1", "::1":
+		return nil
+	default:
+		return errors.New("unencrypted connection")
+	}
+}
@@ -0,0 +1,190 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"strings"
+	"time"
+)
+
+// the notification event types, the values notifier events subscriptions refer to
+const (
+	EventIPChanged        = "ip_changed"        // the public IP addresses changed and the DDNS services were updated
+	EventChangeSuppressed = "change_suppressed" // a detected IP address change was not published
+	EventNATDetected      = "nat_detected"      // the host is behind carrier grade or double NAT
+	EventUpdateFailed     = "update_failed"     // DDNS service updates failed for the failure threshold of runs
+	EventUpdateRecovered  = "update_recovered"  // a notified failure recovered
+	EventIPLookupFailed   = "ip_lookup_failed"  // the public IP addresses could not be determined
+	EventConfigReloaded   = "config_reloaded"   // the configuration file was changed and reloaded
+	EventStartup          = "startup"           // the client started
+)
+
+// the notification event severities, mapped to the colours and priorities of the notification services
+const (
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+//Event describes something notifiers are told about
+type Event struct {
+	Type        string          `json:"type"`
+	Hostname    string          `json:"hostname"`
+	DomainCount int             `json:"domainCount"`
+	Domains     string          `json:"domains"`
+	IPv4        string          `json:"ipv4,omitempty"`
+	IPv6        string          `json:"ipv6,omitempty"`
+	OldIPv4     string          `json:"oldIPv4,omitempty"`
+	OldIPv6     string          `json:"oldIPv6,omitempty"`
+	Reason      string          `json:"reason,omitempty"`
+	Failures    int             `json:"failures,omitempty"` // The consecutive failed runs of a failure or recovery
+	Services    []ServiceResult `json:"services,omitempty"` // The per service results of an update
+	Timestamp   time.Time       `json:"timestamp"`
+
+	locale    string                              //the locale of the built-in messages
+	templates []map[string]config.MessageTemplate //the configured templates of the notifier and of all notifiers
+}
+
+//ServiceResult describes the outcome of the update of a single DDNS service
+type ServiceResult struct {
+	ServiceType string    `json:"serviceType"`
+	Domain      string    `json:"domain"`
+	IPv4        string    `json:"ipv4,omitempty"`
+	IPv6        string    `json:"ipv6,omitempty"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+//AddressChange describes the old and new address of an address family
+type AddressChange struct {
+	Family string
+	Old    string
+	New    string
+}
+
+//String returns the change as old → new, or only the new address when it is unchanged or there is no old address
+func (change AddressChange) String() string {
+	if change.Old == "" || change.Old == change.New {
+		return change.New
+	}
+	return change.Old + " → " + change.New
+}
+
+//Status returns the outcome of the service update as updated or failed with the error
+func (result ServiceResult) Status() string {
+	if result.Success {
+		return "updated"
+	}
+	return "failed: " + result.Error
+}
+
+//AddressChanges returns the old and new address of each address family the event carries a new address for
+func (event *Event) AddressChanges() []AddressChange {
+	var changes []AddressChange
+	if event.IPv4 != "" {
+		changes = append(changes, AddressChange{Family: "IPv4", Old: event.OldIPv4, New: event.IPv4})
+	}
+	if event.IPv6 != "" {
+		changes = append(changes, AddressChange{Family: "IPv6", Old: event.OldIPv6, New: event.IPv6})
+	}
+	return changes
+}
+
+//Severity returns the severity of the event, an error when any service update failed
+func (event *Event) Severity() string {
+	for _, result := range event.Services {
+		if !result.Success {
+			return SeverityError
+		}
+	}
+	switch event.Type {
+	case EventUpdateFailed, EventIPLookupFailed:
+		return SeverityError
+	case EventChangeSuppressed, EventNATDetected:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+//Subject returns the short subject line of the event, the configured subject template or the built-in subject
+func (event *Event) Subject() string {
+	return event.render("subject", func(messageTemplate config.MessageTemplate) string {
+		return messageTemplate.Subject
+	}, func(messages *localeMessages) map[string]string {
+		return messages.subjects
+	})
+}
+
+//Message returns the plain text message of the event, the configured body template or the built-in message
+func (event *Event) Message() string {
+	return event.render("body", selectBody, func(messages *localeMessages) map[string]string {
+		return messages.messages
+	})
+}
+
+//Summary returns the plain text body of notifiers that show the address changes and service results, the configured
+//body template or the description followed by the details
+func (event *Event) Summary() string {
+	return event.render("body", selectBody, func(messages *localeMessages) map[string]string {
+		return nil
+	})
+}
+
+//HTML returns the html body of the event, the configured html template or the built-in html body that shows the plain
+//text message. A failing configured template is logged and the built-in html body is used instead
+func (event *Event) HTML() string {
+	key, text := event.getTemplate(func(messageTemplate config.MessageTemplate) string {
+		return messageTemplate.Html
+	})
+	if text != "" {
+		rendered, err := executeHTMLTemplate("html", text, event)
+		if err == nil {
+			return rendered
+		}
+		log.Printf("the %s html template failed, the built-in html is used: %v", key, err)
+	}
+
+	rendered, err := executeHTMLTemplate("html", defaultHTMLMessage, event)
+	if err != nil {
+		log.Printf("the built-in %s html template failed: %v", event.Type, err)
+	}
+	return rendered
+}
+
+//Description returns the configured body template or a single line description of the event without its addresses, for
+//notifiers that show the addresses and service results separately
+func (event *Event) Description() string {
+	return event.render("description", selectBody, func(messages *localeMessages) map[string]string {
+		return messages.descriptions
+	})
+}
+
+//ServiceStatus returns the outcome of the service update as updated or failed with the error in the locale of the event
+func (event *Event) ServiceStatus(result ServiceResult) string {
+	messages := getLocaleMessages(event.locale)
+	if result.Success {
+		return messages.updated
+	}
+	return messages.failed + ": " + result.Error
+}
+
+//Details returns the address changes and service results of the event one per line, for plain text notifiers that
+//follow the Description with the details
+func (event *Event) Details() string {
+	var lines []string
+	for _, change := range event.AddressChanges() {
+		lines = append(lines, fmt.Sprintf("%s: %s", change.Family, change))
+	}
+	for _, result := range event.Services {
+		lines = append(lines, fmt.Sprintf("%s (%s): %s", result.Domain, result.ServiceType, event.ServiceStatus(result)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+//selectBody selects the body of a message template
+func selectBody(messageTemplate config.MessageTemplate) string {
+	return messageTemplate.Body
+}
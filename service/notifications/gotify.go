@@ -0,0 +1,66 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+)
+
+//GotifyNotifier implements the Gotify message sender for a self-hosted Gotify server. The event severity maps to the
+//priorities 5, 7 and 9 unless a fixed priority is configured, priorities of 8 and above pop up on Android clients
+/*
+gotify docs:
+	https://gotify.net/api-docs#/message/createMessage
+
+request url:
+	https://gotify.example.com/message
+
+json payload:
+	{
+		"title": "go ddns client ip address update",
+		"message": "The IP addresses for domain 'example.com' were updated by host...",
+		"priority": 5
+	}
+*/
+type GotifyNotifier struct {
+	conf *config.Gotify
+}
+
+//Send sends the Gotify notification
+func (notifier GotifyNotifier) Send(event *Event) error {
+	if notifier.conf.BaseUrl == "" || notifier.conf.Token == "" {
+		return notifier.gotifyError(errors.New("a baseUrl and token are required"))
+	}
+
+	priority := 5
+	switch event.Severity() {
+	case SeverityWarning:
+		priority = 7
+	case SeverityError:
+		priority = 9
+	}
+	if notifier.conf.Priority != 0 {
+		priority = notifier.conf.Priority
+	}
+
+	payload := map[string]interface{}{
+		"title":    event.Subject(),
+		"message":  event.Summary(),
+		"priority": priority,
+	}
+	headers := map[string]string{"X-Gotify-Key": notifier.conf.Token}
+
+	url := getBaseUrl(notifier.conf.BaseUrl, "") + "/message"
+	if _, err := sendJSON(http.MethodPost, url, payload, headers); err != nil {
+		return notifier.gotifyError(err)
+	}
+
+	log.Println("Gotify notification sent")
+	return nil
+}
+
+func (notifier GotifyNotifier) gotifyError(err error) error {
+	return fmt.Errorf("gotify error: %v", err)
+}
@@ -1,59 +1,187 @@
 package notifications
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/bebo-dot-dev/go-ddns-client/service/config"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
 //INotificationManager describes the interface the notifications.Manager
 type INotificationManager interface {
 	GetNotifierCount() int
-	Send(hostname string, domainCount int, domainsStr, ipv4, ipv6 string) error
+	Send(event *Event) error
 }
 
 //INotification describes the interface of a type able to send a notification
 type INotification interface {
-	Send(hostname string, domainCount int, domainsStr, ipv4, ipv6 string) error
+	Send(event *Event) error
+}
+
+//Subscription is a notifier and the event types it is sent
+type Subscription struct {
+	Notifier  INotification
+	Events    []string                          // The subscribed event types, all event types when empty
+	Templates map[string]config.MessageTemplate // The message templates of the notifier keyed by event type or default
+}
+
+//IsSubscribed returns an indicator that describes if the notifier is sent events of the supplied type
+func (subscription *Subscription) IsSubscribed(eventType string) bool {
+	if len(subscription.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range subscription.Events {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
 }
 
 //Manager wraps types that have the ability to send a notification
 type Manager struct {
-	Notifiers []INotification
+	Subscriptions []Subscription
+	Locale        string                            // The locale of the built-in messages
+	Templates     map[string]config.MessageTemplate // The message templates of all notifiers
 }
 
 //GetManager returns the notification manager
 func GetManager(conf *config.Notifications) INotificationManager {
-	var notifiers []INotification
+	var subscriptions []Subscription
+	subscribe := func(notifier INotification, events []string, templates map[string]config.MessageTemplate) {
+		subscriptions = append(subscriptions, Subscription{Notifier: notifier, Events: events, Templates: templates})
+	}
 
 	if conf.SipgateSMS.Enabled {
-		notifiers = append(notifiers, &SipGateSmsNotifier{conf: &conf.SipgateSMS})
+		subscribe(&SipGateSmsNotifier{conf: &conf.SipgateSMS}, conf.SipgateSMS.Events, conf.SipgateSMS.Templates)
 	}
 	if conf.Email.IsEnabled {
-		notifiers = append(notifiers, &EmailNotifier{conf: &conf.Email})
+		subscribe(&EmailNotifier{conf: &conf.Email}, conf.Email.Events, conf.Email.Templates)
+	}
+	if conf.Slack.Enabled {
+		subscribe(&SlackNotifier{conf: &conf.Slack}, conf.Slack.Events, conf.Slack.Templates)
+	}
+	if conf.Discord.Enabled {
+		subscribe(&DiscordNotifier{conf: &conf.Discord}, conf.Discord.Events, conf.Discord.Templates)
+	}
+	if conf.Teams.Enabled {
+		subscribe(&TeamsNotifier{conf: &conf.Teams}, conf.Teams.Events, conf.Teams.Templates)
+	}
+	if conf.Mattermost.Enabled {
+		subscribe(&MattermostNotifier{conf: &conf.Mattermost}, conf.Mattermost.Events, conf.Mattermost.Templates)
+	}
+	if conf.Telegram.Enabled {
+		subscribe(&TelegramNotifier{conf: &conf.Telegram}, conf.Telegram.Events, conf.Telegram.Templates)
+	}
+	if conf.Ntfy.Enabled {
+		subscribe(&NtfyNotifier{conf: &conf.Ntfy}, conf.Ntfy.Events, conf.Ntfy.Templates)
+	}
+	if conf.Gotify.Enabled {
+		subscribe(&GotifyNotifier{conf: &conf.Gotify}, conf.Gotify.Events, conf.Gotify.Templates)
+	}
+	if conf.Pushover.Enabled {
+		subscribe(&PushoverNotifier{conf: &conf.Pushover}, conf.Pushover.Events, conf.Pushover.Templates)
+	}
+	if conf.Matrix.Enabled {
+		subscribe(&MatrixNotifier{conf: &conf.Matrix}, conf.Matrix.Events, conf.Matrix.Templates)
+	}
+	if conf.MQTT.Enabled {
+		subscribe(&MQTTNotifier{conf: &conf.MQTT}, conf.MQTT.Events, conf.MQTT.Templates)
+	}
+	for index := range conf.Webhooks {
+		if conf.Webhooks[index].Enabled {
+			subscribe(&WebhookNotifier{conf: &conf.Webhooks[index]}, conf.Webhooks[index].Events,
+				conf.Webhooks[index].Templates)
+		}
 	}
 
 	return &Manager{
-		Notifiers: notifiers,
+		Subscriptions: subscriptions,
+		Locale:        conf.Locale,
+		Templates:     conf.Templates,
 	}
 }
 
 func (manager *Manager) GetNotifierCount() int {
-	return len(manager.Notifiers)
+	return len(manager.Subscriptions)
 }
 
-//Send sends one or more notifications
-func (manager *Manager) Send(hostname string, domainCount int, domainsStr string, ipv4, ipv6 string) error {
-	for _, notifier := range manager.Notifiers {
-		if err := notifier.Send(hostname, domainCount, domainsStr, ipv4, ipv6); err != nil {
-			return err
+//Send sends the event to all notifiers subscribed to its type. Each notifier is sent a copy of the event that renders
+//its messages with the templates of the notifier. A failing notifier does not stop the event from being sent to the
+//other notifiers, the errors of all failing notifiers are returned together
+func (manager *Manager) Send(event *Event) error {
+	var errs []string
+	for index := range manager.Subscriptions {
+		subscription := &manager.Subscriptions[index]
+		if !subscription.IsSubscribed(event.Type) {
+			continue
 		}
+		notifierEvent := *event
+		notifierEvent.locale = manager.Locale
+		notifierEvent.templates = nil
+		for _, templates := range []map[string]config.MessageTemplate{subscription.Templates, manager.Templates} {
+			if len(templates) > 0 {
+				notifierEvent.templates = append(notifierEvent.templates, templates)
+			}
+		}
+		if err := subscription.Notifier.Send(&notifierEvent); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
 	}
 	return nil
 }
 
+//formatAddressLines returns the non empty supplied addresses one per line
+func formatAddressLines(addresses ...string) string {
+	var lines []string
+	for _, address := range addresses {
+		if address != "" {
+			lines = append(lines, address)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+//getBaseUrl returns the configured base url of a notification service without a trailing slash, or the default url
+//of the public service when none is configured
+func getBaseUrl(baseUrl string, defaultUrl string) string {
+	if baseUrl == "" {
+		return defaultUrl
+	}
+	return strings.TrimRight(baseUrl, "/")
+}
+
+//sendJSON sends the json encoding of payload with the supplied method and returns the response, a response without a
+//2xx status code is an error
+func sendJSON(method string, url string, payload interface{}, headers map[string]string) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	requestHeaders := map[string]string{"Content-Type": "application/json"}
+	for key, value := range headers {
+		requestHeaders[key] = value
+	}
+
+	statusCode, response, err := PerformHttpRequest(method, url, "", "", bytes.NewReader(body), requestHeaders)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("unexpected status code %d %s", statusCode, strings.TrimSpace(string(response)))
+	}
+	return response, nil
+}
+
 // PerformHttpRequest performs a HTTP request and returns the status code and the response
 func PerformHttpRequest(
 	method string,
@@ -0,0 +1,62 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+//MatrixNotifier implements the Matrix room message sender using the client-server API and the access token of the
+//sending user. Informational events are sent as m.notice messages, which clients do not alert on by default, warnings
+//and errors as m.text messages
+/*
+matrix docs:
+	https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3roomsroomidsendeventtypetxnid
+
+request url:
+	PUT https://matrix.example.com/_matrix/client/v3/rooms/<room id>/send/m.room.message/<transaction id>
+
+json payload:
+	{
+		"msgtype": "m.notice",
+		"body": "go ddns client ip address update\nThe IP addresses for domain 'example.com' were updated by host..."
+	}
+*/
+type MatrixNotifier struct {
+	conf *config.Matrix
+}
+
+//Send sends the Matrix notification
+func (notifier MatrixNotifier) Send(event *Event) error {
+	if notifier.conf.BaseUrl == "" || notifier.conf.AccessToken == "" || notifier.conf.RoomId == "" {
+		return notifier.matrixError(errors.New("a baseUrl, accessToken and roomId are required"))
+	}
+
+	msgType := "m.notice"
+	if event.Severity() != SeverityInfo {
+		msgType = "m.text"
+	}
+	payload := map[string]interface{}{
+		"msgtype": msgType,
+		"body":    fmt.Sprintf("%s\n%s", event.Subject(), event.Summary()),
+	}
+	headers := map[string]string{"Authorization": "Bearer " + notifier.conf.AccessToken}
+
+	//the transaction id makes the request idempotent, it must be unique for each message of the access token
+	requestUrl := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/go-ddns-client-%d",
+		getBaseUrl(notifier.conf.BaseUrl, ""), url.PathEscape(notifier.conf.RoomId), time.Now().UnixNano())
+	if _, err := sendJSON(http.MethodPut, requestUrl, payload, headers); err != nil {
+		return notifier.matrixError(err)
+	}
+
+	log.Println("Matrix notification sent")
+	return nil
+}
+
+func (notifier MatrixNotifier) matrixError(err error) error {
+	return fmt.Errorf("matrix error: %v", err)
+}
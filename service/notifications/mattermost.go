@@ -0,0 +1,94 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// the Mattermost attachment colours of the event severities
+const (
+	mattermostColorInfo    = "#2eb67d"
+	mattermostColorWarning = "#ecb22e"
+	mattermostColorError   = "#e01e5a"
+)
+
+//MattermostNotifier implements the Mattermost incoming webhook sender, the message carries an attachment
+/*
+mattermost docs:
+	https://developers.mattermost.com/integrate/webhooks/incoming/
+	https://developers.mattermost.com/integrate/reference/message-attachments/
+
+json payload:
+	{
+		"attachments": [
+			{
+				"fallback": "go ddns client ip address update",
+				"color": "#2eb67d",
+				"title": "go ddns client ip address update",
+				"text": "The IP addresses for domain 'example.com' were...\n\n| Domain | Service | Result |\n...",
+				"fields": [{"short": true, "title": "IPv4", "value": "255.255.255.254 → 255.255.255.255"}]
+			}
+		]
+	}
+*/
+type MattermostNotifier struct {
+	conf *config.Mattermost
+}
+
+//Send sends the Mattermost notification
+func (notifier MattermostNotifier) Send(event *Event) error {
+	var fields []map[string]interface{}
+	for _, change := range event.AddressChanges() {
+		fields = append(fields, map[string]interface{}{"short": true, "title": change.Family, "value": change.String()})
+	}
+
+	var text strings.Builder
+	text.WriteString(event.Description())
+	if len(event.Services) > 0 {
+		text.WriteString("\n\n| Domain | Service | Result |\n|:--|:--|:--|\n")
+		for _, result := range event.Services {
+			icon := ":white_check_mark:"
+			if !result.Success {
+				icon = ":x:"
+			}
+			_, _ = fmt.Fprintf(&text, "| %s | %s | %s %s |\n",
+				result.Domain, result.ServiceType, icon, strings.ReplaceAll(event.ServiceStatus(result), "|", "\\|"))
+		}
+	}
+
+	color := mattermostColorInfo
+	switch event.Severity() {
+	case SeverityWarning:
+		color = mattermostColorWarning
+	case SeverityError:
+		color = mattermostColorError
+	}
+
+	payload := map[string]interface{}{
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"fallback": event.Subject(),
+				"color":    color,
+				"title":    event.Subject(),
+				"text":     text.String(),
+				"fields":   fields,
+			},
+		},
+	}
+	if notifier.conf.Channel != "" {
+		payload["channel"] = notifier.conf.Channel
+	}
+	if notifier.conf.Username != "" {
+		payload["username"] = notifier.conf.Username
+	}
+
+	if _, err := sendJSON(http.MethodPost, notifier.conf.WebhookUrl, payload, nil); err != nil {
+		return fmt.Errorf("mattermost error: %v", err)
+	}
+
+	log.Println("Mattermost notification sent")
+	return nil
+}
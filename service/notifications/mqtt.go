@@ -0,0 +1,194 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// the Home Assistant MQTT discovery prefix used when none is configured
+const mqttDefaultDiscoveryPrefix = "homeassistant"
+
+// the characters that are replaced in MQTT topic levels and Home Assistant object ids
+var mqttTopicLevelRegex = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+/*
+MQTTNotifier implements an MQTT 3.1.1 publisher of retained topics below the topic prefix, go-ddns-client/<hostname>
+by default:
+
+	<prefix>/ipv4                  the current public IPv4 address, published on startup and by each event carrying it
+	<prefix>/ipv6                  the current public IPv6 address, published on startup and by each event carrying it
+	<prefix>/last_update           success or failed, the result of the last update of the DDNS services
+	<prefix>/message               the plain text message of the last event, the configured body template or built-in
+	<prefix>/event                 the json of the last event of any type
+	<prefix>/service/<domain>      the json of the last ServiceResult of the domain
+
+With Discovery enabled Home Assistant MQTT discovery configs are published below the discovery prefix so that the
+addresses, the last update result and each service status show up as sensors of a go-ddns-client device:
+
+	homeassistant/sensor/<node id>/<object id>/config
+*/
+type MQTTNotifier struct {
+	conf *config.MQTT
+}
+
+//mqttMessage is a retained message published by the MQTTNotifier
+type mqttMessage struct {
+	topic   string
+	payload []byte
+}
+
+//Send publishes the event topics and, when enabled, the Home Assistant discovery configs
+func (notifier MQTTNotifier) Send(event *Event) error {
+	if notifier.conf.Broker == "" {
+		return notifier.mqttError(errors.New("a broker url is required"))
+	}
+	messages, err := notifier.buildMessages(event)
+	if err != nil {
+		return notifier.mqttError(err)
+	}
+
+	clientID := notifier.conf.ClientId
+	if clientID == "" {
+		clientID = "go-ddns-client-" + mqttTopicLevel(event.Hostname)
+	}
+	client, err := dialMQTT(notifier.conf.Broker, clientID, notifier.conf.Username, notifier.conf.Password,
+		notifier.conf.InsecureSkipVerify)
+	if err != nil {
+		return notifier.mqttError(err)
+	}
+	for _, message := range messages {
+		if err = client.publish(message.topic, message.payload); err != nil {
+			_ = client.close()
+			return notifier.mqttError(err)
+		}
+	}
+	if err = client.close(); err != nil {
+		return notifier.mqttError(err)
+	}
+
+	log.Printf("MQTT notification published to %s", notifier.conf.Broker)
+	return nil
+}
+
+//buildMessages returns the retained messages published for the event
+func (notifier MQTTNotifier) buildMessages(event *Event) ([]mqttMessage, error) {
+	prefix := notifier.getTopicPrefix(event)
+	var messages []mqttMessage
+	if notifier.conf.Discovery {
+		discovery, err := notifier.buildDiscoveryMessages(event, prefix)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, discovery...)
+	}
+
+	switch event.Type {
+	case EventChangeSuppressed, EventNATDetected:
+		//the suppressed address and the WAN address behind NAT are not the published addresses
+	case EventIPChanged, EventStartup:
+		//both families are published, an empty payload clears the retained address of a family without an address
+		messages = append(messages,
+			mqttMessage{topic: prefix + "/ipv4", payload: []byte(event.IPv4)},
+			mqttMessage{topic: prefix + "/ipv6", payload: []byte(event.IPv6)})
+	default:
+		if event.IPv4 != "" {
+			messages = append(messages, mqttMessage{topic: prefix + "/ipv4", payload: []byte(event.IPv4)})
+		}
+		if event.IPv6 != "" {
+			messages = append(messages, mqttMessage{topic: prefix + "/ipv6", payload: []byte(event.IPv6)})
+		}
+	}
+	if len(event.Services) > 0 {
+		result := "success"
+		if event.Severity() == SeverityError {
+			result = "failed"
+		}
+		messages = append(messages, mqttMessage{topic: prefix + "/last_update", payload: []byte(result)})
+	}
+	for _, service := range event.Services {
+		payload, err := json.Marshal(service)
+		if err != nil {
+			return nil, err
+		}
+		topic := prefix + "/service/" + mqttTopicLevel(service.Domain)
+		messages = append(messages, mqttMessage{topic: topic, payload: payload})
+	}
+
+	messages = append(messages, mqttMessage{topic: prefix + "/message", payload: []byte(event.Message())})
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return append(messages, mqttMessage{topic: prefix + "/event", payload: payload}), nil
+}
+
+//buildDiscoveryMessages returns the Home Assistant MQTT discovery configs of the address, last update and service
+//status sensors
+func (notifier MQTTNotifier) buildDiscoveryMessages(event *Event, prefix string) ([]mqttMessage, error) {
+	discoveryPrefix := notifier.conf.DiscoveryPrefix
+	if discoveryPrefix == "" {
+		discoveryPrefix = mqttDefaultDiscoveryPrefix
+	}
+	nodeID := "go_ddns_client_" + mqttTopicLevel(event.Hostname)
+	device := map[string]interface{}{
+		"identifiers":  []string{nodeID},
+		"name":         "go-ddns-client " + event.Hostname,
+		"manufacturer": "go-ddns-client",
+	}
+
+	sensors := []map[string]interface{}{
+		{"object_id": "ipv4", "name": "Public IPv4", "state_topic": prefix + "/ipv4", "icon": "mdi:ip-network"},
+		{"object_id": "ipv6", "name": "Public IPv6", "state_topic": prefix + "/ipv6", "icon": "mdi:ip-network"},
+		{"object_id": "last_update", "name": "Last update", "state_topic": prefix + "/last_update",
+			"json_attributes_topic": prefix + "/event", "icon": "mdi:dns"},
+	}
+	for _, service := range event.Services {
+		objectID := "service_" + mqttTopicLevel(service.Domain)
+		topic := prefix + "/service/" + mqttTopicLevel(service.Domain)
+		sensors = append(sensors, map[string]interface{}{
+			"object_id":             objectID,
+			"name":                  fmt.Sprintf("%s %s", service.Domain, service.ServiceType),
+			"state_topic":           topic,
+			"value_template":        "{{ 'updated' if value_json.success else 'failed' }}",
+			"json_attributes_topic": topic,
+			"icon":                  "mdi:web",
+		})
+	}
+
+	var messages []mqttMessage
+	for _, sensor := range sensors {
+		objectID := sensor["object_id"].(string)
+		sensor["unique_id"] = nodeID + "_" + objectID
+		sensor["device"] = device
+		payload, err := json.Marshal(sensor)
+		if err != nil {
+			return nil, err
+		}
+		topic := fmt.Sprintf("%s/sensor/%s/%s/config", discoveryPrefix, nodeID, objectID)
+		messages = append(messages, mqttMessage{topic: topic, payload: payload})
+	}
+	return messages, nil
+}
+
+//getTopicPrefix returns the configured topic prefix or go-ddns-client/<hostname>
+func (notifier MQTTNotifier) getTopicPrefix(event *Event) string {
+	if notifier.conf.TopicPrefix != "" {
+		return strings.TrimRight(notifier.conf.TopicPrefix, "/")
+	}
+	return "go-ddns-client/" + mqttTopicLevel(event.Hostname)
+}
+
+func (notifier MQTTNotifier) mqttError(err error) error {
+	return fmt.Errorf("MQTT error: %v", err)
+}
+
+//mqttTopicLevel returns value with the characters that are not safe in a topic level or object id replaced
+func mqttTopicLevel(value string) string {
+	return mqttTopicLevelRegex.ReplaceAllString(value, "_")
+}
@@ -0,0 +1,392 @@
+package notifications
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//mqttTestPacket is a control packet received by an mqttTestBroker
+type mqttTestPacket struct {
+	header      byte
+	lengthBytes []byte // the encoded remaining length
+	body        []byte
+}
+
+//startMQTTTestBroker starts an MQTT broker stand-in on 127.0.0.1 accepting a single connection. CONNECT is answered
+//with an accepted CONNACK and each QoS 1 PUBLISH with a PUBACK carrying its packet identifier plus pubAckOffset. The
+//packets received are sent on the returned channel once the connection is closed
+func startMQTTTestBroker(t *testing.T, pubAckOffset uint16) (string, <-chan []mqttTestPacket) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	received := make(chan []mqttTestPacket, 1)
+	go func() {
+		var packets []mqttTestPacket
+		defer func() {
+			received <- packets
+		}()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		reader := bufio.NewReader(conn)
+		for {
+			packet, err := readMQTTTestPacket(reader)
+			if err != nil {
+				return
+			}
+			packets = append(packets, packet)
+
+			var response []byte
+			switch packet.header & 0xf0 {
+			case mqttConnect:
+				response = []byte{mqttConnAck, 0x02, 0x00, 0x00}
+			case mqttPublish:
+				if packet.header&0x06 != mqttPublishQoS1 || len(packet.body) < 2 {
+					continue
+				}
+				topicLength := int(packet.body[0])<<8 | int(packet.body[1])
+				if len(packet.body) < 2+topicLength+2 {
+					continue
+				}
+				id := uint16(packet.body[2+topicLength])<<8 | uint16(packet.body[3+topicLength]) + pubAckOffset
+				response = []byte{mqttPubAck, 0x02, byte(id >> 8), byte(id)}
+			case mqttDisconnect:
+				return
+			}
+			if _, err = conn.Write(response); err != nil {
+				return
+			}
+		}
+	}()
+	return "tcp://" + listener.Addr().String(), received
+}
+
+//readMQTTTestPacket reads a control packet, decoding the variable length remaining length
+func readMQTTTestPacket(reader *bufio.Reader) (mqttTestPacket, error) {
+	var packet mqttTestPacket
+	var err error
+	if packet.header, err = reader.ReadByte(); err != nil {
+		return packet, err
+	}
+	length, multiplier := 0, 1
+	for {
+		encoded, err := reader.ReadByte()
+		if err != nil {
+			return packet, err
+		}
+		packet.lengthBytes = append(packet.lengthBytes, encoded)
+		length += int(encoded&0x7f) * multiplier
+		multiplier *= 128
+		if encoded&0x80 == 0 {
+			break
+		}
+	}
+	packet.body = make([]byte, length)
+	_, err = io.ReadFull(reader, packet.body)
+	return packet, err
+}
+
+//waitForMQTTTestPackets returns the packets received by the broker stand-in
+func waitForMQTTTestPackets(t *testing.T, received <-chan []mqttTestPacket) []mqttTestPacket {
+	t.Helper()
+	select {
+	case packets := <-received:
+		return packets
+	case <-time.After(5 * time.Second):
+		t.Fatal("the MQTT broker stand-in received no connection")
+		return nil
+	}
+}
+
+//mqttTestString returns the length prefixed encoding of value
+func mqttTestString(value string) []byte {
+	return append([]byte{byte(len(value) >> 8), byte(len(value))}, value...)
+}
+
+//mqttTestPublish is a decoded PUBLISH packet
+type mqttTestPublish struct {
+	topic    string
+	packetID uint16
+	payload  []byte
+}
+
+//decodeMQTTTestPublish decodes the topic, packet identifier and payload of a QoS 1 PUBLISH packet
+func decodeMQTTTestPublish(t *testing.T, packet mqttTestPacket) mqttTestPublish {
+	t.Helper()
+	if packet.header != 0x33 {
+		t.Fatalf("expected a retained QoS 1 PUBLISH header 0x33, got 0x%02x", packet.header)
+	}
+	topicLength := int(packet.body[0])<<8 | int(packet.body[1])
+	return mqttTestPublish{
+		topic:    string(packet.body[2 : 2+topicLength]),
+		packetID: uint16(packet.body[2+topicLength])<<8 | uint16(packet.body[3+topicLength]),
+		payload:  packet.body[4+topicLength:],
+	}
+}
+
+//mqttTestEvent returns an ip_changed event of a single successful service update
+func mqttTestEvent() *Event {
+	return &Event{
+		Type:     EventIPChanged,
+		Hostname: "host.example.com",
+		IPv4:     "81.2.69.142",
+		IPv6:     "2a00:1450:4009:81d::200e",
+		OldIPv4:  "81.2.69.1",
+		Services: []ServiceResult{{ServiceType: "Cloudflare", Domain: "home.example.com", Success: true}},
+	}
+}
+
+func TestMQTTClientConnect(t *testing.T) {
+	tests := []struct {
+		username string
+		password string
+		flags    byte
+	}{
+		{"", "", 0x02},
+		{"ddns", "", 0x82},
+		{"ddns", "secret", 0xc2},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("flags 0x%02x", test.flags), func(t *testing.T) {
+			broker, received := startMQTTTestBroker(t, 0)
+			client, err := dialMQTT(broker, "go-ddns-client-test", test.username, test.password, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = client.close(); err != nil {
+				t.Fatal(err)
+			}
+
+			packets := waitForMQTTTestPackets(t, received)
+			if len(packets) != 2 || packets[0].header != mqttConnect || packets[1].header != mqttDisconnect ||
+				len(packets[1].body) != 0 {
+				t.Fatalf("expected a CONNECT and an empty DISCONNECT packet, got %v", packets)
+			}
+			expected := append([]byte{0x00, 0x04, 'M', 'Q', 'T', 'T', 0x04, test.flags, 0x00, 0x3c},
+				mqttTestString("go-ddns-client-test")...)
+			if test.username != "" {
+				expected = append(expected, mqttTestString(test.username)...)
+			}
+			if test.password != "" {
+				expected = append(expected, mqttTestString(test.password)...)
+			}
+			if !bytes.Equal(packets[0].body, expected) {
+				t.Errorf("expected the CONNECT body %x, got %x", expected, packets[0].body)
+			}
+		})
+	}
+}
+
+func TestMQTTNotifierPublishesRetainedTopics(t *testing.T) {
+	broker, received := startMQTTTestBroker(t, 0)
+	notifier := MQTTNotifier{conf: &config.MQTT{
+		Broker:    broker,
+		Username:  "ddns",
+		Password:  "secret",
+		Discovery: true,
+	}}
+	if err := notifier.Send(mqttTestEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := waitForMQTTTestPackets(t, received)
+	if len(packets) < 2 || packets[0].header != mqttConnect || packets[len(packets)-1].header != mqttDisconnect {
+		t.Fatalf("expected the PUBLISH packets between a CONNECT and a DISCONNECT, got %v", packets)
+	}
+	expectedPayload := append(mqttTestString("go-ddns-client-host_example_com"),
+		append(mqttTestString("ddns"), mqttTestString("secret")...)...)
+	if packets[0].body[7] != 0xc2 || !bytes.HasSuffix(packets[0].body, expectedPayload) {
+		t.Errorf("expected the CONNECT flags 0xc2 and payload %x, got %x", expectedPayload, packets[0].body)
+	}
+
+	discovery := "homeassistant/sensor/go_ddns_client_host_example_com/"
+	prefix := "go-ddns-client/host_example_com/"
+	expectedTopics := []string{
+		discovery + "ipv4/config",
+		discovery + "ipv6/config",
+		discovery + "last_update/config",
+		discovery + "service_home_example_com/config",
+		prefix + "ipv4",
+		prefix + "ipv6",
+		prefix + "last_update",
+		prefix + "service/home_example_com",
+		prefix + "message",
+		prefix + "event",
+	}
+	var topics []string
+	payloads := make(map[string][]byte)
+	longPacket := false
+	for index, packet := range packets[1 : len(packets)-1] {
+		publish := decodeMQTTTestPublish(t, packet)
+		if publish.packetID != uint16(index+1) {
+			t.Errorf("expected the packet identifier %d of %s, got %d", index+1, publish.topic, publish.packetID)
+		}
+		if len(packet.body) > 127 {
+			longPacket = true
+			if len(packet.lengthBytes) < 2 {
+				t.Errorf("expected a multi byte remaining length of %d bytes, got %x", len(packet.body),
+					packet.lengthBytes)
+			}
+		}
+		topics = append(topics, publish.topic)
+		payloads[publish.topic] = publish.payload
+	}
+	if !reflect.DeepEqual(topics, expectedTopics) {
+		t.Fatalf("expected the topics %v, got %v", expectedTopics, topics)
+	}
+	if !longPacket {
+		t.Error("expected a PUBLISH packet over 127 bytes")
+	}
+
+	for topic, expected := range map[string]string{
+		prefix + "ipv4":        "81.2.69.142",
+		prefix + "ipv6":        "2a00:1450:4009:81d::200e",
+		prefix + "last_update": "success",
+	} {
+		if string(payloads[topic]) != expected {
+			t.Errorf("expected the %s payload %s, got %s", topic, expected, payloads[topic])
+		}
+	}
+
+	if !strings.HasPrefix(string(payloads[prefix+"message"]), "The IP addresses for domain") {
+		t.Errorf("expected the built-in ip_changed message, got %s", payloads[prefix+"message"])
+	}
+
+	var sensor struct {
+		UniqueID            string `json:"unique_id"`
+		StateTopic          string `json:"state_topic"`
+		JSONAttributesTopic string `json:"json_attributes_topic"`
+		ValueTemplate       string `json:"value_template"`
+		Device              struct {
+			Identifiers []string `json:"identifiers"`
+		} `json:"device"`
+	}
+	if err := json.Unmarshal(payloads[discovery+"service_home_example_com/config"], &sensor); err != nil {
+		t.Fatal(err)
+	}
+	if sensor.UniqueID != "go_ddns_client_host_example_com_service_home_example_com" ||
+		sensor.StateTopic != prefix+"service/home_example_com" ||
+		sensor.JSONAttributesTopic != sensor.StateTopic ||
+		!strings.Contains(sensor.ValueTemplate, "value_json.success") ||
+		!reflect.DeepEqual(sensor.Device.Identifiers, []string{"go_ddns_client_host_example_com"}) {
+		t.Errorf("unexpected service discovery config %s", payloads[discovery+"service_home_example_com/config"])
+	}
+
+	var event Event
+	if err := json.Unmarshal(payloads[prefix+"event"], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventIPChanged || event.IPv4 != "81.2.69.142" {
+		t.Errorf("unexpected event payload %s", payloads[prefix+"event"])
+	}
+}
+
+func TestMQTTNotifierRejectsMismatchedPubAck(t *testing.T) {
+	broker, received := startMQTTTestBroker(t, 1)
+	notifier := MQTTNotifier{conf: &config.MQTT{Broker: broker}}
+	err := notifier.Send(mqttTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "packet identifier") {
+		t.Errorf("expected a packet identifier error, got %v", err)
+	}
+	waitForMQTTTestPackets(t, received)
+}
+
+func TestMQTTRemainingLength(t *testing.T) {
+	tests := []struct {
+		length  int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{321, []byte{0xc1, 0x02}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+	}
+	for _, test := range tests {
+		clientConn, brokerConn := net.Pipe()
+		client := &mqttClient{conn: clientConn, reader: bufio.NewReader(clientConn)}
+		body := bytes.Repeat([]byte{'x'}, test.length)
+		go func() {
+			_ = client.writePacket(mqttPublish, body)
+		}()
+
+		packet := make([]byte, 1+len(test.encoded)+test.length)
+		if _, err := io.ReadFull(brokerConn, packet); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(packet[1:1+len(test.encoded)], test.encoded) {
+			t.Errorf("expected the remaining length %d to be encoded as %x, got %x", test.length, test.encoded,
+				packet[1:1+len(test.encoded)])
+		}
+
+		//the packet is echoed back and decoded by the client
+		go func() {
+			_, _ = brokerConn.Write(packet)
+		}()
+		header, decoded, err := client.readPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header != mqttPublish || !bytes.Equal(decoded, body) {
+			t.Errorf("expected the remaining length %d to be decoded, got %d", test.length, len(decoded))
+		}
+		_ = clientConn.Close()
+		_ = brokerConn.Close()
+	}
+}
+
+func TestMQTTNotifierAddressTopics(t *testing.T) {
+	prefix := "go-ddns-client/host/"
+	tests := []struct {
+		event    Event
+		expected map[string]string
+	}{
+		{Event{Type: EventStartup, IPv4: "81.2.69.142"},
+			map[string]string{prefix + "ipv4": "81.2.69.142", prefix + "ipv6": ""}},
+		{Event{Type: EventUpdateFailed, IPv6: "2a00:1450:4009:81d::200e"},
+			map[string]string{prefix + "ipv6": "2a00:1450:4009:81d::200e"}},
+		{Event{Type: EventChangeSuppressed, IPv4: "192.0.2.1", OldIPv4: "81.2.69.142"},
+			map[string]string{}},
+		{Event{Type: EventNATDetected, IPv4: "100.64.0.1"},
+			map[string]string{}},
+		{Event{Type: EventConfigReloaded},
+			map[string]string{}},
+	}
+	for _, test := range tests {
+		test.event.Hostname = "host"
+		messages, err := MQTTNotifier{conf: &config.MQTT{}}.buildMessages(&test.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addresses := make(map[string]string)
+		for _, message := range messages {
+			if strings.HasSuffix(message.topic, "/ipv4") || strings.HasSuffix(message.topic, "/ipv6") {
+				addresses[message.topic] = string(message.payload)
+			}
+		}
+		if !reflect.DeepEqual(addresses, test.expected) {
+			t.Errorf("expected the %s address topics %v, got %v", test.event.Type, test.expected, addresses)
+		}
+	}
+}
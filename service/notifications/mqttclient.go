@@ -0,0 +1,198 @@
+package notifications
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// the MQTT 3.1.1 control packet types and flags used by the mqttClient
+const (
+	mqttConnect        byte = 0x10
+	mqttConnAck        byte = 0x20
+	mqttPublish        byte = 0x30
+	mqttPubAck         byte = 0x40
+	mqttDisconnect     byte = 0xe0
+	mqttPublishQoS1    byte = 0x02
+	mqttPublishRetain  byte = 0x01
+	mqttProtocolLevel  byte = 0x04
+	mqttFlagUsername   byte = 0x80
+	mqttFlagPassword   byte = 0x40
+	mqttFlagCleanStart byte = 0x02
+)
+
+// the time allowed for the whole exchange with the MQTT broker
+const mqttTimeout = 10 * time.Second
+
+//mqttClient is a minimal MQTT 3.1.1 client able to publish retained QoS 1 messages over TCP or TLS
+type mqttClient struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	packetID uint16
+}
+
+//dialMQTT connects to the broker described by a tcp://host:port, mqtt://, tls://, ssl:// or mqtts:// url and performs
+//the MQTT CONNECT handshake
+func dialMQTT(broker string, clientID, username, password string, insecureSkipVerify bool) (*mqttClient, error) {
+	brokerUrl, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: mqttTimeout}
+	var conn net.Conn
+	switch brokerUrl.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.Dial("tcp", getBrokerAddress(brokerUrl, "1883"))
+	case "tls", "ssl", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", getBrokerAddress(brokerUrl, "8883"), &tls.Config{
+			ServerName:         brokerUrl.Hostname(),
+			InsecureSkipVerify: insecureSkipVerify,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported MQTT broker scheme %s", brokerUrl.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.SetDeadline(time.Now().Add(mqttTimeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	client := &mqttClient{conn: conn, reader: bufio.NewReader(conn)}
+	if err = client.connect(clientID, username, password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+//connect sends the CONNECT packet and waits for a successful CONNACK
+func (client *mqttClient) connect(clientID, username, password string) error {
+	flags := mqttFlagCleanStart
+	payload := mqttString(clientID)
+	if username != "" {
+		flags |= mqttFlagUsername
+		payload = append(payload, mqttString(username)...)
+		if password != "" {
+			flags |= mqttFlagPassword
+			payload = append(payload, mqttString(password)...)
+		}
+	}
+	variableHeader := append(mqttString("MQTT"), mqttProtocolLevel, flags, 0x00, 0x3c) //60 second keep alive
+	if err := client.writePacket(mqttConnect, append(variableHeader, payload...)); err != nil {
+		return err
+	}
+
+	packetType, body, err := client.readPacket()
+	if err != nil {
+		return err
+	}
+	if packetType&0xf0 != mqttConnAck || len(body) != 2 {
+		return fmt.Errorf("unexpected MQTT packet 0x%02x in place of CONNACK", packetType)
+	}
+	if body[1] != 0 {
+		return fmt.Errorf("the MQTT broker refused the connection with return code %d", body[1])
+	}
+	return nil
+}
+
+//publish publishes a retained QoS 1 message and waits for its PUBACK
+func (client *mqttClient) publish(topic string, payload []byte) error {
+	client.packetID++
+	if client.packetID == 0 {
+		client.packetID = 1
+	}
+	body := append(mqttString(topic), byte(client.packetID>>8), byte(client.packetID))
+	body = append(body, payload...)
+	if err := client.writePacket(mqttPublish|mqttPublishQoS1|mqttPublishRetain, body); err != nil {
+		return err
+	}
+
+	packetType, ack, err := client.readPacket()
+	if err != nil {
+		return err
+	}
+	if packetType&0xf0 != mqttPubAck || len(ack) != 2 {
+		return fmt.Errorf("unexpected MQTT packet 0x%02x in place of PUBACK", packetType)
+	}
+	if uint16(ack[0])<<8|uint16(ack[1]) != client.packetID {
+		return errors.New("the MQTT PUBACK packet identifier does not match the PUBLISH")
+	}
+	return nil
+}
+
+//close sends the DISCONNECT packet and closes the connection
+func (client *mqttClient) close() error {
+	err := client.writePacket(mqttDisconnect, nil)
+	if closeErr := client.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+//writePacket writes a control packet with the supplied fixed header byte and body
+func (client *mqttClient) writePacket(header byte, body []byte) error {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		encoded := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			encoded |= 0x80
+		}
+		packet = append(packet, encoded)
+		if length == 0 {
+			break
+		}
+	}
+	_, err := client.conn.Write(append(packet, body...))
+	return err
+}
+
+//readPacket reads a control packet and returns its fixed header byte and body
+func (client *mqttClient) readPacket() (byte, []byte, error) {
+	header, err := client.reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for index := 0; ; index++ {
+		if index == 4 {
+			return 0, nil, errors.New("invalid MQTT remaining length")
+		}
+		encoded, err := client.reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(encoded&0x7f) * multiplier
+		multiplier *= 128
+		if encoded&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(client.reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+//mqttString returns the length prefixed UTF-8 encoding of value
+func mqttString(value string) []byte {
+	return append([]byte{byte(len(value) >> 8), byte(len(value))}, value...)
+}
+
+//getBrokerAddress returns the host:port address of the broker url, defaultPort is used when the url has no port
+func getBrokerAddress(brokerUrl *url.URL, defaultPort string) string {
+	port := brokerUrl.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(brokerUrl.Hostname(), port)
+}
@@ -0,0 +1,81 @@
+package notifications
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+)
+
+// the public ntfy server url
+const ntfyDefaultBaseUrl = "https://ntfy.sh"
+
+//NtfyNotifier implements the ntfy publisher for the public ntfy.sh server or a self-hosted server. The event severity
+//maps to the default (3), high (4) and urgent (5) priorities unless a fixed priority is configured
+/*
+ntfy docs:
+	https://docs.ntfy.sh/publish/#publish-as-json
+
+request url:
+	https://ntfy.sh/
+
+json payload:
+	{
+		"topic": "mytopic",
+		"title": "go ddns client ip address update",
+		"message": "The IP addresses for domain 'example.com' were updated by host...",
+		"priority": 3,
+		"tags": ["globe_with_meridians"]
+	}
+*/
+type NtfyNotifier struct {
+	conf *config.Ntfy
+}
+
+//Send sends the ntfy notification
+func (notifier NtfyNotifier) Send(event *Event) error {
+	if notifier.conf.Topic == "" {
+		return notifier.ntfyError(errors.New("a topic is required"))
+	}
+
+	priority, tag := 3, "globe_with_meridians"
+	switch event.Severity() {
+	case SeverityWarning:
+		priority, tag = 4, "warning"
+	case SeverityError:
+		priority, tag = 5, "rotating_light"
+	}
+	if notifier.conf.Priority != 0 {
+		priority = notifier.conf.Priority
+	}
+
+	payload := map[string]interface{}{
+		"topic":    notifier.conf.Topic,
+		"title":    event.Subject(),
+		"message":  event.Summary(),
+		"priority": priority,
+		"tags":     []string{tag},
+	}
+
+	headers := make(map[string]string)
+	if notifier.conf.Token != "" {
+		headers["Authorization"] = "Bearer " + notifier.conf.Token
+	} else if notifier.conf.Username != "" {
+		credentials := notifier.conf.Username + ":" + notifier.conf.Password
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+
+	url := getBaseUrl(notifier.conf.BaseUrl, ntfyDefaultBaseUrl) + "/"
+	if _, err := sendJSON(http.MethodPost, url, payload, headers); err != nil {
+		return notifier.ntfyError(err)
+	}
+
+	log.Println("ntfy notification sent")
+	return nil
+}
+
+func (notifier NtfyNotifier) ntfyError(err error) error {
+	return fmt.Errorf("ntfy error: %v", err)
+}
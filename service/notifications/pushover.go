@@ -0,0 +1,75 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+)
+
+// the public Pushover API url
+const pushoverDefaultBaseUrl = "https://api.pushover.net"
+
+//PushoverNotifier implements the Pushover message sender. Informational events are sent with the quiet priority -1,
+//warnings with the normal priority 0 and errors with the high priority 1 that bypasses quiet hours
+/*
+pushover docs:
+	https://pushover.net/api
+
+request url:
+	https://api.pushover.net/1/messages.json
+
+json payload:
+	{
+		"token": "application token",
+		"user": "user key",
+		"title": "go ddns client ip address update",
+		"message": "The IP addresses for domain 'example.com' were updated by host...",
+		"priority": -1
+	}
+*/
+type PushoverNotifier struct {
+	conf *config.Pushover
+}
+
+//Send sends the Pushover notification
+func (notifier PushoverNotifier) Send(event *Event) error {
+	if notifier.conf.Token == "" || notifier.conf.UserKey == "" {
+		return notifier.pushoverError(errors.New("a token and userKey are required"))
+	}
+
+	priority := -1
+	switch event.Severity() {
+	case SeverityWarning:
+		priority = 0
+	case SeverityError:
+		priority = 1
+	}
+
+	payload := map[string]interface{}{
+		"token":    notifier.conf.Token,
+		"user":     notifier.conf.UserKey,
+		"title":    event.Subject(),
+		"message":  event.Summary(),
+		"priority": priority,
+	}
+	if notifier.conf.Device != "" {
+		payload["device"] = notifier.conf.Device
+	}
+	if !event.Timestamp.IsZero() {
+		payload["timestamp"] = event.Timestamp.Unix()
+	}
+
+	url := getBaseUrl(notifier.conf.BaseUrl, pushoverDefaultBaseUrl) + "/1/messages.json"
+	if _, err := sendJSON(http.MethodPost, url, payload, nil); err != nil {
+		return notifier.pushoverError(err)
+	}
+
+	log.Println("Pushover notification sent")
+	return nil
+}
+
+func (notifier PushoverNotifier) pushoverError(err error) error {
+	return fmt.Errorf("pushover error: %v", err)
+}
@@ -2,6 +2,7 @@ package notifications
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/bebo-dot-dev/go-ddns-client/service/config"
 	"log"
@@ -21,7 +22,7 @@ json payload:
 	{
 		"smsId": "smsId",
 		"recipient": "0123456789",
-		"message": "The IP address for domain 'example.com' has been updated to '127.0.0.1'"
+		"message": "The IP addresses for domain 'example.com' were updated to:\n127.0.0.1\nby: host"
 	}
 */
 type SipGateSmsNotifier struct {
@@ -29,30 +30,21 @@ type SipGateSmsNotifier struct {
 }
 
 //Send sends the sipgate IO sms notification
-func (notifier SipGateSmsNotifier) Send(hostname string, domainCount int, domainsStr, ipv4, ipv6 string) error {
-	plural := ""
-	if domainCount > 1 {
-		plural = "s"
+func (notifier SipGateSmsNotifier) Send(event *Event) error {
+	jsonBody, err := notifier.buildBody(event)
+	if err != nil {
+		return notifier.sipgateError(err)
 	}
 
-	msg := fmt.Sprintf("The IP addresses for domain%s '%s' were updated to:\n%s\n%s\nby: %s",
-		plural, domainsStr, ipv4, ipv6, hostname)
-
-	jsonBody := fmt.Sprintf(`{
-		"smsId": "%s",
-		"recipient": "%s",
-		"message": "%s"
-	}`, notifier.conf.SmsId, notifier.conf.Recipient, msg)
-
 	headers := make(map[string]string)
 	headers["Content-Type"] = "application/json"
 
-	_, _, err := PerformHttpRequest(
+	_, _, err = PerformHttpRequest(
 		http.MethodPost,
 		"https://api.sipgate.com/v2/sessions/sms",
 		notifier.conf.TokenId,
 		notifier.conf.Token,
-		bytes.NewBuffer([]byte(jsonBody)),
+		bytes.NewBuffer(jsonBody),
 		headers)
 
 	if err != nil {
@@ -63,6 +55,15 @@ func (notifier SipGateSmsNotifier) Send(hostname string, domainCount int, domain
 	return nil
 }
 
+//buildBody returns the json request body carrying the plain text message of the event
+func (notifier SipGateSmsNotifier) buildBody(event *Event) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"smsId":     notifier.conf.SmsId,
+		"recipient": notifier.conf.Recipient,
+		"message":   event.Message(),
+	})
+}
+
 func (notifier SipGateSmsNotifier) sipgateError(err error) error {
 	return fmt.Errorf("sipgate IO SMS error: %v", err)
 }
@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+	"strings"
+)
+
+//SlackNotifier implements the Slack incoming webhook sender
+/*
+slack docs:
+	https://api.slack.com/messaging/webhooks
+	https://api.slack.com/reference/block-kit/blocks
+
+json payload:
+	{
+		"text": "go ddns client ip address update",
+		"blocks": [
+			{"type": "header", "text": {"type": "plain_text", "text": "go ddns client ip address update"}},
+			{"type": "section", "text": {"type": "mrkdwn", "text": "The IP addresses for domain 'example.com' were..."}},
+			{"type": "section", "fields": [{"type": "mrkdwn", "text": "*IPv4*\n255.255.255.254 → 255.255.255.255"}]},
+			{"type": "section", "text": {"type": "mrkdwn", "text": ":white_check_mark: *example.com* DuckDNS updated"}}
+		]
+	}
+*/
+type SlackNotifier struct {
+	conf *config.Slack
+}
+
+//Send sends the Slack notification
+func (notifier SlackNotifier) Send(event *Event) error {
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": event.Subject()}},
+		{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": event.Description()}},
+	}
+
+	var fields []map[string]string
+	for _, change := range event.AddressChanges() {
+		fields = append(fields, map[string]string{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("*%s*\n%s", change.Family, change.String()),
+		})
+	}
+	if len(fields) > 0 {
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields})
+	}
+
+	if len(event.Services) > 0 {
+		var rows []string
+		for _, result := range event.Services {
+			icon := ":white_check_mark:"
+			if !result.Success {
+				icon = ":x:"
+			}
+			rows = append(rows, fmt.Sprintf("%s *%s* %s %s",
+				icon, result.Domain, result.ServiceType, event.ServiceStatus(result)))
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": strings.Join(rows, "\n")},
+		})
+	}
+
+	payload := map[string]interface{}{"text": event.Subject(), "blocks": blocks}
+	if notifier.conf.Channel != "" {
+		payload["channel"] = notifier.conf.Channel
+	}
+	if notifier.conf.Username != "" {
+		payload["username"] = notifier.conf.Username
+	}
+
+	if _, err := sendJSON(http.MethodPost, notifier.conf.WebhookUrl, payload, nil); err != nil {
+		return fmt.Errorf("slack error: %v", err)
+	}
+
+	log.Println("Slack notification sent")
+	return nil
+}
@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+)
+
+//TeamsNotifier implements the Microsoft Teams incoming webhook sender, the message carries an adaptive card
+/*
+teams docs:
+	https://learn.microsoft.com/en-us/microsoftteams/platform/webhooks-and-connectors/how-to/connectors-using
+	https://adaptivecards.io/explorer/
+
+json payload:
+	{
+		"type": "message",
+		"attachments": [
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": {
+					"type": "AdaptiveCard",
+					"version": "1.4",
+					"body": [
+						{"type": "TextBlock", "text": "go ddns client ip address update", "weight": "Bolder", "size": "Medium"},
+						{"type": "TextBlock", "text": "The IP addresses for domain 'example.com' were...", "wrap": true},
+						{"type": "FactSet", "facts": [{"title": "IPv4", "value": "255.255.255.254 → 255.255.255.255"}]},
+						{"type": "FactSet", "facts": [{"title": "example.com", "value": "✅ DuckDNS updated"}]}
+					]
+				}
+			}
+		]
+	}
+*/
+type TeamsNotifier struct {
+	conf *config.Teams
+}
+
+//Send sends the Microsoft Teams notification
+func (notifier TeamsNotifier) Send(event *Event) error {
+	titleColor := "Good"
+	switch event.Severity() {
+	case SeverityWarning:
+		titleColor = "Warning"
+	case SeverityError:
+		titleColor = "Attention"
+	}
+
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": event.Subject(), "weight": "Bolder", "size": "Medium", "color": titleColor},
+		{"type": "TextBlock", "text": event.Description(), "wrap": true},
+	}
+
+	var addressFacts []map[string]string
+	for _, change := range event.AddressChanges() {
+		addressFacts = append(addressFacts, map[string]string{"title": change.Family, "value": change.String()})
+	}
+	if len(addressFacts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": addressFacts})
+	}
+
+	var serviceFacts []map[string]string
+	for _, result := range event.Services {
+		icon := "✅"
+		if !result.Success {
+			icon = "❌"
+		}
+		serviceFacts = append(serviceFacts, map[string]string{
+			"title": result.Domain,
+			"value": fmt.Sprintf("%s %s %s", icon, result.ServiceType, event.ServiceStatus(result)),
+		})
+	}
+	if len(serviceFacts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "separator": true, "facts": serviceFacts})
+	}
+
+	payload := map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+
+	if _, err := sendJSON(http.MethodPost, notifier.conf.WebhookUrl, payload, nil); err != nil {
+		return fmt.Errorf("microsoft teams error: %v", err)
+	}
+
+	log.Println("Microsoft Teams notification sent")
+	return nil
+}
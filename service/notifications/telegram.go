@@ -0,0 +1,58 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+)
+
+// the public Telegram Bot API url
+const telegramDefaultBaseUrl = "https://api.telegram.org"
+
+//TelegramNotifier implements the Telegram Bot API message sender. Informational events are sent silently, warnings
+//and errors with a notification sound
+/*
+telegram docs:
+	https://core.telegram.org/bots/api#sendmessage
+
+request url:
+	https://api.telegram.org/bot<token>/sendMessage
+
+json payload:
+	{
+		"chat_id": "123456789",
+		"text": "go ddns client ip address update\nThe IP addresses for domain 'example.com' were updated by host...",
+		"disable_notification": true
+	}
+*/
+type TelegramNotifier struct {
+	conf *config.Telegram
+}
+
+//Send sends the Telegram notification
+func (notifier TelegramNotifier) Send(event *Event) error {
+	if notifier.conf.BotToken == "" || notifier.conf.ChatId == "" {
+		return notifier.telegramError(errors.New("a botToken and chatId are required"))
+	}
+
+	payload := map[string]interface{}{
+		"chat_id":              notifier.conf.ChatId,
+		"text":                 fmt.Sprintf("%s\n%s", event.Subject(), event.Summary()),
+		"disable_notification": event.Severity() == SeverityInfo,
+	}
+	url := fmt.Sprintf("%s/bot%s/sendMessage",
+		getBaseUrl(notifier.conf.BaseUrl, telegramDefaultBaseUrl), notifier.conf.BotToken)
+
+	if _, err := sendJSON(http.MethodPost, url, payload, nil); err != nil {
+		return notifier.telegramError(err)
+	}
+
+	log.Println("Telegram notification sent")
+	return nil
+}
+
+func (notifier TelegramNotifier) telegramError(err error) error {
+	return fmt.Errorf("telegram error: %v", err)
+}
@@ -0,0 +1,325 @@
+package notifications
+
+import (
+	"bytes"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	"text/template"
+)
+
+// the locale of the built-in messages used when no locale or an unknown locale is configured
+const defaultLocale = "en"
+
+// the template key of the message templates that apply to all event types without their own template
+const defaultTemplateKey = "default"
+
+// the built-in message of the event types that are described by their description and details
+const detailedMessage = "{{.Description}}{{with .Details}}\n{{.}}{{end}}"
+
+// the built-in html body of email notifications, the plain text message one line per line break
+const defaultHTMLMessage = `<!DOCTYPE html>
+<html>
+<body>
+<p>{{range $index, $line := lines .Message}}{{if $index}}<br>
+{{end}}{{$line}}{{end}}</p>
+</body>
+</html>
+`
+
+//localeMessages are the built-in text/template messages of a locale, the subjects, descriptions and messages are keyed
+//by event type
+type localeMessages struct {
+	subjects     map[string]string
+	descriptions map[string]string
+	messages     map[string]string
+	updated      string //the status of a successful service update
+	failed       string //the status of a failed service update, followed by the error
+}
+
+//locales holds the built-in messages of the shipped locales
+var locales = map[string]*localeMessages{
+	"en": {
+		subjects: map[string]string{
+			EventIPChanged:        "go ddns client ip address update",
+			EventChangeSuppressed: "go ddns client ip address change suppressed",
+			EventNATDetected:      "go ddns client carrier grade or double NAT detected",
+			EventUpdateFailed:     "go ddns client update failed",
+			EventUpdateRecovered:  "go ddns client update recovered",
+			EventIPLookupFailed:   "go ddns client ip address lookup failed",
+			EventConfigReloaded:   "go ddns client configuration reloaded",
+			EventStartup:          "go ddns client started",
+		},
+		descriptions: map[string]string{
+			EventIPChanged: `The IP addresses for domain{{plural .DomainCount "" "s"}} '{{.Domains}}' were updated by ` +
+				`{{.Hostname}}`,
+			EventChangeSuppressed: `A change of the IP addresses for domain{{plural .DomainCount "" "s"}} '{{.Domains}}' ` +
+				`was not published by {{.Hostname}}: {{.Reason}}`,
+			EventNATDetected: `Inbound access through domain{{plural .DomainCount "" "s"}} '{{.Domains}}' is unlikely ` +
+				`to work, {{.Reason}} ({{.Hostname}})`,
+			EventUpdateFailed: `The DDNS update failed {{.Failures}} times in a row on {{.Hostname}}: {{.Reason}}`,
+			EventUpdateRecovered: `The DDNS update succeeded again on {{.Hostname}} after {{.Failures}} failed runs: ` +
+				`{{.Reason}}`,
+			EventIPLookupFailed: `The public IP addresses could not be determined {{.Failures}} times in a row on ` +
+				`{{.Hostname}}: {{.Reason}}`,
+			EventConfigReloaded: `The configuration of {{.Hostname}} for domain{{plural .DomainCount "" "s"}} ` +
+				`'{{.Domains}}' was reloaded`,
+			EventStartup: `go ddns client started on {{.Hostname}} for domain{{plural .DomainCount "" "s"}} '{{.Domains}}'`,
+		},
+		messages: map[string]string{
+			EventIPChanged: `The IP addresses for domain{{plural .DomainCount "" "s"}} '{{.Domains}}' were updated to:` +
+				"\n{{addresses .IPv4 .IPv6}}\nby: {{.Hostname}}",
+			EventChangeSuppressed: `A change of the IP addresses for domain{{plural .DomainCount "" "s"}} '{{.Domains}}' ` +
+				"to:\n{{addresses .IPv4 .IPv6}}\nwas not published: {{.Reason}}\nby: {{.Hostname}}",
+			EventNATDetected: `Inbound access through domain{{plural .DomainCount "" "s"}} '{{.Domains}}' is unlikely ` +
+				"to work, {{.Reason}}\nby: {{.Hostname}}",
+		},
+		updated: "updated",
+		failed:  "failed",
+	},
+	"de": {
+		subjects: map[string]string{
+			EventIPChanged:        "go ddns client IP-Adressaktualisierung",
+			EventChangeSuppressed: "go ddns client IP-Adressänderung unterdrückt",
+			EventNATDetected:      "go ddns client Carrier-Grade- oder Doppel-NAT erkannt",
+			EventUpdateFailed:     "go ddns client Aktualisierung fehlgeschlagen",
+			EventUpdateRecovered:  "go ddns client Aktualisierung wiederhergestellt",
+			EventIPLookupFailed:   "go ddns client IP-Adressabfrage fehlgeschlagen",
+			EventConfigReloaded:   "go ddns client Konfiguration neu geladen",
+			EventStartup:          "go ddns client gestartet",
+		},
+		descriptions: map[string]string{
+			EventIPChanged: `Die IP-Adressen für {{plural .DomainCount "die Domain" "die Domains"}} '{{.Domains}}' ` +
+				`wurden von {{.Hostname}} aktualisiert`,
+			EventChangeSuppressed: `Eine Änderung der IP-Adressen für {{plural .DomainCount "die Domain" "die Domains"}} ` +
+				`'{{.Domains}}' wurde von {{.Hostname}} nicht veröffentlicht: {{.Reason}}`,
+			EventNATDetected: `Eingehender Zugriff über {{plural .DomainCount "die Domain" "die Domains"}} ` +
+				`'{{.Domains}}' wird wahrscheinlich nicht funktionieren, {{.Reason}} ({{.Hostname}})`,
+			EventUpdateFailed: `Die DDNS-Aktualisierung ist auf {{.Hostname}} {{.Failures}}-mal in Folge ` +
+				`fehlgeschlagen: {{.Reason}}`,
+			EventUpdateRecovered: `Die DDNS-Aktualisierung war auf {{.Hostname}} nach {{.Failures}} fehlgeschlagenen ` +
+				`Durchläufen wieder erfolgreich: {{.Reason}}`,
+			EventIPLookupFailed: `Die öffentlichen IP-Adressen konnten auf {{.Hostname}} {{.Failures}}-mal in Folge ` +
+				`nicht ermittelt werden: {{.Reason}}`,
+			EventConfigReloaded: `Die Konfiguration von {{.Hostname}} für ` +
+				`{{plural .DomainCount "die Domain" "die Domains"}} '{{.Domains}}' wurde neu geladen`,
+			EventStartup: `go ddns client wurde auf {{.Hostname}} für ` +
+				`{{plural .DomainCount "die Domain" "die Domains"}} '{{.Domains}}' gestartet`,
+		},
+		messages: map[string]string{
+			EventIPChanged: `Die IP-Adressen für {{plural .DomainCount "die Domain" "die Domains"}} '{{.Domains}}' ` +
+				"wurden aktualisiert auf:\n{{addresses .IPv4 .IPv6}}\nvon: {{.Hostname}}",
+			EventChangeSuppressed: `Eine Änderung der IP-Adressen für {{plural .DomainCount "die Domain" "die Domains"}} ` +
+				"'{{.Domains}}' auf:\n{{addresses .IPv4 .IPv6}}\nwurde nicht veröffentlicht: {{.Reason}}\n" +
+				"von: {{.Hostname}}",
+			EventNATDetected: `Eingehender Zugriff über {{plural .DomainCount "die Domain" "die Domains"}} ` +
+				"'{{.Domains}}' wird wahrscheinlich nicht funktionieren, {{.Reason}}\nvon: {{.Hostname}}",
+		},
+		updated: "aktualisiert",
+		failed:  "fehlgeschlagen",
+	},
+	"fr": {
+		subjects: map[string]string{
+			EventIPChanged:        "go ddns client mise à jour de l'adresse IP",
+			EventChangeSuppressed: "go ddns client changement d'adresse IP ignoré",
+			EventNATDetected:      "go ddns client NAT opérateur ou double NAT détecté",
+			EventUpdateFailed:     "go ddns client échec de la mise à jour",
+			EventUpdateRecovered:  "go ddns client mise à jour rétablie",
+			EventIPLookupFailed:   "go ddns client échec de la recherche de l'adresse IP",
+			EventConfigReloaded:   "go ddns client configuration rechargée",
+			EventStartup:          "go ddns client démarré",
+		},
+		descriptions: map[string]string{
+			EventIPChanged: `Les adresses IP pour {{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}' ` +
+				`ont été mises à jour par {{.Hostname}}`,
+			EventChangeSuppressed: `Un changement des adresses IP pour ` +
+				`{{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}' n'a pas été publié par ` +
+				`{{.Hostname}} : {{.Reason}}`,
+			EventNATDetected: `L'accès entrant via {{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}' ` +
+				`ne fonctionnera probablement pas, {{.Reason}} ({{.Hostname}})`,
+			EventUpdateFailed: `La mise à jour DDNS a échoué {{.Failures}} fois de suite sur {{.Hostname}} : ` +
+				`{{.Reason}}`,
+			EventUpdateRecovered: `La mise à jour DDNS a de nouveau réussi sur {{.Hostname}} après {{.Failures}} ` +
+				`exécutions en échec : {{.Reason}}`,
+			EventIPLookupFailed: `Les adresses IP publiques n'ont pas pu être déterminées {{.Failures}} fois de suite ` +
+				`sur {{.Hostname}} : {{.Reason}}`,
+			EventConfigReloaded: `La configuration de {{.Hostname}} pour ` +
+				`{{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}' a été rechargée`,
+			EventStartup: `go ddns client a démarré sur {{.Hostname}} pour ` +
+				`{{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}'`,
+		},
+		messages: map[string]string{
+			EventIPChanged: `Les adresses IP pour {{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}' ` +
+				"ont été mises à jour vers :\n{{addresses .IPv4 .IPv6}}\npar : {{.Hostname}}",
+			EventChangeSuppressed: `Un changement des adresses IP pour ` +
+				`{{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}' vers :` +
+				"\n{{addresses .IPv4 .IPv6}}\nn'a pas été publié : {{.Reason}}\npar : {{.Hostname}}",
+			EventNATDetected: `L'accès entrant via {{plural .DomainCount "le domaine" "les domaines"}} '{{.Domains}}' ` +
+				"ne fonctionnera probablement pas, {{.Reason}}\npar : {{.Hostname}}",
+		},
+		updated: "mis à jour",
+		failed:  "échec",
+	},
+	"es": {
+		subjects: map[string]string{
+			EventIPChanged:        "go ddns client actualización de la dirección IP",
+			EventChangeSuppressed: "go ddns client cambio de dirección IP suprimido",
+			EventNATDetected:      "go ddns client NAT de operador o doble NAT detectado",
+			EventUpdateFailed:     "go ddns client la actualización falló",
+			EventUpdateRecovered:  "go ddns client actualización recuperada",
+			EventIPLookupFailed:   "go ddns client la consulta de la dirección IP falló",
+			EventConfigReloaded:   "go ddns client configuración recargada",
+			EventStartup:          "go ddns client iniciado",
+		},
+		descriptions: map[string]string{
+			EventIPChanged: `Las direcciones IP para {{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}' ` +
+				`fueron actualizadas por {{.Hostname}}`,
+			EventChangeSuppressed: `Un cambio de las direcciones IP para ` +
+				`{{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}' no fue publicado por ` +
+				`{{.Hostname}}: {{.Reason}}`,
+			EventNATDetected: `Es poco probable que el acceso entrante a través de ` +
+				`{{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}' funcione, {{.Reason}} ({{.Hostname}})`,
+			EventUpdateFailed: `La actualización DDNS falló {{.Failures}} veces seguidas en {{.Hostname}}: {{.Reason}}`,
+			EventUpdateRecovered: `La actualización DDNS volvió a funcionar en {{.Hostname}} después de {{.Failures}} ` +
+				`ejecuciones fallidas: {{.Reason}}`,
+			EventIPLookupFailed: `No se pudieron determinar las direcciones IP públicas {{.Failures}} veces seguidas en ` +
+				`{{.Hostname}}: {{.Reason}}`,
+			EventConfigReloaded: `La configuración de {{.Hostname}} para ` +
+				`{{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}' fue recargada`,
+			EventStartup: `go ddns client se inició en {{.Hostname}} para ` +
+				`{{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}'`,
+		},
+		messages: map[string]string{
+			EventIPChanged: `Las direcciones IP para {{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}' ` +
+				"fueron actualizadas a:\n{{addresses .IPv4 .IPv6}}\npor: {{.Hostname}}",
+			EventChangeSuppressed: `Un cambio de las direcciones IP para ` +
+				`{{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}' a:` +
+				"\n{{addresses .IPv4 .IPv6}}\nno fue publicado: {{.Reason}}\npor: {{.Hostname}}",
+			EventNATDetected: `Es poco probable que el acceso entrante a través de ` +
+				`{{plural .DomainCount "el dominio" "los dominios"}} '{{.Domains}}' funcione, {{.Reason}}` +
+				"\npor: {{.Hostname}}",
+		},
+		updated: "actualizado",
+		failed:  "falló",
+	},
+}
+
+//templateFuncs are the functions available to the message and webhook body templates
+var templateFuncs = map[string]interface{}{
+	"json":      templateJSON,
+	"plural":    templatePlural,
+	"addresses": formatAddressLines,
+	"lines":     templateLines,
+}
+
+//getLocaleMessages returns the built-in messages of the supplied locale such as de or de-DE, the English messages when
+//the locale is not shipped
+func getLocaleMessages(locale string) *localeMessages {
+	locale = strings.ToLower(locale)
+	if messages, ok := locales[locale]; ok {
+		return messages
+	}
+	if index := strings.IndexAny(locale, "-_"); index > 0 {
+		if messages, ok := locales[locale[:index]]; ok {
+			return messages
+		}
+	}
+	return locales[defaultLocale]
+}
+
+//getBuiltinText returns the built-in template of the event type selected by selectTexts in the locale of the event,
+//falling back to the English template. Unknown event types are described as an ip address update and event types
+//without a built-in message use their description and details
+func (event *Event) getBuiltinText(selectTexts func(messages *localeMessages) map[string]string) string {
+	eventType := event.Type
+	if _, ok := locales[defaultLocale].subjects[eventType]; !ok {
+		eventType = EventIPChanged
+	}
+	for _, messages := range []*localeMessages{getLocaleMessages(event.locale), locales[defaultLocale]} {
+		if text, ok := selectTexts(messages)[eventType]; ok {
+			return text
+		}
+	}
+	return detailedMessage
+}
+
+//executeTextTemplate executes the supplied text/template over the event
+func executeTextTemplate(name string, text string, event *Event) (string, error) {
+	textTemplate, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered bytes.Buffer
+	if err = textTemplate.Execute(&rendered, event); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+//executeHTMLTemplate executes the supplied html/template over the event
+func executeHTMLTemplate(name string, text string, event *Event) (string, error) {
+	htmlTemplate, err := htmltemplate.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered bytes.Buffer
+	if err = htmlTemplate.Execute(&rendered, event); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+//getTemplate returns the key and the configured template text of the event type selected by selectText. The templates
+//of the notifier take precedence over the templates of all notifiers and a template of the event type over the
+//default template, an empty text is returned when no template is configured
+func (event *Event) getTemplate(selectText func(messageTemplate config.MessageTemplate) string) (string, string) {
+	if selectText == nil {
+		return "", ""
+	}
+	for _, templates := range event.templates {
+		for _, key := range []string{event.Type, defaultTemplateKey} {
+			if text := selectText(templates[key]); text != "" {
+				return key, text
+			}
+		}
+	}
+	return "", ""
+}
+
+//render returns the configured template of the event executed over the event, or the built-in template of the locale
+//when no template is configured or selectText is nil. A failing configured template is logged and the built-in
+//template is used instead
+func (event *Event) render(
+	part string,
+	selectText func(messageTemplate config.MessageTemplate) string,
+	builtin func(messages *localeMessages) map[string]string) string {
+
+	if key, text := event.getTemplate(selectText); text != "" {
+		rendered, err := executeTextTemplate(part, text, event)
+		if err == nil {
+			return rendered
+		}
+		log.Printf("the %s %s template failed, the built-in %s is used: %v", key, part, part, err)
+	}
+
+	text := event.getBuiltinText(builtin)
+	rendered, err := executeTextTemplate(part, text, event)
+	if err != nil {
+		log.Printf("the built-in %s %s template failed: %v", event.Type, part, err)
+	}
+	return rendered
+}
+
+//templatePlural is the plural template function, it returns one when count is 1 or less and many otherwise
+func templatePlural(count int, one string, many string) string {
+	if count > 1 {
+		return many
+	}
+	return one
+}
+
+//templateLines is the lines template function, it returns the lines of the supplied text
+func templateLines(text string) []string {
+	return strings.Split(text, "\n")
+}
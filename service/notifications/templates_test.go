@@ -0,0 +1,213 @@
+package notifications
+
+import (
+	"encoding/json"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"strings"
+	"testing"
+)
+
+//templateTestNotifier is a stub notifier recording the subject, message and description of the events it is sent
+type templateTestNotifier struct {
+	sent *[]string
+}
+
+//Send records the rendered parts of the event
+func (notifier templateTestNotifier) Send(event *Event) error {
+	*notifier.sent = append(*notifier.sent, event.Subject()+"|"+event.Message()+"|"+event.Description())
+	return nil
+}
+
+//templateTestEvent returns an ip_changed event of a single domain with both address families
+func templateTestEvent(locale string) *Event {
+	return &Event{
+		Type:        EventIPChanged,
+		Hostname:    "host",
+		DomainCount: 1,
+		Domains:     "home.example.com",
+		IPv4:        "81.2.69.142",
+		IPv6:        "2a00:1450:4009:81d::200e",
+		locale:      locale,
+	}
+}
+
+func TestBuiltinLocales(t *testing.T) {
+	tests := []struct {
+		locale  string
+		subject string
+		message string
+	}{
+		{"en", "go ddns client ip address update",
+			"The IP addresses for domain 'home.example.com' were updated to:\n81.2.69.142\n2a00:1450:4009:81d::200e\n" +
+				"by: host"},
+		{"de", "go ddns client IP-Adressaktualisierung",
+			"Die IP-Adressen für die Domain 'home.example.com' wurden aktualisiert auf:\n81.2.69.142\n" +
+				"2a00:1450:4009:81d::200e\nvon: host"},
+		{"fr", "go ddns client mise à jour de l'adresse IP",
+			"Les adresses IP pour le domaine 'home.example.com' ont été mises à jour vers :\n81.2.69.142\n" +
+				"2a00:1450:4009:81d::200e\npar : host"},
+		{"es", "go ddns client actualización de la dirección IP",
+			"Las direcciones IP para el dominio 'home.example.com' fueron actualizadas a:\n81.2.69.142\n" +
+				"2a00:1450:4009:81d::200e\npor: host"},
+		{"de-DE", "go ddns client IP-Adressaktualisierung",
+			"Die IP-Adressen für die Domain 'home.example.com' wurden aktualisiert auf:\n81.2.69.142\n" +
+				"2a00:1450:4009:81d::200e\nvon: host"},
+		{"it", "go ddns client ip address update",
+			"The IP addresses for domain 'home.example.com' were updated to:\n81.2.69.142\n2a00:1450:4009:81d::200e\n" +
+				"by: host"},
+		{"", "go ddns client ip address update",
+			"The IP addresses for domain 'home.example.com' were updated to:\n81.2.69.142\n2a00:1450:4009:81d::200e\n" +
+				"by: host"},
+	}
+	for _, test := range tests {
+		t.Run(test.locale, func(t *testing.T) {
+			event := templateTestEvent(test.locale)
+			if subject := event.Subject(); subject != test.subject {
+				t.Errorf("expected the subject %q, got %q", test.subject, subject)
+			}
+			if message := event.Message(); message != test.message {
+				t.Errorf("expected the message %q, got %q", test.message, message)
+			}
+		})
+	}
+}
+
+func TestBuiltinLocalesCoverAllEventTypes(t *testing.T) {
+	eventTypes := []string{EventIPChanged, EventChangeSuppressed, EventNATDetected, EventUpdateFailed,
+		EventUpdateRecovered, EventIPLookupFailed, EventConfigReloaded, EventStartup}
+	for locale, messages := range locales {
+		for _, eventType := range eventTypes {
+			if messages.subjects[eventType] == "" || messages.descriptions[eventType] == "" {
+				t.Errorf("the %s locale has no subject or description of %s", locale, eventType)
+			}
+			event := &Event{Type: eventType, Hostname: "host", DomainCount: 2, Domains: "a.example.com, b.example.com",
+				Reason: "reason", Failures: 3, locale: locale}
+			for part, rendered := range map[string]string{
+				"subject":     event.Subject(),
+				"message":     event.Message(),
+				"description": event.Description(),
+			} {
+				if rendered == "" || strings.Contains(rendered, "{{") || strings.Contains(rendered, "<no value>") {
+					t.Errorf("the %s %s %s rendered as %q", locale, eventType, part, rendered)
+				}
+			}
+		}
+	}
+}
+
+func TestBuiltinMessageFallbacks(t *testing.T) {
+	failed := &Event{Type: EventUpdateFailed, Hostname: "host", Reason: "timeout", Failures: 3, locale: "de",
+		Services: []ServiceResult{{ServiceType: "DuckDNS", Domain: "home.example.com", Error: "timeout"}}}
+	expected := "Die DDNS-Aktualisierung ist auf host 3-mal in Folge fehlgeschlagen: timeout\n" +
+		"home.example.com (DuckDNS): fehlgeschlagen: timeout"
+	if message := failed.Message(); message != expected {
+		t.Errorf("expected the description and details %q, got %q", expected, message)
+	}
+
+	unknown := templateTestEvent("fr")
+	unknown.Type = "unknown"
+	if subject := unknown.Subject(); subject != "go ddns client mise à jour de l'adresse IP" {
+		t.Errorf("expected an unknown event type to be described as an ip address update, got %q", subject)
+	}
+}
+
+func TestManagerTemplatePrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		notifier  map[string]config.MessageTemplate
+		all       map[string]config.MessageTemplate
+		eventType string
+		expected  string
+	}{
+		{"built-in without templates", nil, nil, EventIPChanged,
+			"go ddns client ip address update|The IP addresses for domain 'home.example.com' were updated to:\n" +
+				"81.2.69.142\n2a00:1450:4009:81d::200e\nby: host|The IP addresses for domain 'home.example.com' were " +
+				"updated by host"},
+		{"notifier event type over notifier default", map[string]config.MessageTemplate{
+			EventIPChanged:     {Subject: "changed {{.IPv4}}", Body: "body {{.Hostname}}"},
+			defaultTemplateKey: {Subject: "default", Body: "default"},
+		}, nil, EventIPChanged, "changed 81.2.69.142|body host|body host"},
+		{"notifier default over all notifiers event type", map[string]config.MessageTemplate{
+			defaultTemplateKey: {Body: "notifier default {{.Type}}"},
+		}, map[string]config.MessageTemplate{
+			EventStartup: {Subject: "all startup", Body: "all startup"},
+		}, EventStartup, "all startup|notifier default startup|notifier default startup"},
+		{"all notifiers default", nil, map[string]config.MessageTemplate{
+			defaultTemplateKey: {Subject: "{{.Type}} on {{.Hostname}}"},
+		}, EventStartup, "startup on host|go ddns client started on host for domain 'home.example.com'\n" +
+			"IPv4: 81.2.69.142\nIPv6: 2a00:1450:4009:81d::200e|go ddns client started on host for domain " +
+			"'home.example.com'"},
+		{"failing template falls back to the built-in", map[string]config.MessageTemplate{
+			EventStartup: {Subject: "{{.Missing}}", Body: "{{"},
+		}, nil, EventStartup, "go ddns client started|go ddns client started on host for domain 'home.example.com'\n" +
+			"IPv4: 81.2.69.142\nIPv6: 2a00:1450:4009:81d::200e|go ddns client started on host for domain " +
+			"'home.example.com'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent []string
+			manager := Manager{
+				Subscriptions: []Subscription{{Notifier: templateTestNotifier{sent: &sent}, Templates: test.notifier}},
+				Templates:     test.all,
+			}
+			event := templateTestEvent("")
+			event.Type = test.eventType
+			if err := manager.Send(event); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent) != 1 || sent[0] != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, sent)
+			}
+		})
+	}
+}
+
+func TestGetManagerPassesTheTemplatesOfEveryNotifier(t *testing.T) {
+	templates := map[string]config.MessageTemplate{defaultTemplateKey: {Body: "{{.Hostname}}"}}
+	conf := config.Notifications{
+		SipgateSMS: config.SipgateSMS{Enabled: true, Templates: templates},
+		Email:      config.Email{IsEnabled: true, Templates: templates},
+		Webhooks:   []config.Webhook{{Enabled: true, Templates: templates}},
+		Slack:      config.Slack{Enabled: true, Templates: templates},
+		Discord:    config.Discord{Enabled: true, Templates: templates},
+		Teams:      config.Teams{Enabled: true, Templates: templates},
+		Mattermost: config.Mattermost{Enabled: true, Templates: templates},
+		Telegram:   config.Telegram{Enabled: true, Templates: templates},
+		Ntfy:       config.Ntfy{Enabled: true, Templates: templates},
+		Gotify:     config.Gotify{Enabled: true, Templates: templates},
+		Pushover:   config.Pushover{Enabled: true, Templates: templates},
+		Matrix:     config.Matrix{Enabled: true, Templates: templates},
+		MQTT:       config.MQTT{Enabled: true, Templates: templates},
+	}
+	manager := GetManager(&conf).(*Manager)
+	if len(manager.Subscriptions) != 13 {
+		t.Fatalf("expected 13 notifiers, got %d", len(manager.Subscriptions))
+	}
+	for _, subscription := range manager.Subscriptions {
+		if len(subscription.Templates) == 0 {
+			t.Errorf("the %T notifier was subscribed without its templates", subscription.Notifier)
+		}
+	}
+}
+
+func TestSipgateSMSBodyEscapesTheMessage(t *testing.T) {
+	notifier := SipGateSmsNotifier{conf: &config.SipgateSMS{SmsId: "s0", Recipient: "+4915799912345"}}
+	event := templateTestEvent("")
+	event.Domains = `home.example.com", "recipient": "+0000`
+	event.templates = []map[string]config.MessageTemplate{{
+		EventIPChanged: {Body: "{{.Domains}}\n\t\\ {{.IPv4}} <&>"},
+	}}
+
+	body, err := notifier.buildBody(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]string
+	if err = json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("expected a valid json body, got %s: %v", body, err)
+	}
+	expected := "home.example.com\", \"recipient\": \"+0000\n\t\\ 81.2.69.142 <&>"
+	if len(payload) != 3 || payload["recipient"] != "+4915799912345" || payload["message"] != expected {
+		t.Errorf("expected the recipient +4915799912345 and message %q, got %v", expected, payload)
+	}
+}
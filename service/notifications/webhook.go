@@ -0,0 +1,166 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// the webhook defaults applied when the configuration leaves them empty
+const (
+	defaultWebhookSignatureHeader = "X-Signature-256"
+	defaultWebhookRetryDelay      = 2 * time.Second
+)
+
+/*
+WebhookNotifier implements a generic webhook that sends the event to an arbitrary url, for incident tooling and home
+automation systems
+
+The request body is the Body text/template executed over the Event, or the json of the Event with its subject and
+message when no Body is configured. The subject and message are rendered with the configured message templates. Besides
+the Event fields and its Subject and Message methods the template may use the json function to json encode a value, and
+the plural, addresses and lines functions of the message templates:
+
+	{"text": {{json .Message}}, "host": {{json .Hostname}}, "ipv4": {{json .IPv4}}}
+
+sample json body:
+	{
+		"type": "ip_changed",
+		"hostname": "host",
+		"domainCount": 1,
+		"domains": "example.com",
+		"ipv4": "255.255.255.255",
+		"oldIPv4": "255.255.255.254",
+		"services": [
+			{
+				"serviceType": "Cloudflare",
+				"domain": "example.com",
+				"ipv4": "255.255.255.255",
+				"success": true,
+				"timestamp": "2021-01-01T00:00:00Z"
+			}
+		],
+		"timestamp": "2021-01-01T00:00:00Z",
+		"subject": "go ddns client ip address update",
+		"message": "The IP addresses for domain 'example.com' were updated to:\n255.255.255.255\nby: host"
+	}
+
+When a Secret is configured the request carries the hex HMAC-SHA256 of the body in the signature header as
+sha256=<hex>. Failed requests, network errors and 429 or 5xx responses, are retried with a doubling delay.
+*/
+type WebhookNotifier struct {
+	conf *config.Webhook
+}
+
+//webhookPayload is the default webhook body, the json of the event with its rendered subject and message
+type webhookPayload struct {
+	*Event
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+}
+
+//Send sends the webhook notification
+func (notifier WebhookNotifier) Send(event *Event) error {
+	body, err := notifier.buildBody(event)
+	if err != nil {
+		return notifier.webhookError(err)
+	}
+
+	method := notifier.conf.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for key, value := range notifier.conf.Headers {
+		headers[key] = value
+	}
+	if notifier.conf.Secret != "" {
+		signatureHeader := notifier.conf.SignatureHeader
+		if signatureHeader == "" {
+			signatureHeader = defaultWebhookSignatureHeader
+		}
+		headers[signatureHeader] = signWebhookBody(notifier.conf.Secret, body)
+	}
+
+	retryDelay := defaultWebhookRetryDelay
+	if notifier.conf.RetryDelay != "" {
+		if retryDelay, err = time.ParseDuration(notifier.conf.RetryDelay); err != nil {
+			return notifier.webhookError(err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var statusCode int
+		var retry bool
+		statusCode, _, err = PerformHttpRequest(method, notifier.conf.Url, "", "", bytes.NewReader(body), headers)
+		switch {
+		case err != nil:
+			retry = true
+		case statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+			err = fmt.Errorf("unexpected status code %d", statusCode)
+			retry = true
+		case statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices:
+			err = fmt.Errorf("unexpected status code %d", statusCode)
+		}
+		if err == nil {
+			log.Printf("Webhook notification sent to %s", notifier.conf.Url)
+			return nil
+		}
+		if !retry || attempt >= notifier.conf.Retries {
+			return notifier.webhookError(err)
+		}
+		log.Printf("Webhook notification to %s failed, retrying in %s: %v", notifier.conf.Url, retryDelay, err)
+		time.Sleep(retryDelay)
+		retryDelay *= 2
+	}
+}
+
+//buildBody returns the request body, the executed body template or the json of the event
+func (notifier WebhookNotifier) buildBody(event *Event) ([]byte, error) {
+	if notifier.conf.Url == "" {
+		return nil, errors.New("the webhook requires a url")
+	}
+	if notifier.conf.Body == "" {
+		return json.Marshal(webhookPayload{Event: event, Subject: event.Subject(), Message: event.Message()})
+	}
+
+	bodyTemplate, err := template.New("body").Funcs(templateFuncs).Parse(notifier.conf.Body)
+	if err != nil {
+		return nil, err
+	}
+	var body bytes.Buffer
+	if err = bodyTemplate.Execute(&body, event); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func (notifier WebhookNotifier) webhookError(err error) error {
+	return fmt.Errorf("webhook %s error: %v", notifier.conf.Url, err)
+}
+
+//signWebhookBody returns the sha256=<hex> HMAC-SHA256 signature of the body
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//templateJSON is the json template function, it returns the json encoding of the supplied value
+func templateJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(encoded)), nil
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/bebo-dot-dev/go-ddns-client/service/config"
 	"github.com/bebo-dot-dev/go-ddns-client/service/ddns"
 	"github.com/bebo-dot-dev/go-ddns-client/service/ipaddress"
@@ -10,30 +12,50 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
-//StartServer starts a http server on port 8080 to serve up the current ipv4 and ipv6 ip addresses
+// the IP address family modes
+const (
+	familyRequired = "required"
+	familyOptional = "optional"
+	familyDisabled = "disabled"
+)
+
+//addressLookup is the result of a single IP address provider lookup
+type addressLookup struct {
+	provider ipaddress.IAddressProvider
+	ipv4     net.IP
+	ipv6     net.IP
+	err      error
+}
+
+//StartServer starts a http server on the configured port to serve up the current ipv4 and ipv6 ip addresses and,
+//when enabled, to accept DynDNS2 update requests from other devices
 func StartServer(cfg *config.Configuration) {
 	ipv4Handler := func(w http.ResponseWriter, req *http.Request) {
-		_, err := io.WriteString(w, cfg.LastIPv4.String())
+		_, err := io.WriteString(w, formatAddress(cfg.LastIPv4))
 		if err != nil {
 			log.Printf("io.WriteString error in ipv4Handler: %v", err)
 		}
 	}
 	ipv6Handler := func(w http.ResponseWriter, req *http.Request) {
-		_, err := io.WriteString(w, cfg.LastIPv6.String())
+		_, err := io.WriteString(w, formatAddress(cfg.LastIPv6))
 		if err != nil {
 			log.Printf("io.WriteString error in ipv6Handler: %v", err)
 		}
 	}
 	jsonHandler := func(w http.ResponseWriter, req *http.Request) {
 		response := struct {
-			Hostname  string `json:"hostname"`
-			Ipv4      net.IP `json:"ipv4"`
-			Ipv6      net.IP `json:"ipv6"`
-			Timestamp string `json:"timestamp"`
-		}{cfg.Hostname, cfg.LastIPv4, cfg.LastIPv6, time.Now().Format(time.RFC3339)}
+			Hostname    string                 `json:"hostname"`
+			Ipv4        net.IP                 `json:"ipv4"`
+			Ipv6        net.IP                 `json:"ipv6"`
+			Timestamp   string                 `json:"timestamp"`
+			NATWarning  *natWarning            `json:"natWarning,omitempty"`
+			Diagnostics []ipaddress.Diagnostic `json:"diagnostics,omitempty"`
+		}{cfg.Hostname, cfg.LastIPv4, cfg.LastIPv6, time.Now().Format(time.RFC3339), getNATWarning(),
+			ipaddress.GetDiagnostics()}
 		w.Header().Set("Content-Type", "application/json")
 		encoder := json.NewEncoder(w)
 		encoder.SetIndent("", "    ")
@@ -46,6 +68,7 @@ func StartServer(cfg *config.Configuration) {
 	http.HandleFunc("/ipv4", ipv4Handler)
 	http.HandleFunc("/ipv6", ipv6Handler)
 	http.HandleFunc("/json", jsonHandler)
+	http.HandleFunc("/nic/update", dynDNSUpdateHandler(cfg))
 	log.Fatal(http.ListenAndServe(":"+cfg.ServerPort, nil))
 }
 
@@ -53,33 +76,67 @@ func StartServer(cfg *config.Configuration) {
 func PerformDDNSActions(cfg *config.Configuration) error {
 	var err error
 
-	if cfg.Services == nil {
+	services := cfg.GetLocalServices()
+	if services == nil {
 		log.Println("no DDNS services configured, nothing to do")
 		return nil
 	}
 
-	ipAddrProvider := getIpAddressProvider(&cfg.Router)
-	ipv4, ipv6, err := ipAddrProvider.GetPublicIPAddresses()
+	ipv4, ipv6, ipv6Provider, err := getPublicIPAddresses(cfg)
 	if err != nil {
+		notifyFailure(cfg, failureKeyIPLookup, &notifications.Event{
+			Type:   notifications.EventIPLookupFailed,
+			Reason: err.Error(),
+		})
 		return err
 	}
+	notifyRecovery(cfg, failureKeyIPLookup, "the public IP addresses were determined")
 
-	if cfg.IPAddressesChanged(ipv4, ipv6) {
-		for _, serviceConfig := range cfg.Services {
-			ddnsClient := getDDNSClient(&serviceConfig)
-			if ddnsClient != nil {
-				if err = ddnsClient.UpdateIPAddresses(ipv4, ipv6); err != nil {
-					break
-				}
-			}
+	ipv4 = detectNAT(cfg, ipv4)
+
+	ipv4, ipv6, suppressed, err := validateAddresses(cfg, ipv4, ipv6)
+	if err != nil {
+		return err
+	}
+	if err = sendSuppressedNotifications(cfg, suppressed); err != nil {
+		log.Println(err)
+	}
+
+	var prefix *net.IPNet
+	if cfg.UsesIPv6Prefix() {
+		prefix = getIPv6Prefix(cfg, ipv6Provider, ipv6)
+	}
+
+	if cfg.IPAddressesChanged(ipv4, ipv6) || cfg.IPv6PrefixChanged(prefix) {
+		var results []notifications.ServiceResult
+		if results, err = updateServices(cfg, services, ipv4, ipv6, prefix); err != nil {
+			return err
 		}
-		if err == nil {
-			if err = cfg.Save(ipv4, ipv6); err != nil {
-				return err
-			}
-			if err = sendNotifications(cfg, ipv4, ipv6); err != nil {
-				return err
-			}
+
+		oldIPv4, oldIPv6 := cfg.LastIPv4, cfg.LastIPv6
+		cfg.LastIPv6Prefix = ""
+		if prefix != nil {
+			cfg.LastIPv6Prefix = prefix.String()
+		}
+		if err = cfg.Save(ipv4, ipv6); err != nil {
+			notifyFailure(cfg, failureKeySave, &notifications.Event{
+				Type:   notifications.EventUpdateFailed,
+				Reason: fmt.Sprintf("the configuration file could not be saved: %v", err),
+			})
+			return err
+		}
+		notifyRecovery(cfg, failureKeySave, "the configuration file was saved")
+
+		err = sendNotifications(cfg, &notifications.Event{
+			Type:     notifications.EventIPChanged,
+			IPv4:     formatAddress(ipv4),
+			IPv6:     formatAddress(ipv6),
+			OldIPv4:  formatAddress(oldIPv4),
+			OldIPv6:  formatAddress(oldIPv6),
+			Services: results,
+		})
+		if err != nil {
+			return err
 		}
 	} else {
 		log.Printf("IPv4 address %s and IPv6 %s remain unchanged, no DDNS updates performed", ipv4, ipv6)
@@ -88,18 +145,422 @@ func PerformDDNSActions(cfg *config.Configuration) error {
 	return err
 }
 
+//updateServices updates every DDNS service and returns the service results. A failing service does not stop the
+//other services from being updated, the errors of all failing services are returned together. A failure is notified
+//once it reaches the failure threshold and its recovery is notified, one event covers all services of a run
+func updateServices(
+	cfg *config.Configuration,
+	services []config.ServiceConfiguration,
+	ipv4 net.IP,
+	ipv6 net.IP,
+	prefix *net.IPNet) ([]notifications.ServiceResult, error) {
+
+	policy := getFailurePolicy(&cfg.Notifications)
+	var results, failed, recovered []notifications.ServiceResult
+	var errs, failedErrs []string
+	failedCount, recoveredCount := 0, 0
+	for index := range services {
+		serviceConfig := &services[index]
+		ddnsClient := getDDNSClient(serviceConfig)
+		if ddnsClient == nil {
+			continue
+		}
+		serviceIPv6, err := getServiceIPv6(serviceConfig, ipv6, prefix)
+		if err == nil {
+			err = ddnsClient.UpdateIPAddresses(ipv4, serviceIPv6)
+		}
+		result := newServiceResult(serviceConfig, ipv4, serviceIPv6, err)
+		results = append(results, result)
+
+		key := getServiceFailureKey(serviceConfig)
+		if err != nil {
+			errs = append(errs, err.Error())
+			if count, due := recordFailure(key, policy); due {
+				failed = append(failed, result)
+				failedErrs = append(failedErrs, err.Error())
+				if count > failedCount {
+					failedCount = count
+				}
+			}
+		} else if count, notified := recordSuccess(key); notified {
+			recovered = append(recovered, result)
+			if count > recoveredCount {
+				recoveredCount = count
+			}
+		}
+	}
+
+	if len(recovered) > 0 {
+		event := &notifications.Event{
+			Type:     notifications.EventUpdateRecovered,
+			Failures: recoveredCount,
+			Reason:   "the DDNS services were updated",
+			Services: recovered,
+		}
+		if err := sendNotifications(cfg, event); err != nil {
+			log.Println(err)
+		}
+	}
+	if len(failed) > 0 {
+		event := &notifications.Event{
+			Type:     notifications.EventUpdateFailed,
+			IPv4:     formatAddress(ipv4),
+			IPv6:     formatAddress(ipv6),
+			Failures: failedCount,
+			Reason:   strings.Join(failedErrs, "; "),
+			Services: failed,
+		}
+		if err := sendNotifications(cfg, event); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, errors.New(strings.Join(errs, "; "))
+	}
+	return results, nil
+}
+
 //getIpAddressProvider returns an ipaddress.IAddressProvider for the supplied routerConfig *config.RouterConfiguration
-func getIpAddressProvider(routerConfig *config.RouterConfiguration) ipaddress.IAddressProvider {
-	var ipAddressProvider ipaddress.IAddressProvider
-	if routerConfig != nil && routerConfig.RouterType != "" {
-		switch routerConfig.RouterType {
-		case "BTSmartHub2":
-			ipAddressProvider = &ipaddress.BTSmartHub2{Config: routerConfig}
+func getIpAddressProvider(routerConfig *config.RouterConfiguration) (ipaddress.IAddressProvider, error) {
+	if routerConfig == nil || routerConfig.RouterType == "" {
+		return &ipaddress.Default{}, nil
+	}
+	switch routerConfig.RouterType {
+	case "BTSmartHub2":
+		return &ipaddress.BTSmartHub2{Config: routerConfig}, nil
+	case "FritzBox":
+		return &ipaddress.FritzBox{Config: routerConfig}, nil
+	case "OpenWrt":
+		return &ipaddress.OpenWrt{Config: routerConfig}, nil
+	case "OPNsense":
+		return &ipaddress.OPNsense{Config: routerConfig}, nil
+	case "PfSense":
+		return &ipaddress.PfSense{Config: routerConfig}, nil
+	case "MikroTik":
+		return &ipaddress.MikroTik{Config: routerConfig}, nil
+	default:
+		return nil, fmt.Errorf("unsupported routerType %s", routerConfig.RouterType)
+	}
+}
+
+//getPublicIPAddresses resolves the public IPv4 and IPv6 addresses using the source chain of each address family and
+//returns them with the provider of the IPv6 address. A disabled family is not resolved, a missing optional family is
+//logged and a missing required family is an error
+func getPublicIPAddresses(cfg *config.Configuration) (net.IP, net.IP, ipaddress.IAddressProvider, error) {
+	ipv4Mode, err := getFamilyMode(cfg.IPv4, familyRequired)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ipv6Mode, err := getFamilyMode(cfg.IPv6, familyOptional)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ipv4Mode == familyDisabled && ipv6Mode == familyDisabled {
+		return nil, nil, nil, errors.New("the IPv4 and IPv6 address families are both disabled")
+	}
+
+	var shared *addressLookup
+	var ipv4, ipv6 net.IP
+	var ipv6Provider ipaddress.IAddressProvider
+	if ipv4Mode != familyDisabled {
+		lookup := lookupFamily(cfg, cfg.IPv4, ipaddress.FamilyIPv4, &shared)
+		if ipv4, err = getFamilyAddress(lookup.ipv4, lookup.err, "IPv4", ipv4Mode); err != nil {
+			return nil, nil, nil, err
 		}
-	} else {
-		ipAddressProvider = &ipaddress.Default{}
 	}
-	return ipAddressProvider
+	if ipv6Mode != familyDisabled {
+		lookup := lookupFamily(cfg, cfg.IPv6, ipaddress.FamilyIPv6, &shared)
+		if ipv6, err = getFamilyAddress(lookup.ipv6, lookup.err, "IPv6", ipv6Mode); err != nil {
+			return nil, nil, nil, err
+		}
+		ipv6Provider = lookup.provider
+	}
+	if ipv4 == nil && ipv6 == nil {
+		return nil, nil, nil, errors.New("no public IP address could be determined")
+	}
+	return ipv4, ipv6, ipv6Provider, nil
+}
+
+//getIPv6Prefix returns the delegated IPv6 prefix reported by the IPv6 provider or derived from the public IPv6 address,
+//nil is returned when there is no IPv6 prefix
+func getIPv6Prefix(cfg *config.Configuration, provider ipaddress.IAddressProvider, ipv6 net.IP) *net.IPNet {
+	prefixLength := 0
+	if cfg.IPv6 != nil {
+		prefixLength = cfg.IPv6.PrefixLength
+	}
+	prefix, err := ipaddress.GetIPv6Prefix(provider, ipv6, prefixLength)
+	if err != nil {
+		log.Printf("The IPv6 prefix could not be determined, IPv6 suffix services are updated without IPv6: %v", err)
+		return nil
+	}
+	log.Printf("The IPv6 prefix is %s", prefix)
+	return prefix
+}
+
+//getServiceIPv6 returns the IPv6 address of a service, the public IPv6 address or, for services with an IPv6 suffix or
+//MAC address, the address made of the IPv6 prefix and the interface ID of the service
+func getServiceIPv6(serviceConfig *config.ServiceConfiguration, ipv6 net.IP, prefix *net.IPNet) (net.IP, error) {
+	if !serviceConfig.UsesIPv6Prefix() {
+		return ipv6, nil
+	}
+	interfaceID, err := ipaddress.ParseInterfaceID(serviceConfig.IPv6Suffix, serviceConfig.MacAddress)
+	if err != nil {
+		return nil, fmt.Errorf("the %s service for domain %s has an invalid interface ID: %v",
+			serviceConfig.ServiceType, serviceConfig.TargetDomain, err)
+	}
+	if prefix == nil {
+		return nil, nil
+	}
+	return ipaddress.CombineIPv6Prefix(prefix, interfaceID), nil
+}
+
+//getFamilyMode returns the configured mode of an address family or defaultMode when none is configured
+func getFamilyMode(family *config.AddressFamily, defaultMode string) (string, error) {
+	if family == nil || family.Mode == "" {
+		return defaultMode, nil
+	}
+	switch family.Mode {
+	case familyRequired, familyOptional, familyDisabled:
+		return family.Mode, nil
+	default:
+		return "", fmt.Errorf("unsupported IP address family mode %s", family.Mode)
+	}
+}
+
+//lookupFamily queries the source chain of an address family. Families without their own ipSources use the shared
+//source chain, which is only queried once per run and remembered in shared
+func lookupFamily(
+	cfg *config.Configuration,
+	familyConfig *config.AddressFamily,
+	family string,
+	shared **addressLookup) *addressLookup {
+
+	if familyConfig != nil && familyConfig.IPSources != nil && len(familyConfig.IPSources.Sources) > 0 {
+		provider, err := getIpSourcesProvider(familyConfig.IPSources, &cfg.Router, family)
+		if err != nil {
+			return &addressLookup{err: err}
+		}
+		ipv4, ipv6, err := provider.GetPublicIPAddresses()
+		return &addressLookup{provider: provider, ipv4: ipv4, ipv6: ipv6, err: err}
+	}
+
+	if *shared == nil {
+		provider, err := getIpSourcesProvider(cfg.IPSources, &cfg.Router, "")
+		if err != nil {
+			*shared = &addressLookup{err: err}
+		} else {
+			ipv4, ipv6, err := provider.GetPublicIPAddresses()
+			*shared = &addressLookup{provider: provider, ipv4: ipv4, ipv6: ipv6, err: err}
+		}
+	}
+	return *shared
+}
+
+//getFamilyAddress applies the family mode to the result of a lookup. A missing required address is an error, a missing
+//optional address is logged and nil is returned
+func getFamilyAddress(ip net.IP, err error, familyName string, mode string) (net.IP, error) {
+	if err == nil && ip != nil {
+		return ip, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("no public %s address was found", familyName)
+	}
+	if mode == familyRequired {
+		return nil, fmt.Errorf("the required %s address could not be determined: %v", familyName, err)
+	}
+	log.Printf("The optional %s address could not be determined, continuing without it: %v", familyName, err)
+	return nil, nil
+}
+
+//getIpSourcesProvider returns an ipaddress.IAddressProvider for the supplied ipSources. The provider returned by
+//getIpAddressProvider for the router section is used when no IP sources are configured. A family specific source
+//chain only accepts results that hold an address of that family
+func getIpSourcesProvider(
+	ipSources *config.IPSources,
+	routerConfig *config.RouterConfiguration,
+	family string) (ipaddress.IAddressProvider, error) {
+
+	if ipSources == nil || len(ipSources.Sources) == 0 {
+		return getIpAddressProvider(routerConfig)
+	}
+
+	var providers []ipaddress.IAddressProvider
+	for index := range ipSources.Sources {
+		provider, err := getIpSourceProvider(&ipSources.Sources[index], routerConfig)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+
+	switch ipSources.Mode {
+	case "", "fallback":
+		return &ipaddress.Fallback{Providers: providers, Family: family}, nil
+	case "quorum":
+		required := ipSources.Quorum
+		if required == 0 {
+			//a simple majority
+			required = len(providers)/2 + 1
+		}
+		var timeout time.Duration
+		if ipSources.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(ipSources.Timeout); err != nil {
+				return nil, err
+			}
+		}
+		return &ipaddress.Quorum{Providers: providers, Required: required, Timeout: timeout, Family: family}, nil
+	default:
+		return nil, fmt.Errorf("unsupported IP sources mode %s", ipSources.Mode)
+	}
+}
+
+//getIpSourceProvider returns the ipaddress.IAddressProvider for the supplied source *config.IPSource
+func getIpSourceProvider(
+	source *config.IPSource,
+	routerConfig *config.RouterConfiguration) (ipaddress.IAddressProvider, error) {
+
+	switch source.SourceType {
+	case "Default":
+		return &ipaddress.Default{}, nil
+	case "Router":
+		if source.Router != nil {
+			routerConfig = source.Router
+		}
+		if routerConfig.RouterType == "" {
+			return nil, errors.New("the Router IP address source requires a configured routerType")
+		}
+		return getIpAddressProvider(routerConfig)
+	case "HTTP":
+		return getHTTPSource(source)
+	case "DNS":
+		timeout, err := parseSourceTimeout(source)
+		if err != nil {
+			return nil, err
+		}
+		return &ipaddress.DNSSource{
+			Preset:    source.Preset,
+			Resolvers: source.Resolvers,
+			Family:    source.Family,
+			Timeout:   timeout,
+		}, nil
+	case "STUN":
+		timeout, err := parseSourceTimeout(source)
+		if err != nil {
+			return nil, err
+		}
+		return &ipaddress.STUNSource{Servers: source.Servers, Family: source.Family, Timeout: timeout}, nil
+	case "UPnP":
+		timeout, err := parseSourceTimeout(source)
+		if err != nil {
+			return nil, err
+		}
+		return &ipaddress.UPnPSource{SearchAddress: source.Address, Location: source.Url, Timeout: timeout}, nil
+	case "NATPMP":
+		timeout, err := parseSourceTimeout(source)
+		if err != nil {
+			return nil, err
+		}
+		return &ipaddress.NATPMPSource{Gateway: source.Address, Protocol: source.Protocol, Timeout: timeout}, nil
+	case "Command":
+		timeout, err := parseSourceTimeout(source)
+		if err != nil {
+			return nil, err
+		}
+		return &ipaddress.CommandSource{
+			Command: source.Command,
+			Args:    source.Args,
+			Env:     source.Env,
+			Format:  source.Format,
+			Family:  source.Family,
+			Timeout: timeout,
+		}, nil
+	case "SNMP":
+		timeout, err := parseSourceTimeout(source)
+		if err != nil {
+			return nil, err
+		}
+		snmpSource := &ipaddress.SNMPSource{
+			Address:   source.Address,
+			Interface: source.Interface,
+			Family:    source.Family,
+			Timeout:   timeout,
+		}
+		if source.SNMP != nil {
+			snmpSource.Version = source.SNMP.Version
+			snmpSource.Community = source.SNMP.Community
+			snmpSource.Username = source.SNMP.Username
+			snmpSource.AuthProtocol = source.SNMP.AuthProtocol
+			snmpSource.AuthPassword = source.SNMP.AuthPassword
+			snmpSource.PrivProtocol = source.SNMP.PrivProtocol
+			snmpSource.PrivPassword = source.SNMP.PrivPassword
+		}
+		return snmpSource, nil
+	case "Interface":
+		return &ipaddress.InterfaceSource{
+			Interface: source.Interface,
+			Family:    source.Family,
+			Prefer:    source.Prefer,
+			Exclude:   source.Exclude,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported IP address sourceType %s", source.SourceType)
+	}
+}
+
+//getHTTPSource returns the ipaddress.HTTPSource described by the supplied source *config.IPSource. The built-in preset
+//named by source.Preset is used as the starting point and any other configured values override it
+func getHTTPSource(source *config.IPSource) (*ipaddress.HTTPSource, error) {
+	var httpSource ipaddress.HTTPSource
+	if source.Preset != "" {
+		preset, ok := ipaddress.HTTPSourcePresets[source.Preset]
+		if !ok {
+			return nil, fmt.Errorf("unknown HTTP IP address source preset %s", source.Preset)
+		}
+		httpSource = preset
+	}
+
+	if source.Url != "" {
+		httpSource.URL = source.Url
+	}
+	if source.Family != "" {
+		httpSource.Family = source.Family
+	}
+	if source.ForceFamily {
+		httpSource.ForceFamily = true
+	}
+	if source.Extractor != "" {
+		httpSource.Extractor = source.Extractor
+	}
+	if source.Expression != "" {
+		httpSource.Expression = source.Expression
+	}
+	timeout, err := parseSourceTimeout(source)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		httpSource.Timeout = timeout
+	}
+
+	if httpSource.URL == "" {
+		return nil, errors.New("the HTTP IP address source requires a preset or a url")
+	}
+	switch httpSource.Family {
+	case "", ipaddress.FamilyIPv4, ipaddress.FamilyIPv6:
+	default:
+		return nil, fmt.Errorf("unsupported IP address family %s", httpSource.Family)
+	}
+	return &httpSource, nil
+}
+
+//parseSourceTimeout parses and returns the optional source.Timeout duration, zero when no timeout is configured
+func parseSourceTimeout(source *config.IPSource) (time.Duration, error) {
+	if source.Timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(source.Timeout)
 }
 
 //returns the corresponding ddns.IDynamicDnsClient for the supplied serviceConfig.ServiceType
@@ -120,8 +581,9 @@ func getDDNSClient(serviceConfig *config.ServiceConfiguration) ddns.IDynamicDnsC
 	}
 }
 
-//sendNotifications sends all configured notifications on ip address change
-func sendNotifications(cfg *config.Configuration, ipv4, ipv6 net.IP) error {
+//sendNotifications sends the supplied event to all configured notifiers, the hostname, domains and timestamp of the
+//event are filled in here
+func sendNotifications(cfg *config.Configuration, event *notifications.Event) error {
 	var err error
 	mgr := notifications.GetManager(&cfg.Notifications)
 	if mgr.GetNotifierCount() > 0 {
@@ -129,10 +591,68 @@ func sendNotifications(cfg *config.Configuration, ipv4, ipv6 net.IP) error {
 		if err != nil {
 			return err
 		}
-		err = mgr.Send(cfg.Hostname, len(cfg.Services), domainsStr, ipv4.String(), ipv6.String())
+		event.Hostname = cfg.Hostname
+		event.DomainCount = len(cfg.GetLocalServices())
+		event.Domains = domainsStr
+		event.Timestamp = time.Now()
+		err = mgr.Send(event)
 		if err != nil {
 			return err
 		}
 	}
 	return err
 }
+
+//sendSuppressedNotifications sends notifications about suppressed address changes when enabled, once per suppressed
+//address
+func sendSuppressedNotifications(cfg *config.Configuration, suppressed []suppressedChange) error {
+	if cfg.ChangeValidation == nil || !cfg.ChangeValidation.NotifySuppressed {
+		return nil
+	}
+	for _, change := range takeUnnotified(suppressed) {
+		event := &notifications.Event{
+			Type:    notifications.EventChangeSuppressed,
+			OldIPv4: formatAddress(cfg.LastIPv4),
+			OldIPv6: formatAddress(cfg.LastIPv6),
+			Reason:  change.reason,
+		}
+		if change.family == ipaddress.FamilyIPv4 {
+			event.IPv4 = formatAddress(change.ip)
+		} else {
+			event.IPv6 = formatAddress(change.ip)
+		}
+		if err := sendNotifications(cfg, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//newServiceResult returns the notifications.ServiceResult of the update of a single DDNS service
+func newServiceResult(
+	serviceConfig *config.ServiceConfiguration,
+	ipv4 net.IP,
+	ipv6 net.IP,
+	err error) notifications.ServiceResult {
+
+	result := notifications.ServiceResult{
+		ServiceType: serviceConfig.ServiceType,
+		Domain:      serviceConfig.TargetDomain,
+		IPv4:        formatAddress(ipv4),
+		IPv6:        formatAddress(ipv6),
+		Success:     err == nil,
+		Timestamp:   time.Now(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+//formatAddress returns the string form of the supplied address, an empty string rather than <nil> when there is none
+func formatAddress(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"github.com/bebo-dot-dev/go-ddns-client/service/ipaddress"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+//addressCandidate is a changed IP address that is held down until it has been seen for long enough to be published
+type addressCandidate struct {
+	ip        net.IP
+	firstSeen time.Time
+	count     int
+}
+
+//heldDownAddresses tracks the held down candidate address of each address family and the last suppressed address of
+//each family that a notification was sent for
+type heldDownAddresses struct {
+	mu         sync.Mutex
+	candidates map[string]*addressCandidate
+	notified   map[string]string
+}
+
+var changeHoldDown = heldDownAddresses{
+	candidates: make(map[string]*addressCandidate),
+	notified:   make(map[string]string),
+}
+
+//now returns the current time of the change hold down and the failure notification cooldown
+var now = time.Now
+
+//suppressedChange describes a changed IP address that was not published and why
+type suppressedChange struct {
+	family string
+	ip     net.IP
+	reason string
+}
+
+//validateAddresses checks the looked up addresses before they are published. A non global address or a changed address
+//that is still held down is not published, the last published address of its family is returned in its place along
+//with the suppressed changes. A missing address of a family that is not disabled keeps the last published address
+func validateAddresses(cfg *config.Configuration, ipv4, ipv6 net.IP) (net.IP, net.IP, []suppressedChange, error) {
+	validation := cfg.ChangeValidation
+	if validation == nil {
+		validation = &config.ChangeValidation{}
+	}
+	var holdDownDuration time.Duration
+	if validation.HoldDownDuration != "" {
+		var err error
+		if holdDownDuration, err = time.ParseDuration(validation.HoldDownDuration); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid changeValidation holdDownDuration: %v", err)
+		}
+	}
+
+	changeHoldDown.mu.Lock()
+	defer changeHoldDown.mu.Unlock()
+
+	var suppressed []suppressedChange
+	check := func(family string, ip, last net.IP, familyConfig *config.AddressFamily) net.IP {
+		reason := ""
+		if ip == nil && last != nil {
+			//a missing reading of an enabled family is not a change, the last address remains published
+			if mode, _ := getFamilyMode(familyConfig, familyOptional); mode != familyDisabled {
+				log.Printf("No %s address was read, the last address %s remains published", family, last)
+				return last
+			}
+		}
+		if ip.Equal(last) {
+			delete(changeHoldDown.candidates, family)
+			delete(changeHoldDown.notified, family)
+			return ip
+		}
+		if ip != nil && !validation.AllowNonGlobal && !ipaddress.IsPublicAddress(ip) {
+			reason = fmt.Sprintf("%s is not a global address", ip)
+			delete(changeHoldDown.candidates, family)
+		} else if last != nil {
+			reason = holdDown(family, ip, validation.HoldDownCount, holdDownDuration)
+		}
+		if reason == "" {
+			delete(changeHoldDown.candidates, family)
+			delete(changeHoldDown.notified, family)
+			return ip
+		}
+		log.Printf("The %s change from %s to %s was not published: %s", family, last, ip, reason)
+		suppressed = append(suppressed, suppressedChange{family: family, ip: ip, reason: reason})
+		return last
+	}
+
+	ipv4 = check(ipaddress.FamilyIPv4, ipv4, cfg.LastIPv4, cfg.IPv4)
+	ipv6 = check(ipaddress.FamilyIPv6, ipv6, cfg.LastIPv6, cfg.IPv6)
+	return ipv4, ipv6, suppressed, nil
+}
+
+//holdDown records a reading of the changed address of a family and returns the reason it is still held down, an empty
+//string once it has been seen for count consecutive readings and for the duration. Must be called with the lock held
+func holdDown(family string, ip net.IP, count int, duration time.Duration) string {
+	candidate := changeHoldDown.candidates[family]
+	if candidate == nil || !candidate.ip.Equal(ip) {
+		candidate = &addressCandidate{ip: ip, firstSeen: now()}
+		changeHoldDown.candidates[family] = candidate
+	}
+	candidate.count++
+
+	if candidate.count < count {
+		return fmt.Sprintf("%s was seen %d of %d consecutive times", ip, candidate.count, count)
+	}
+	if seen := now().Sub(candidate.firstSeen); seen < duration {
+		return fmt.Sprintf("%s was seen for %s of %s", ip, seen.Round(time.Second), duration)
+	}
+	return ""
+}
+
+//takeUnnotified returns the suppressed changes that no notification has been sent for yet and marks them as notified,
+//a change suppressed on every run while an address is held down is notified once
+func takeUnnotified(suppressed []suppressedChange) []suppressedChange {
+	changeHoldDown.mu.Lock()
+	defer changeHoldDown.mu.Unlock()
+
+	var unnotified []suppressedChange
+	for _, change := range suppressed {
+		if changeHoldDown.notified[change.family] == change.ip.String() {
+			continue
+		}
+		changeHoldDown.notified[change.family] = change.ip.String()
+		unnotified = append(unnotified, change)
+	}
+	return unnotified
+}
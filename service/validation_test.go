@@ -0,0 +1,182 @@
+package service
+
+import (
+	"github.com/bebo-dot-dev/go-ddns-client/service/config"
+	"github.com/bebo-dot-dev/go-ddns-client/service/ipaddress"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+//validationStep is a single run of the change validation, the reading of the IPv4 address after the clock advanced
+type validationStep struct {
+	advance   time.Duration
+	reading   string
+	published string
+	reason    string
+	notified  bool
+}
+
+//useValidationTestClock clears the held down addresses and replaces the clock with a test clock
+func useValidationTestClock(t *testing.T) func(time.Duration) {
+	changeHoldDown = heldDownAddresses{
+		candidates: make(map[string]*addressCandidate),
+		notified:   make(map[string]string),
+	}
+	return useTestClock(t)
+}
+
+//useTestClock replaces the clock with a clock that only moves when the returned function advances it
+func useTestClock(t *testing.T) func(time.Duration) {
+	current := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time {
+		return current
+	}
+	t.Cleanup(func() {
+		now = time.Now
+	})
+	return func(duration time.Duration) {
+		current = current.Add(duration)
+	}
+}
+
+func TestValidateAddresses(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation *config.ChangeValidation
+		family     *config.AddressFamily
+		last       string
+		steps      []validationStep
+	}{
+		{"unchanged address", &config.ChangeValidation{HoldDownCount: 3}, nil, "81.2.69.142", []validationStep{
+			{0, "81.2.69.142", "81.2.69.142", "", false},
+		}},
+		{"first address is published at once", &config.ChangeValidation{HoldDownCount: 3}, nil, "", []validationStep{
+			{0, "81.2.69.142", "81.2.69.142", "", false},
+		}},
+		{"change without a hold down", nil, nil, "81.2.69.142", []validationStep{
+			{0, "81.2.69.143", "81.2.69.143", "", false},
+		}},
+		{"held down for the consecutive readings", &config.ChangeValidation{HoldDownCount: 3}, nil, "81.2.69.142",
+			[]validationStep{
+				{0, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen 1 of 3 consecutive times", true},
+				{time.Minute, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen 2 of 3 consecutive times", false},
+				{time.Minute, "81.2.69.143", "81.2.69.143", "", false},
+			}},
+		{"held down for the duration", &config.ChangeValidation{HoldDownDuration: "10m"}, nil, "81.2.69.142",
+			[]validationStep{
+				{0, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen for 0s of 10m0s", true},
+				{5 * time.Minute, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen for 5m0s of 10m0s", false},
+				{5*time.Minute - time.Second, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen for 9m59s of 10m0s",
+					false},
+				{time.Second, "81.2.69.143", "81.2.69.143", "", false},
+			}},
+		{"held down for the readings and the duration",
+			&config.ChangeValidation{HoldDownCount: 2, HoldDownDuration: "10m"}, nil, "81.2.69.142",
+			[]validationStep{
+				{0, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen 1 of 2 consecutive times", true},
+				{time.Minute, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen for 1m0s of 10m0s", false},
+				{10 * time.Minute, "81.2.69.143", "81.2.69.143", "", false},
+			}},
+		{"a different candidate restarts the hold down", &config.ChangeValidation{HoldDownCount: 2}, nil,
+			"81.2.69.142", []validationStep{
+				{0, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen 1 of 2 consecutive times", true},
+				{time.Minute, "81.2.69.144", "81.2.69.142", "81.2.69.144 was seen 1 of 2 consecutive times", true},
+				{time.Minute, "81.2.69.144", "81.2.69.144", "", false},
+			}},
+		{"returning to the last address clears the candidate", &config.ChangeValidation{HoldDownCount: 2}, nil,
+			"81.2.69.142", []validationStep{
+				{0, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen 1 of 2 consecutive times", true},
+				{time.Minute, "81.2.69.142", "81.2.69.142", "", false},
+				{time.Minute, "81.2.69.143", "81.2.69.142", "81.2.69.143 was seen 1 of 2 consecutive times", true},
+			}},
+		{"non global address", nil, nil, "81.2.69.142", []validationStep{
+			{0, "192.168.1.10", "81.2.69.142", "192.168.1.10 is not a global address", true},
+			{time.Hour, "192.168.1.10", "81.2.69.142", "192.168.1.10 is not a global address", false},
+			{0, "100.64.0.1", "81.2.69.142", "100.64.0.1 is not a global address", true},
+		}},
+		{"non global address allowed", &config.ChangeValidation{AllowNonGlobal: true}, nil, "81.2.69.142",
+			[]validationStep{
+				{0, "192.168.1.10", "192.168.1.10", "", false},
+			}},
+		{"missing reading keeps the last address", &config.ChangeValidation{HoldDownCount: 2}, nil, "81.2.69.142",
+			[]validationStep{
+				{0, "", "81.2.69.142", "", false},
+			}},
+		{"missing reading of a disabled family", nil, &config.AddressFamily{Mode: "disabled"}, "81.2.69.142",
+			[]validationStep{
+				{0, "", "<nil>", "", false},
+			}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			advance := useValidationTestClock(t)
+			cfg := &config.Configuration{
+				ChangeValidation: test.validation,
+				IPv4:             test.family,
+				LastIPv4:         net.ParseIP(test.last).To4(),
+			}
+			for index, step := range test.steps {
+				advance(step.advance)
+				ipv4, ipv6, suppressed, err := validateAddresses(cfg, net.ParseIP(step.reading).To4(), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ipv4.String() != step.published || ipv6 != nil {
+					t.Errorf("reading %d: expected the published addresses %s and <nil>, got %s and %s",
+						index, step.published, ipv4, ipv6)
+				}
+				reason := ""
+				if len(suppressed) == 1 {
+					reason = suppressed[0].reason
+				}
+				if len(suppressed) > 1 || reason != step.reason {
+					t.Errorf("reading %d: expected the suppressed reason %q, got %v", index, step.reason, suppressed)
+				}
+				if notified := len(takeUnnotified(suppressed)) > 0; notified != step.notified {
+					t.Errorf("reading %d: expected the suppressed change to be notified %t, got %t",
+						index, step.notified, notified)
+				}
+				cfg.LastIPv4 = ipv4
+			}
+		})
+	}
+}
+
+func TestValidateAddressesInvalidHoldDownDuration(t *testing.T) {
+	useValidationTestClock(t)
+	cfg := &config.Configuration{ChangeValidation: &config.ChangeValidation{HoldDownDuration: "ten minutes"}}
+	_, _, _, err := validateAddresses(cfg, net.ParseIP("81.2.69.142").To4(), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid changeValidation holdDownDuration") {
+		t.Errorf("expected an invalid holdDownDuration error, got %v", err)
+	}
+}
+
+func TestValidateAddressesHoldsDownEachFamily(t *testing.T) {
+	advance := useValidationTestClock(t)
+	cfg := &config.Configuration{
+		ChangeValidation: &config.ChangeValidation{HoldDownDuration: "5m"},
+		LastIPv4:         net.ParseIP("81.2.69.142").To4(),
+		LastIPv6:         net.ParseIP("2a00:1450:4009:81d::200e"),
+	}
+	newIPv4, newIPv6 := net.ParseIP("81.2.69.143").To4(), net.ParseIP("2a00:1450:4009:81d::200f")
+
+	_, _, suppressed, _ := validateAddresses(cfg, newIPv4, cfg.LastIPv6)
+	if len(takeUnnotified(suppressed)) != 1 {
+		t.Fatalf("expected the IPv4 change to be held down and notified, got %v", suppressed)
+	}
+	advance(3 * time.Minute)
+	_, _, suppressed, _ = validateAddresses(cfg, newIPv4, newIPv6)
+	if unnotified := takeUnnotified(suppressed); len(suppressed) != 2 || len(unnotified) != 1 ||
+		unnotified[0].family != ipaddress.FamilyIPv6 {
+		t.Fatalf("expected both changes to be held down and only the IPv6 change to be notified, got %v", suppressed)
+	}
+	advance(2 * time.Minute)
+	ipv4, ipv6, suppressed, _ := validateAddresses(cfg, newIPv4, newIPv6)
+	if !ipv4.Equal(newIPv4) || !ipv6.Equal(cfg.LastIPv6) || len(suppressed) != 1 ||
+		suppressed[0].reason != "2a00:1450:4009:81d::200f was seen for 2m0s of 5m0s" {
+		t.Errorf("expected the IPv4 change to be published and the IPv6 change to be held down, got %s %s %v",
+			ipv4, ipv6, suppressed)
+	}
+}